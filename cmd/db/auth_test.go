@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestAuth(c *check.C) {
+	api := new(gate)
+	api.open(authMiddleware("secret", s.router))
+	root := newRootHandler(api)
+	get := func(target, authorization string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		root.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Then
+	c.Assert(get("/db/key", ""), check.Equals, http.StatusUnauthorized)
+	c.Assert(get("/admin/stats", "Bearer wrong"), check.Equals, http.StatusUnauthorized)
+	c.Assert(get("/db/key", "Bearer secret"), check.Equals, http.StatusNotFound)
+	c.Assert(get("/admin/stats", "Bearer secret"), check.Equals, http.StatusOK)
+	c.Assert(get("/health", ""), check.Equals, http.StatusOK)
+}
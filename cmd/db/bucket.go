@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+// response of DELETE /db/{bucket}/
+type DropResult struct {
+	Bucket  string `json:"bucket"`
+	Deleted int    `json:"deleted"`
+}
+
+// bucket-scoped routes: /db/{bucket}/ lists or drops a bucket, /db/{bucket}/{key} reads,
+// writes and deletes string values in it. Must be registered after /db/{key}/watch,
+// which is why no value in a bucket may be written under the key watch.
+func routeBuckets(r *mux.Router, db *datastore.Db) {
+	bucketOf := func(w http.ResponseWriter, r *http.Request) *datastore.Bucket {
+		bucket, err := db.Bucket(mux.Vars(r)["bucket"])
+		if err != nil {
+			writeError(w, err)
+			return nil
+		}
+		return bucket
+	}
+
+	r.HandleFunc("/db/{bucket}/", func(w http.ResponseWriter, r *http.Request) {
+		if bucket := bucketOf(w, r); bucket != nil {
+			serveList(w, r, bucket.NewIterator, func(key string) (interface{}, error) {
+				return bucket.Get(key)
+			})
+		}
+	}).Methods("GET")
+
+	r.HandleFunc("/db/{bucket}/", func(w http.ResponseWriter, r *http.Request) {
+		bucket := bucketOf(w, r)
+		if bucket == nil {
+			return
+		}
+		n, err := bucket.Drop()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeResponse(w, r, http.StatusOK, DropResult{Bucket: mux.Vars(r)["bucket"], Deleted: n})
+	}).Methods("DELETE")
+
+	r.HandleFunc("/db/{bucket}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		bucket := bucketOf(w, r)
+		if bucket == nil {
+			return
+		}
+		key := mux.Vars(r)["key"]
+		value, err := bucket.Get(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		etag := etagFor(value)
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			return
+		}
+		writeKeyValue(w, r, KeyValue{Key: key, Value: value})
+	}).Methods("GET")
+
+	r.HandleFunc("/db/{bucket}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		bucket := bucketOf(w, r)
+		if bucket == nil {
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, err)
+			return
+		}
+		if err := bucket.Put(mux.Vars(r)["key"], req.Value); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", etagFor(req.Value))
+		writeResponse(w, r, http.StatusOK, req)
+	}).Methods("POST", "PUT")
+
+	r.HandleFunc("/db/{bucket}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		bucket := bucketOf(w, r)
+		if bucket == nil {
+			return
+		}
+		if err := bucket.Delete(mux.Vars(r)["key"]); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestBuckets(c *check.C) {
+	// When
+	c.Assert(s.do("POST", "/db/sessions/abc", `{"value": "session"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/sessions/def", `{"value": "session"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/users/abc", `{"value": "user"}`).Code, check.Equals, http.StatusOK)
+
+	// Then
+	rec := s.do("GET", "/db/users/abc", "")
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"key":"abc","value":"user"}`+"\n")
+	c.Assert(s.do("GET", "/db/abc", "").Code, check.Equals, http.StatusNotFound)
+
+	rec = s.do("GET", "/db/sessions/?values=true", "")
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"items":[{"key":"abc","value":"session"},{"key":"def","value":"session"}]}`+"\n")
+
+	c.Assert(s.do("DELETE", "/db/sessions/def", "").Code, check.Equals, http.StatusNoContent)
+	c.Assert(s.do("GET", "/db/sessions/def", "").Code, check.Equals, http.StatusNotFound)
+
+	// When the bucket is dropped
+	rec = s.do("DELETE", "/db/sessions/", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"bucket":"sessions","deleted":1}`+"\n")
+	c.Assert(s.do("GET", "/db/sessions/abc", "").Code, check.Equals, http.StatusNotFound)
+	c.Assert(s.do("GET", "/db/users/abc", "").Code, check.Equals, http.StatusOK)
+}
+
+func (s *MySuite) TestBucketKeyWatchIsReserved(c *check.C) {
+	// When a value is written under the key of the watch route
+	rec := s.do("PUT", "/db/users/watch", `{"value": "v"}`)
+
+	// Then it is refused, it could never be read back
+	c.Assert(rec.Code, check.Equals, http.StatusUnprocessableEntity)
+	c.Assert(s.do("POST", "/admin/import", `{"key":"users/watch","value":"v"}`).Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("PUT", "/db/watch", `{"value": "v"}`).Code, check.Equals, http.StatusCreated)
+}
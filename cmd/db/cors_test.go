@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestCORS(c *check.C) {
+	handler := corsMiddleware([]string{"http://dashboard"}, "GET, POST", authMiddleware("secret", s.router))
+	serve := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/stats", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Authorization", "Bearer secret")
+		if method == "OPTIONS" {
+			req.Header.Del("Authorization")
+			req.Header.Set("Access-Control-Request-Method", "GET")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// When a preflight request is made without credentials
+	rec := serve("OPTIONS", "http://dashboard")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	c.Assert(rec.Header().Get("Access-Control-Allow-Origin"), check.Equals, "http://dashboard")
+	c.Assert(rec.Header().Get("Access-Control-Allow-Methods"), check.Equals, "GET, POST")
+
+	// When
+	rec = serve("GET", "http://dashboard")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Access-Control-Allow-Origin"), check.Equals, "http://dashboard")
+
+	// When the origin is not allowed
+	rec = serve("GET", "http://elsewhere")
+
+	// Then
+	c.Assert(rec.Header().Get("Access-Control-Allow-Origin"), check.Equals, "")
+}
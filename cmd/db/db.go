@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mikhmol/Architecture_Lab4/datastore"
@@ -15,87 +19,574 @@ import (
 	"github.com/mikhmol/Architecture_Lab4/signal"
 )
 
-var port = flag.Int("port", 8080, "server port")
+var (
+	port            = flag.Int("port", 8080, "server port")
+	listen          = flag.String("listen", "", "address to listen on as host:port or unix:///path.sock, overrides -port")
+	apiKey          = flag.String("api-key", "", "key required in the Authorization header of /db and /admin requests")
+	apiKeyFile      = flag.String("api-key-file", "", "file containing the API key, used when -api-key is empty")
+	rateLimit       = flag.Float64("rate-limit", 0, "requests per second allowed for each client IP, 0 disables rate limiting")
+	rateBurst       = flag.Int("rate-burst", 0, "number of requests a client may make at once, defaults to the rate limit")
+	corsOrigins     = flag.String("cors-origins", "", "comma separated origins allowed to make cross-origin requests, * allows any")
+	corsMethods     = flag.String("cors-methods", "GET, POST, PUT, DELETE", "methods allowed in cross-origin requests")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "time given to in-flight requests to finish on shutdown")
+	role            = flag.String("role", rolePrimary, "role of the instance: primary, replica or proxy")
+	primaryURL      = flag.String("primary-url", "", "base URL of the primary a replica follows, e.g. http://db:8080")
+	shards          = flag.String("shards", "", "comma separated base URLs of the nodes a proxy routes keys to")
+	shardInterval   = flag.Duration("shard-check-interval", 5*time.Second, "time between health checks of the shard nodes")
+	maxBodySize     = flag.Int64("max-body-size", datastore.TenMegabytes, "maximum request body size in bytes, 0 disables the limit")
+	readOnly        = flag.Bool("read-only", false, "reject writes with 405 and serve reads only")
+	debug           = flag.Bool("debug", false, "serve net/http/pprof handlers under /debug/pprof on the debug port")
+	debugPort       = flag.Int("debug-port", 6060, "admin port of the pprof handlers")
+	logLevelName    = flag.String("log-level", "info", "request log level: debug, info, warn, error or off")
+	requestTimeout  = flag.Duration("request-timeout", 5*time.Second, "time a request may take before it is answered with 504, less than the 10s write timeout of the server, 0 disables the limit")
+)
 
 type Request struct {
 	Value string `json:"value"`
 }
 
+// request body for ?type=int64 writes
+type Int64Request struct {
+	Value int64 `json:"value"`
+}
+
+// header alternative to the ?ttl= parameter of writes, GET responses carry the seconds left in it
+const ttlHeader = "X-TTL"
+
+const (
+	typeString = "string"
+	typeInt64  = "int64"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type ListItem struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type BulkItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type BulkResult struct {
+	Key    string `json:"key"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// response of POST /db/_mget, items follow the order of the requested keys
+type MultiGetResponse struct {
+	Found   []KeyValue `json:"found"`
+	Missing []string   `json:"missing"`
+}
+
+type ListResponse struct {
+	Items  []ListItem `json:"items"`
+	Cursor string     `json:"cursor,omitempty"` // pass as ?cursor= to get the next page
+}
+
 func main() {
+	flag.Parse()
 	log.Println("Intializing database server ...")
+	if *requestTimeout >= httptools.WriteTimeout {
+		log.Fatalf("-request-timeout must be less than the write timeout %s, or the 504 never reaches the client", httptools.WriteTimeout)
+	}
 
-	r := mux.NewRouter()
-
-	dir, err := ioutil.TempDir("", "test-db")
+	key, err := loadAPIKey(*apiKey, *apiKeyFile)
 	if err != nil {
-		fmt.Println("Error creating temporary directory:", err)
+		fmt.Println("Error reading API key:", err)
 		os.Exit(1) // Exit with a non-zero error code
 	}
-	defer func() {
-		os.RemoveAll(dir)
-	}()
-
-	db, err := datastore.NewDb(dir)
+	level, err := parseLogLevel(*logLevelName)
 	if err != nil {
-		fmt.Println("Error creating database:", err)
+		fmt.Println(err)
 		os.Exit(1) // Exit with a non-zero error code
 	}
-	defer db.Close()
+	reqLogger := &requestLogger{level: level, out: os.Stdout}
+	if *role != rolePrimary && *role != roleReplica && *role != roleProxy {
+		fmt.Println("Unknown role", *role)
+		os.Exit(1) // Exit with a non-zero error code
+	}
+	if *role == roleReplica && *primaryURL == "" {
+		fmt.Println("-primary-url is required for replicas")
+		os.Exit(1) // Exit with a non-zero error code
+	}
+	var proxy *shardProxy
+	if *role == roleProxy {
+		if proxy, err = newShardProxy(splitList(*shards), key); err != nil {
+			fmt.Println("Error configuring shards:", err)
+			os.Exit(1) // Exit with a non-zero error code
+		}
+	}
+
+	if *debug {
+		startDebugServer(*debugPort)
+	}
+
+	// serve health probes while the database is being recovered
+	api := new(gate)
+	addr := *listen
+	if addr == "" {
+		addr = fmt.Sprintf(":%d", *port)
+	}
+	server := httptools.CreateServerAt(addr, newRootHandler(api))
+	log.Println("Starting database server ...")
+	server.Start()
+
+	var handler http.Handler
+	if proxy != nil {
+		// no local datastore, keys live on the shard nodes
+		healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+		defer stopHealthChecks()
+		go proxy.watchHealth(healthCtx, *shardInterval)
+		handler = timeoutMiddleware(*requestTimeout, proxy)
+	} else {
+		dir, err := ioutil.TempDir("", "test-db")
+		if err != nil {
+			fmt.Println("Error creating temporary directory:", err)
+			os.Exit(1) // Exit with a non-zero error code
+		}
+		defer func() {
+			os.RemoveAll(dir)
+		}()
+
+		db, err := datastore.NewDb(dir)
+		if err != nil {
+			fmt.Println("Error creating database:", err)
+			os.Exit(1) // Exit with a non-zero error code
+		}
+		defer db.Close()
+
+		handler = timeoutMiddleware(*requestTimeout, newRouter(db))
+		if *role == roleReplica {
+			handler = replicaMiddleware(*primaryURL, handler)
+			replicationCtx, stopReplication := context.WithCancel(context.Background())
+			defer stopReplication()
+			go replicate(replicationCtx, db, *primaryURL, key)
+		}
+	}
+	var limiter *rateLimiter
+	if *rateLimit > 0 {
+		limiter = newRateLimiter(*rateLimit, *rateBurst)
+	}
+	if *readOnly {
+		handler = readOnlyMiddleware(handler)
+	}
+	handler = bodyLimitMiddleware(*maxBodySize, handler)
+	handler = authMiddleware(key, handler)
+	handler = rateLimitMiddleware(limiter, handler)
+	handler = corsMiddleware(splitList(*corsOrigins), *corsMethods, handler)
+	api.open(httptools.RequestIDMiddleware(loggingMiddleware(reqLogger, handler)))
+	log.Println("Database is ready")
+	signal.WaitForTerminationSignal()
+
+	// drain in-flight requests before the deferred db.Close
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Error draining requests:", err)
+	}
+	log.Println("Database server stopped")
+}
+
+// API handler, legacy paths without the version prefix are served as well
+func newRouter(db *datastore.Db) http.Handler {
+	root := mux.NewRouter()
+	root.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, "not found", http.StatusNotFound)
+	})
+	root.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	})
+	root.Use(gzipMiddleware)
+	r := root.PathPrefix(apiPrefix).Subrouter()
+	r.Use(keyValidationMiddleware)
+
+	// registered before /db/{key} so that "_bulk", "_mget" and "_search" are not taken for keys
+	r.HandleFunc("/db/_bulk", func(w http.ResponseWriter, r *http.Request) {
+		var items []BulkItem
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			badRequest(w, err)
+			return
+		}
+
+		var batch datastore.WriteBatch
+		results := make([]BulkResult, len(items))
+		for i, item := range items {
+			results[i].Key = item.Key
+			if item.Key == "" {
+				results[i].Status = http.StatusBadRequest
+				results[i].Error = "empty key"
+				continue
+			}
+			if err := validateKey(item.Key); err != nil {
+				results[i].Status = http.StatusUnprocessableEntity
+				results[i].Error = err.Error()
+				continue
+			}
+			batch.Put(item.Key, item.Value)
+			results[i].Status = http.StatusOK
+		}
 
+		status := http.StatusOK
+		if err := db.Write(&batch); err != nil {
+			status = errorStatus(err)
+			for i := range results {
+				if results[i].Status == http.StatusOK {
+					results[i].Status = status
+					results[i].Error = err.Error()
+				}
+			}
+		}
+
+		writeResponse(w, r, status, results)
+	}).Methods("POST")
+
+	r.HandleFunc("/db/_mget", func(w http.ResponseWriter, r *http.Request) {
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			badRequest(w, err)
+			return
+		}
+		if len(keys) > maxListLimit {
+			jsonError(w, fmt.Sprintf("at most %d keys can be requested at once", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		for _, key := range keys {
+			if err := validateReadKey(key); err != nil {
+				jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		values, err := db.MultiGet(keys)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response := MultiGetResponse{Found: make([]KeyValue, 0, len(values)), Missing: make([]string, 0)}
+		for _, key := range keys {
+			if value, ok := values[key]; ok {
+				response.Found = append(response.Found, KeyValue{Key: key, Value: value})
+			} else if value, err := db.GetInt64(key); err == nil {
+				// MultiGet only returns strings, other types are rare enough to be read one by one
+				response.Found = append(response.Found, KeyValue{Key: key, Value: value})
+			} else {
+				response.Missing = append(response.Missing, key)
+			}
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}).Methods("POST")
+
+	r.HandleFunc("/db/_search", func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "" {
+			jsonError(w, "prefix is required, use GET /db to list all keys", http.StatusBadRequest)
+			return
+		}
+		limit, err := parseLimit(r.URL.Query().Get("limit"))
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+
+		// one extra key tells whether there are more matches
+		results, err := db.Scan(prefix, limit+1)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		response := ListResponse{Items: make([]ListItem, 0, len(results))}
+		for _, res := range results {
+			if len(response.Items) == limit {
+				response.Cursor = response.Items[limit-1].Key
+				break
+			}
+			item := ListItem{Key: res.Key, Value: res.Value}
+			if res.Type == datastore.TypeInt64 {
+				item.Value, _ = strconv.ParseInt(res.Value, 10, 64)
+			}
+			response.Items = append(response.Items, item)
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}).Methods("GET")
+
+	getValue := func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		key := vars["key"]
+
+		valueType := r.URL.Query().Get("type")
+		if valueType == "" && negotiate(r) == contentTypeOctetStream {
+			downloadValue(w, r, db, key)
+			return
+		}
+
+		var value interface{}
+		var err error
+		switch valueType {
+		case "", typeString:
+			value, err = db.Get(key)
+		case typeInt64:
+			value, err = db.GetInt64(key)
+		default:
+			jsonError(w, "unsupported type "+valueType, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		kv := KeyValue{Key: key, Value: value}
+		if ttl, err := db.TTL(key); err == nil && ttl > 0 {
+			kv.TTL = int64(math.Ceil(ttl.Seconds()))
+			w.Header().Set(ttlHeader, strconv.FormatInt(kv.TTL, 10))
+		}
+
+		etag := etagFor(value)
+		w.Header().Set("ETag", etag)
+		if notModified(w, r, etag) {
+			return
+		}
+		writeKeyValue(w, r, kv)
+	}
+	r.HandleFunc("/db/{key}", getValue).Methods("GET")
+
+	// same headers as GET without the body, missing keys are answered from the index
 	r.HandleFunc("/db/{key}", func(w http.ResponseWriter, r *http.Request) {
+		if !db.Has(mux.Vars(r)["key"]) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		counter := &countingResponseWriter{header: w.Header()}
+		getValue(counter, r)
+		if counter.status == 0 {
+			counter.status = http.StatusOK
+		}
+		w.Header().Set("content-length", strconv.Itoa(counter.size))
+		w.WriteHeader(counter.status)
+	}).Methods("HEAD")
+
+	r.HandleFunc("/db/{key}", func(w http.ResponseWriter, r *http.Request) {
+		var request interface{}
 		vars := mux.Vars(r)
 		key := vars["key"]
 
-		value, err := db.Get(key)
+		ttl, err := parseTTL(r)
 		if err != nil {
-			if err == datastore.ErrNotFound {
-				http.NotFound(w, r)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch != "" && (ttl != 0 || isOctetStream(r)) {
+			jsonError(w, "If-Match cannot be combined with ttl or streamed values", http.StatusBadRequest)
+			return
+		}
+
+		if isOctetStream(r) {
+			if ttl != 0 {
+				jsonError(w, "ttl is not supported for streamed values", http.StatusBadRequest)
+				return
+			}
+			uploadValue(w, r, db, key)
+			return
+		}
+
+		created := r.Method == http.MethodPut && !db.Has(key)
+		var written interface{}
+		switch valueType := r.URL.Query().Get("type"); valueType {
+		case "", typeString:
+			var req Request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				badRequest(w, err)
+				return
+			}
+			request, written = req, req.Value
+			if ifMatch != "" {
+				err = putIfMatch(db, key, ifMatch, req.Value)
+			} else if ttl != 0 {
+				err = db.PutWithTTL(key, req.Value, ttl)
+			} else {
+				err = db.Put(key, req.Value)
+			}
+		case typeInt64:
+			if ttl != 0 {
+				jsonError(w, fmt.Sprintf("ttl is not supported for %s values", typeInt64), http.StatusBadRequest)
+				return
+			}
+			var req Int64Request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				badRequest(w, err)
+				return
+			}
+			request, written = req, req.Value
+			if ifMatch != "" {
+				err = putIfMatch(db, key, ifMatch, req.Value)
 			} else {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				err = db.PutInt64(key, req.Value)
 			}
+		default:
+			jsonError(w, "unsupported type "+valueType, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 
-		response := struct {
-			Key   string      `json:"key"`
-			Value interface{} `json:"value"`
-		}{
-			Key:   key,
-			Value: value,
+		// PUT tells creation from replacement, POST always answers 200
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+			w.Header().Set("Location", r.URL.Path)
 		}
+		w.Header().Set("ETag", etagFor(written))
+		writeResponse(w, r, status, request)
+	}).Methods("POST", "PUT")
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+	r.HandleFunc("/db/{key}", func(w http.ResponseWriter, r *http.Request) {
+		key := mux.Vars(r)["key"]
 
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := db.Delete(key); err != nil {
+			writeError(w, err)
+			return
 		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+
+	r.HandleFunc("/db/{key}/watch", func(w http.ResponseWriter, r *http.Request) {
+		serveWatch(w, r, db, mux.Vars(r)["key"], true)
 	}).Methods("GET")
 
-	r.HandleFunc("/db/{key}", func(w http.ResponseWriter, r *http.Request) {
-		var request Request
-		vars := mux.Vars(r)
-		key := vars["key"]
+	r.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		serveWatch(w, r, db, r.URL.Query().Get("prefix"), false)
+	}).Methods("GET")
+
+	routeBuckets(r, db)
 
-		err := json.NewDecoder(r.Body).Decode(&request)
+	r.HandleFunc("/admin/compact", func(w http.ResponseWriter, r *http.Request) {
+		res, err := db.Compact()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err)
 			return
 		}
+		writeResponse(w, r, http.StatusOK, res)
+	}).Methods("POST")
 
-		err = db.Put(key, request.Value)
+	r.HandleFunc("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := db.Stats()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err)
 			return
 		}
+		writeResponse(w, r, http.StatusOK, stats)
+	}).Methods("GET")
+
+	r.HandleFunc("/admin/replication", func(w http.ResponseWriter, r *http.Request) {
+		serveReplication(w, r, db)
+	}).Methods("GET")
+
+	r.HandleFunc("/admin/export", func(w http.ResponseWriter, r *http.Request) {
+		exportValues(w, r, db)
+	}).Methods("GET")
 
-		w.Header().Set("content-type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(request)
+	r.HandleFunc("/admin/import", func(w http.ResponseWriter, r *http.Request) {
+		importValues(w, r, db)
 	}).Methods("POST")
 
-	server := httptools.CreateServer(*port, r)
-	log.Println("Starting database server ...")
-	server.Start()
-	signal.WaitForTerminationSignal()
+	r.HandleFunc("/db", func(w http.ResponseWriter, r *http.Request) {
+		serveList(w, r, db.NewIterator, func(key string) (interface{}, error) {
+			return readAnyValue(db, key)
+		})
+	}).Methods("GET")
+
+	return legacyPathMiddleware(root)
+}
+
+// list keys page by page using ?prefix=, ?limit=, ?cursor= and ?values=true
+func serveList(w http.ResponseWriter, r *http.Request, newIterator func(prefix, after string) *datastore.Iterator, readValue func(key string) (interface{}, error)) {
+	query := r.URL.Query()
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+	withValues := query.Get("values") == "true"
+
+	response := ListResponse{Items: make([]ListItem, 0)}
+	it := newIterator(query.Get("prefix"), query.Get("cursor"))
+	for it.Next() {
+		if len(response.Items) == limit {
+			response.Cursor = response.Items[limit-1].Key
+			break
+		}
+		item := ListItem{Key: it.Key()}
+		if withValues {
+			value, err := readValue(it.Key())
+			if err == datastore.ErrNotFound {
+				continue // deleted while listing
+			} else if err != nil {
+				writeError(w, err)
+				return
+			}
+			item.Value = value
+		}
+		response.Items = append(response.Items, item)
+	}
+
+	writeResponse(w, r, http.StatusOK, response)
+}
+
+// page size from ?limit=, capped at maxListLimit
+func parseLimit(l string) (int, error) {
+	if l == "" {
+		return defaultListLimit, nil
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid limit %s", l)
+	}
+	if n > maxListLimit {
+		n = maxListLimit
+	}
+	return n, nil
+}
+
+// expiration of a write taken from ?ttl= or the X-TTL header, either a duration such
+// as 90s or a number of seconds. Zero means the value does not expire.
+func parseTTL(r *http.Request) (time.Duration, error) {
+	s := r.URL.Query().Get("ttl")
+	if s == "" {
+		s = r.Header.Get(ttlHeader)
+	}
+	if s == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, convErr := strconv.ParseInt(s, 10, 64)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid ttl %s", s)
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("ttl must be positive, got %s", s)
+	}
+	return ttl, nil
+}
+
+// read the value of the key whatever its type is
+func readAnyValue(db *datastore.Db, key string) (interface{}, error) {
+	value, err := db.Get(key)
+	if err == datastore.ErrWrongType {
+		return db.GetInt64(key)
+	}
+	return value, err
 }
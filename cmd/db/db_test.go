@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/mikhmol/Architecture_Lab4/datastore"
 	check "gopkg.in/check.v1"
 )
 
@@ -12,6 +17,183 @@ import (
 
 func Test(t *testing.T) { check.TestingT(t) }
 
-type MySuite struct{}
+type MySuite struct {
+	db     *datastore.Db
+	router http.Handler
+}
 
 var _ = check.Suite(&MySuite{})
+
+func (s *MySuite) SetUpTest(c *check.C) {
+	db, err := datastore.NewDb(c.MkDir())
+	c.Assert(err, check.IsNil)
+	s.db = db
+	s.router = newRouter(db)
+}
+
+func (s *MySuite) TearDownTest(c *check.C) {
+	s.db.Close()
+}
+
+func (s *MySuite) do(method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *MySuite) TestDelete(c *check.C) {
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	rec := s.do("DELETE", "/db/key", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	c.Assert(s.do("GET", "/db/key", "").Code, check.Equals, http.StatusNotFound)
+	c.Assert(s.do("DELETE", "/db/key", "").Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *MySuite) TestInt64(c *check.C) {
+	// Given
+	c.Assert(s.do("POST", "/db/counter?type=int64", `{"value": 42}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/text", `{"value": "text"}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	rec := s.do("GET", "/db/counter?type=int64", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"key":"counter","value":42}`+"\n")
+
+	c.Assert(s.do("GET", "/db/counter", "").Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("GET", "/db/text?type=int64", "").Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("GET", "/db/text?type=float", "").Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("POST", "/db/counter?type=int64", `{"value": "42"}`).Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestPut(c *check.C) {
+	// When
+	created := s.do("PUT", "/db/key", `{"value": "v1"}`)
+	updated := s.do("PUT", "/db/key", `{"value": "v2"}`)
+
+	// Then
+	c.Assert(created.Code, check.Equals, http.StatusCreated)
+	c.Assert(created.Header().Get("Location"), check.Equals, "/api/v1/db/key")
+	c.Assert(updated.Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/key", `{"value": "v3"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/other", `{"value": "v1"}`).Code, check.Equals, http.StatusOK)
+
+	// errors of the datastore are not blamed on the client
+	s.db.SetQuota(1, datastore.QuotaReject)
+	c.Assert(s.do("PUT", "/db/key", `{"value": "v4"}`).Code, check.Equals, http.StatusInsufficientStorage)
+	c.Assert(s.do("PUT", "/db/key", `{"value": `).Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestList(c *check.C) {
+	// Given
+	for _, key := range []string{"user-1", "user-2", "user-3", "session-1"} {
+		c.Assert(s.do("POST", "/db/"+key, `{"value": "v"}`).Code, check.Equals, http.StatusOK)
+	}
+	c.Assert(s.do("POST", "/db/user-4?type=int64", `{"value": 4}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	first := s.do("GET", "/db?prefix=user-&limit=2", "")
+	second := s.do("GET", "/db?prefix=user-&limit=2&cursor=user-2&values=true", "")
+
+	// Then
+	c.Assert(first.Code, check.Equals, http.StatusOK)
+	c.Assert(first.Body.String(), check.Equals, `{"items":[{"key":"user-1"},{"key":"user-2"}],"cursor":"user-2"}`+"\n")
+	c.Assert(second.Code, check.Equals, http.StatusOK)
+	c.Assert(second.Body.String(), check.Equals, `{"items":[{"key":"user-3","value":"v"},{"key":"user-4","value":4}]}`+"\n")
+
+	c.Assert(s.do("GET", "/db?limit=abc", "").Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestSearch(c *check.C) {
+	// Given
+	for _, key := range []string{"sess-1", "sess-2", "user-1", "sess-3"} {
+		c.Assert(s.db.Put(key, "v"), check.IsNil)
+	}
+	c.Assert(s.db.PutInt64("sess-0", 7), check.IsNil)
+
+	// When
+	rec := s.do("GET", "/db/_search?prefix=sess-&limit=3", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals,
+		`{"items":[{"key":"sess-0","value":7},{"key":"sess-1","value":"v"},{"key":"sess-2","value":"v"}],"cursor":"sess-2"}`+"\n")
+
+	c.Assert(s.do("GET", "/db/_search?prefix=user-", "").Body.String(), check.Equals, `{"items":[{"key":"user-1","value":"v"}]}`+"\n")
+	c.Assert(s.do("GET", "/db/_search", "").Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestBulk(c *check.C) {
+	// When
+	rec := s.do("POST", "/db/_bulk", `[{"key": "a", "value": "1"}, {"key": "", "value": "2"}, {"key": "b", "value": "3"}]`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals,
+		`[{"key":"a","status":200},{"key":"","status":400,"error":"empty key"},{"key":"b","status":200}]`+"\n")
+
+	value, err := s.db.Get("b")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "3")
+
+	c.Assert(s.do("POST", "/db/_bulk", `{"key": "a"}`).Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestMultiGet(c *check.C) {
+	// Given
+	c.Assert(s.db.Put("a", "1"), check.IsNil)
+	c.Assert(s.db.Put("b", "2"), check.IsNil)
+	c.Assert(s.db.PutInt64("n", 3), check.IsNil)
+
+	// When
+	rec := s.do("POST", "/db/_mget", `["b", "missing", "n", "a"]`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals,
+		`{"found":[{"key":"b","value":"2"},{"key":"n","value":3},{"key":"a","value":"1"}],"missing":["missing"]}`+"\n")
+
+	c.Assert(s.do("POST", "/db/_mget", `{"key": "a"}`).Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestCompact(c *check.C) {
+	// Given
+	for i := 0; i < 3; i++ {
+		c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+	}
+
+	// When
+	rec := s.do("POST", "/admin/compact", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var res datastore.CompactionResult
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &res), check.IsNil)
+	c.Assert(res.SegmentsBefore, check.Equals, 1)
+	c.Assert(res.ReclaimedBytes > 0, check.Equals, true)
+	c.Assert(s.do("GET", "/db/key", "").Code, check.Equals, http.StatusOK)
+}
+
+func (s *MySuite) TestStats(c *check.C) {
+	// Given
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	rec := s.do("GET", "/admin/stats", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var stats datastore.Stats
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &stats), check.IsNil)
+	c.Assert(stats.KeyCount, check.Equals, 1)
+	c.Assert(stats.SegmentCount, check.Equals, 1)
+	c.Assert(stats.DeadBytes > 0, check.Equals, true)
+	c.Assert(stats.DiskUsage > stats.DeadBytes, check.Equals, true)
+}
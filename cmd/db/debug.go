@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprof handlers, kept off the API port so they are never exposed with it
+func newDebugHandler() http.Handler {
+	h := http.NewServeMux()
+	h.HandleFunc("/debug/pprof/", pprof.Index)
+	h.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	h.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	h.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	h.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return h
+}
+
+// serve pprof on the admin port. Unlike httptools servers there is no write timeout,
+// CPU profiles and traces take 30 seconds by default.
+func startDebugServer(port int) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: newDebugHandler(),
+	}
+	go func() {
+		log.Println("Serving pprof on port", port)
+		if err := server.ListenAndServe(); err != nil {
+			log.Println("Debug server finished:", err)
+		}
+	}()
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestDebugHandler(c *check.C) {
+	handler := newDebugHandler()
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Code
+	}
+
+	// Then
+	c.Assert(get("/debug/pprof/"), check.Equals, http.StatusOK)
+	c.Assert(get("/debug/pprof/heap"), check.Equals, http.StatusOK)
+	c.Assert(get("/db/key"), check.Equals, http.StatusNotFound)
+}
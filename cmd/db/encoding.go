@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeMsgpack  = "application/msgpack"
+	contentTypeProtobuf = "application/x-protobuf"
+
+	// raw values of GET and POST /db/{key}, see stream.go
+	contentTypeOctetStream = "application/octet-stream"
+)
+
+// pick the response encoding from the Accept header, JSON is the default.
+// Media types are taken in the order they are listed, quality values are ignored.
+func negotiate(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeJSON, "*/*":
+			return contentTypeJSON
+		case contentTypeMsgpack, "application/x-msgpack":
+			return contentTypeMsgpack
+		case contentTypeProtobuf, "application/protobuf":
+			return contentTypeProtobuf
+		case contentTypeOctetStream:
+			return contentTypeOctetStream
+		}
+	}
+	return contentTypeJSON
+}
+
+// encode v as JSON or MessagePack depending on the request. Protobuf is only
+// defined for key/value payloads (see writeKeyValue), other responses fall back to JSON.
+// So do raw values requested as application/octet-stream.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	var body []byte
+	var err error
+	contentType := negotiate(r)
+	if contentType == contentTypeMsgpack {
+		body, err = marshalMsgpack(v)
+	} else {
+		contentType = contentTypeJSON
+		body, err = json.Marshal(v)
+		body = append(body, '\n')
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// key/value payload of GET /db/{key}
+type KeyValue struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	TTL   int64       `json:"ttl,omitempty"` // seconds left until the key expires
+}
+
+func writeKeyValue(w http.ResponseWriter, r *http.Request, kv KeyValue) {
+	if negotiate(r) != contentTypeProtobuf {
+		writeResponse(w, r, http.StatusOK, kv)
+		return
+	}
+	w.Header().Set("content-type", contentTypeProtobuf)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(marshalKeyValueProto(kv))
+}
+
+// encode the key/value pair as the protobuf message
+//
+//	message KeyValue {
+//	  string key = 1;
+//	  oneof value {
+//	    string string_value = 2;
+//	    int64 int_value = 3;
+//	  }
+//	  int64 ttl = 4;
+//	}
+func marshalKeyValueProto(kv KeyValue) []byte {
+	var buf []byte
+	buf = appendProtoBytes(buf, 1, kv.Key)
+	switch v := kv.Value.(type) {
+	case string:
+		buf = appendProtoBytes(buf, 2, v)
+	case int64:
+		buf = binary.AppendUvarint(append(buf, 3<<3), uint64(v))
+	}
+	if kv.TTL != 0 {
+		buf = binary.AppendUvarint(append(buf, 4<<3), uint64(kv.TTL))
+	}
+	return buf
+}
+
+func appendProtoBytes(buf []byte, field int, s string) []byte {
+	buf = append(buf, byte(field<<3|2))
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// encode v in MessagePack, v is first converted to its JSON data model so struct tags are honored
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			writeMsgpackInt(buf, i)
+		} else if f, err := v.Float64(); err == nil {
+			buf.WriteByte(0xcb)
+			_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		} else {
+			return err
+		}
+	case string:
+		writeMsgpackHeader(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeMsgpackHeader(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			if err := writeMsgpack(buf, k); err != nil {
+				return err
+			}
+			if err := writeMsgpack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as msgpack", v)
+	}
+	return nil
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// write a length header using the fix format when possible, code8 of 0 means there is no 8-bit format
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) get(target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *MySuite) TestMsgpackResponse(c *check.C) {
+	c.Assert(s.do("POST", "/db/k", `{"value": "v"}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	rec := s.get("/db/k", "application/msgpack")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("content-type"), check.Equals, contentTypeMsgpack)
+	c.Assert(rec.Body.Bytes(), check.DeepEquals, []byte{
+		0x82,
+		0xa3, 'k', 'e', 'y', 0xa1, 'k',
+		0xa5, 'v', 'a', 'l', 'u', 'e', 0xa1, 'v',
+	})
+}
+
+func (s *MySuite) TestProtobufResponse(c *check.C) {
+	c.Assert(s.do("POST", "/db/k", `{"value": "v"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/n?type=int64", `{"value": 300}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	str := s.get("/db/k", "application/x-protobuf")
+	num := s.get("/db/n?type=int64", "application/x-protobuf")
+
+	// Then
+	c.Assert(str.Header().Get("content-type"), check.Equals, contentTypeProtobuf)
+	c.Assert(str.Body.Bytes(), check.DeepEquals, []byte{0x0a, 0x01, 'k', 0x12, 0x01, 'v'})
+	c.Assert(num.Body.Bytes(), check.DeepEquals, []byte{0x0a, 0x01, 'n', 0x18, 0xac, 0x02})
+
+	// protobuf is not defined for other payloads
+	list := s.get("/db", "application/x-protobuf")
+	c.Assert(list.Header().Get("content-type"), check.Equals, contentTypeJSON)
+}
+
+func (s *MySuite) TestMsgpackValues(c *check.C) {
+	data, err := marshalMsgpack(map[string]interface{}{
+		"a": []interface{}{true, nil, -1, 1000},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.DeepEquals, []byte{
+		0x81, 0xa1, 'a',
+		0x94, 0xc3, 0xc0, 0xff, 0xd3, 0, 0, 0, 0, 0, 0, 0x03, 0xe8,
+	})
+}
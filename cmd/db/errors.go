@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+// status code of an error returned by the datastore, unknown errors are taken for IO failures
+func errorStatus(err error) int {
+	switch err {
+	case datastore.ErrNotFound:
+		return http.StatusNotFound
+	case datastore.ErrWrongType, datastore.ErrInvalidBucket:
+		return http.StatusBadRequest
+	case datastore.ErrKeyTooLarge:
+		return http.StatusUnprocessableEntity
+	case datastore.ErrQuotaExceeded:
+		return http.StatusInsufficientStorage
+	case errPreconditionFailed:
+		return http.StatusPreconditionFailed
+	}
+	if errors.Is(err, datastore.ErrValueTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// body of every error response
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"` // snake_case status text, e.g. not_found
+	Message string `json:"message"`
+}
+
+// reply with the JSON error envelope, a replacement for http.Error
+func jsonError(w http.ResponseWriter, message string, status int) {
+	body, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: errorCode(status), Message: message}})
+	w.Header().Del("content-length")
+	w.Header().Set("content-type", contentTypeJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+func errorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	jsonError(w, err.Error(), errorStatus(err))
+}
+
+// 413 for bodies over the limit, 400 for other errors reading the request
+func requestErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func badRequest(w http.ResponseWriter, err error) {
+	jsonError(w, err.Error(), requestErrorStatus(err))
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestErrorResponses(c *check.C) {
+	cases := []struct {
+		method, target, body string
+		status               int
+		code                 string
+	}{
+		{"GET", "/db/missing", "", http.StatusNotFound, "not_found"},
+		{"POST", "/db/key", `{"value": `, http.StatusBadRequest, "bad_request"},
+		{"GET", "/db/key?type=float", "", http.StatusBadRequest, "bad_request"},
+		{"PATCH", "/db/key", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"GET", "/unknown/route", "", http.StatusNotFound, "not_found"},
+	}
+	for _, tc := range cases {
+		// When
+		rec := s.do(tc.method, tc.target, tc.body)
+
+		// Then
+		c.Assert(rec.Code, check.Equals, tc.status, check.Commentf("%s %s", tc.method, tc.target))
+		c.Assert(rec.Header().Get("content-type"), check.Equals, "application/json")
+		var res ErrorResponse
+		c.Assert(json.Unmarshal(rec.Body.Bytes(), &res), check.IsNil)
+		c.Assert(res.Error.Code, check.Equals, tc.code)
+		c.Assert(res.Error.Message, check.Not(check.Equals), "")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+var errPreconditionFailed = fmt.Errorf("stored value does not match If-Match")
+
+// strong entity tag derived from the stored value and its type
+func etagFor(value interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%T:%v", value, value)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// report whether an If-None-Match or If-Match header value lists the tag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// answer 304 when the client already has the current representation
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// write value (a string or an int64) only if the stored value of the same type matches
+// the If-Match header, errPreconditionFailed is returned otherwise
+func putIfMatch(db *datastore.Db, key, ifMatch string, value interface{}) error {
+	current, err := readAnyValue(db, key)
+	if err == datastore.ErrNotFound {
+		return errPreconditionFailed
+	} else if err != nil {
+		return err
+	}
+	if !etagMatches(ifMatch, etagFor(current)) {
+		return errPreconditionFailed
+	}
+
+	// the value may change between the read above and the swap, the swap detects that
+	swapped := false
+	switch value := value.(type) {
+	case string:
+		if old, ok := current.(string); ok {
+			swapped, err = db.CompareAndSwap(key, old, value)
+		}
+	case int64:
+		if old, ok := current.(int64); ok {
+			swapped, err = db.CompareAndSwapInt64(key, old, value)
+		}
+	}
+	if err == datastore.ErrNotFound || err == datastore.ErrWrongType || (err == nil && !swapped) {
+		return errPreconditionFailed
+	}
+	return err
+}
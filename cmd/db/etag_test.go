@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestETag(c *check.C) {
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+
+	first := s.do("GET", "/db/key", "")
+	etag := first.Header().Get("ETag")
+	c.Assert(etag, check.Not(check.Equals), "")
+
+	conditionalGet := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/db/key", nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// When the value is unchanged
+	rec := conditionalGet(`"other", ` + etag)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusNotModified)
+	c.Assert(rec.Header().Get("ETag"), check.Equals, etag)
+	c.Assert(rec.Body.Len(), check.Equals, 0)
+
+	// When the value changes
+	c.Assert(s.do("POST", "/db/key", `{"value": "changed"}`).Code, check.Equals, http.StatusOK)
+	rec = conditionalGet(etag)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("ETag"), check.Not(check.Equals), etag)
+}
+
+func (s *MySuite) TestIfMatch(c *check.C) {
+	rec := s.do("POST", "/db/key", `{"value": "v1"}`)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	c.Assert(etag, check.Equals, s.do("GET", "/db/key", "").Header().Get("ETag"))
+
+	conditionalPut := func(ifMatch, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", target, strings.NewReader(body))
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// When
+	rec = conditionalPut(etag, "/db/key", `{"value": "v2"}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("ETag"), check.Not(check.Equals), etag)
+
+	// When the editor holds a stale tag
+	rec = conditionalPut(etag, "/db/key", `{"value": "v3"}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusPreconditionFailed)
+	c.Assert(s.do("GET", "/db/key", "").Body.String(), check.Equals, `{"key":"key","value":"v2"}`+"\n")
+
+	c.Assert(conditionalPut("*", "/db/missing", `{"value": "v1"}`).Code, check.Equals, http.StatusPreconditionFailed)
+
+	c.Assert(s.do("POST", "/db/counter?type=int64", `{"value": 1}`).Code, check.Equals, http.StatusOK)
+	etag = s.do("GET", "/db/counter?type=int64", "").Header().Get("ETag")
+	c.Assert(conditionalPut(etag, "/db/counter?type=int64", `{"value": 2}`).Code, check.Equals, http.StatusOK)
+	c.Assert(conditionalPut(etag, "/db/counter?type=int64", `{"value": 3}`).Code, check.Equals, http.StatusPreconditionFailed)
+}
+
+func (s *MySuite) TestHead(c *check.C) {
+	c.Assert(s.db.Put("key", "value"), check.IsNil)
+	get := s.do("GET", "/db/key", "")
+
+	// When
+	rec := s.do("HEAD", "/db/key", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.Len(), check.Equals, 0)
+	c.Assert(rec.Header().Get("ETag"), check.Equals, get.Header().Get("ETag"))
+	c.Assert(rec.Header().Get("content-length"), check.Equals, strconv.Itoa(get.Body.Len()))
+
+	c.Assert(s.do("HEAD", "/db/missing", "").Code, check.Equals, http.StatusNotFound)
+}
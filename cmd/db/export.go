@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+const contentTypeJSONLines = "application/x-ndjson"
+
+// response of POST /admin/import
+type ImportResult struct {
+	Imported int `json:"imported"`
+}
+
+// line of an import, values are JSON strings or integers like in KeyValue
+type importRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	TTL   int64           `json:"ttl"`
+}
+
+// stream every key as a KeyValue JSON line
+func exportValues(w http.ResponseWriter, r *http.Request, db *datastore.Db) {
+	// large exports take longer than the write timeout
+	clearWriteDeadline(w)
+	w.Header().Set("content-type", contentTypeJSONLines)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	for it := db.NewIterator("", ""); it.Next(); {
+		kv := KeyValue{Key: it.Key()}
+		value, err := readAnyValue(db, kv.Key)
+		if err == datastore.ErrNotFound {
+			continue // deleted while exporting
+		} else if err != nil {
+			// the status is already sent, a cut stream is detected by the importer
+			fmt.Println("Error exporting", kv.Key, err)
+			return
+		}
+		kv.Value = value
+		if ttl, err := db.TTL(kv.Key); err == nil && ttl > 0 {
+			kv.TTL = int64(math.Ceil(ttl.Seconds()))
+		}
+		if err := enc.Encode(kv); err != nil {
+			return
+		}
+	}
+}
+
+// store key/value JSON lines as written by exportValues
+func importValues(w http.ResponseWriter, r *http.Request, db *datastore.Db) {
+	var result ImportResult
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), datastore.TenMegabytes)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := importRecordLine(db, scanner.Bytes()); err != nil {
+			jsonError(w, fmt.Sprintf("line %d: %s (%d records imported)", line, err, result.Imported), http.StatusBadRequest)
+			return
+		}
+		result.Imported++
+	}
+	if err := scanner.Err(); err != nil {
+		jsonError(w, fmt.Sprintf("%s (%d records imported)", err, result.Imported), requestErrorStatus(err))
+		return
+	}
+	writeResponse(w, r, http.StatusOK, result)
+}
+
+func importRecordLine(db *datastore.Db, line []byte) error {
+	var rec importRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
+	}
+	if rec.Key == "" {
+		return fmt.Errorf("empty key")
+	}
+	if err := validateStoredKey(rec.Key); err != nil {
+		return err
+	}
+
+	var s string
+	if err := json.Unmarshal(rec.Value, &s); err == nil {
+		if rec.TTL > 0 {
+			return db.PutWithTTL(rec.Key, s, time.Duration(rec.TTL)*time.Second)
+		}
+		return db.Put(rec.Key, s)
+	}
+	n, err := strconv.ParseInt(string(rec.Value), 10, 64)
+	if err != nil {
+		return fmt.Errorf("value of %s must be a string or an integer", rec.Key)
+	}
+	return db.PutInt64(rec.Key, n)
+}
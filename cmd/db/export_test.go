@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestExportImport(c *check.C) {
+	// Given
+	c.Assert(s.db.Put("a", "1"), check.IsNil)
+	c.Assert(s.db.PutInt64("n", 2), check.IsNil)
+	c.Assert(s.db.PutWithTTL("t", "3", time.Minute), check.IsNil)
+
+	// When
+	rec := s.do("GET", "/admin/export", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("content-type"), check.Equals, "application/x-ndjson")
+	exported := rec.Body.String()
+	c.Assert(exported, check.Equals,
+		`{"key":"a","value":"1"}`+"\n"+`{"key":"n","value":2}`+"\n"+`{"key":"t","value":"3","ttl":60}`+"\n")
+
+	// When the export is imported into another database
+	target, err := datastore.NewDb(c.MkDir())
+	c.Assert(err, check.IsNil)
+	defer target.Close()
+	s.router = newRouter(target)
+	rec = s.do("POST", "/admin/import", exported)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"imported":3}`+"\n")
+	c.Assert(s.do("GET", "/admin/export", "").Body.String(), check.Equals, exported)
+
+	rec = s.do("POST", "/admin/import", `{"key":"b","value":"1"}`+"\n"+`{"key":"c","value":true}`+"\n")
+	c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(rec.Body.String(), check.Equals,
+		`{"error":{"code":"bad_request","message":"line 2: value of c must be a string or an integer (1 records imported)"}}`+"\n")
+}
+
+func (s *MySuite) TestImportBucketKeys(c *check.C) {
+	// Given an export of a value in a bucket
+	c.Assert(s.do("PUT", "/db/users/u-1", `{"value": "ann"}`).Code, check.Equals, http.StatusOK)
+	exported := s.do("GET", "/admin/export", "").Body.String()
+	c.Assert(exported, check.Equals, `{"key":"users/u-1","value":"ann"}`+"\n")
+
+	// When it is imported into another database
+	target, err := datastore.NewDb(c.MkDir())
+	c.Assert(err, check.IsNil)
+	defer target.Close()
+	s.router = newRouter(target)
+	rec := s.do("POST", "/admin/import", exported)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("GET", "/db/users/u-1", "").Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/admin/import", `{"key":"users/a b","value":"v"}`).Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("POST", "/admin/import", `{"key":"a/b/c","value":"v"}`).Code, check.Equals, http.StatusBadRequest)
+}
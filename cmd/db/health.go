@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// gate answers 503 until the API handler is opened, so the process can report
+// liveness while the datastore is still being recovered
+type gate struct {
+	handler atomic.Value // http.Handler
+}
+
+func (g *gate) open(h http.Handler) {
+	g.handler.Store(h)
+}
+
+func (g *gate) ready() bool {
+	return g.handler.Load() != nil
+}
+
+func (g *gate) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	h, ok := g.handler.Load().(http.Handler)
+	if !ok {
+		jsonError(rw, "database is starting", http.StatusServiceUnavailable)
+		return
+	}
+	h.ServeHTTP(rw, r)
+}
+
+// serve health probes and pass everything else to the API
+func newRootHandler(api *gate) http.Handler {
+	h := new(http.ServeMux)
+
+	h.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("content-type", "text/plain")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("OK"))
+	})
+
+	h.HandleFunc("/ready", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("content-type", "text/plain")
+		if api.ready() {
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte("OK"))
+		} else {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = rw.Write([]byte("NOT READY"))
+		}
+	})
+
+	h.Handle("/", legacyPathMiddleware(api))
+	return h
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestReadiness(c *check.C) {
+	api := new(gate)
+	root := newRootHandler(api)
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		root.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Code
+	}
+
+	// Before the database is opened
+	c.Assert(get("/health"), check.Equals, http.StatusOK)
+	c.Assert(get("/ready"), check.Equals, http.StatusServiceUnavailable)
+	c.Assert(get("/db/key"), check.Equals, http.StatusServiceUnavailable)
+
+	// When
+	api.open(s.router)
+
+	// Then
+	c.Assert(get("/ready"), check.Equals, http.StatusOK)
+	c.Assert(get("/db/key"), check.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+// characters allowed in keys besides ASCII letters and digits
+const keyPunctuation = "-_.:@+=~"
+
+// check a key against the rules of the HTTP API, the error tells which one is broken
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	if len(key) > datastore.MaxKeySize {
+		return fmt.Errorf("key is %d bytes long, at most %d are allowed", len(key), datastore.MaxKeySize)
+	}
+	if strings.Contains(key, "..") {
+		return fmt.Errorf("key %q must not contain \"..\"", key)
+	}
+	for i, c := range key {
+		if !keyChar(c) {
+			return fmt.Errorf("key %q has invalid character %q at position %d, only letters, digits and %q are allowed", key, c, i, keyPunctuation)
+		}
+	}
+	return nil
+}
+
+// key name taken by the /db/{key}/watch route, a value in a bucket under it could not be read
+const reservedBucketKey = "watch"
+
+// check the key of a value in a bucket
+func validateBucketKey(key string) error {
+	if key == reservedBucketKey {
+		return fmt.Errorf("key %q is reserved in buckets", key)
+	}
+	return validateKey(key)
+}
+
+// check a key as stored, where values of buckets are under bucket/key and each part
+// follows the rules of the HTTP API
+func validateStoredKey(key string) error {
+	parts := strings.SplitN(key, "/", 2)
+	if err := validateKey(parts[0]); err != nil {
+		return err
+	}
+	if len(parts) == 2 {
+		return validateBucketKey(parts[1])
+	}
+	return nil
+}
+
+// check a key that is only read or deleted. Keys written before the character
+// rules existed break them, they stay readable and deletable.
+func validateReadKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	if len(key) > datastore.MaxKeySize {
+		return fmt.Errorf("key is %d bytes long, at most %d are allowed", len(key), datastore.MaxKeySize)
+	}
+	return nil
+}
+
+func keyChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.ContainsRune(keyPunctuation, c)
+}
+
+// answer requests to routes with an invalid {key} or {bucket} with 422, reads and
+// deletes are only held to validateReadKey
+func keyValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete
+		vars := mux.Vars(r)
+		_, inBucket := vars["bucket"]
+		for _, name := range []string{"bucket", "key"} {
+			value, ok := vars[name]
+			if !ok {
+				continue
+			}
+			var err error
+			switch {
+			case read:
+				err = validateReadKey(value)
+			case inBucket && name == "key":
+				err = validateBucketKey(value)
+			default:
+				err = validateKey(value)
+			}
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
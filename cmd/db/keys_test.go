@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestKeyValidation(c *check.C) {
+	// Given
+	long := strings.Repeat("k", 1025)
+
+	// Then
+	c.Assert(s.do("PUT", "/db/user:42@eu-1", `{"value": "value"}`).Code, check.Equals, http.StatusCreated)
+	for _, target := range []string{"/db/" + long, "/db/a..b", "/db/sp%20ace", "/db/%C3%A9", "/db/users/" + long} {
+		rec := s.do("PUT", target, `{"value": "value"}`)
+		c.Assert(rec.Code, check.Equals, http.StatusUnprocessableEntity, check.Commentf(target))
+		c.Assert(rec.Body.String(), check.Matches, `(?s)\{"error":\{"code":"unprocessable_entity","message":"key .*`)
+	}
+	c.Assert(s.do("GET", "/db/"+long, "").Code, check.Equals, http.StatusUnprocessableEntity)
+	c.Assert(s.do("POST", "/db/_mget", `["ok", ""]`).Code, check.Equals, http.StatusUnprocessableEntity)
+
+	// keys stored before the character rules stay readable and deletable
+	c.Assert(s.db.Put("old key", "value"), check.IsNil)
+	c.Assert(s.do("GET", "/db/old%20key", "").Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/_mget", `["old key"]`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("DELETE", "/db/old%20key", "").Code, check.Equals, http.StatusNoContent)
+
+	rec := s.do("POST", "/db/_bulk", `[{"key": "ok", "value": "v"}, {"key": "not ok", "value": "v"}]`)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Matches, `\[\{"key":"ok","status":200\},\{"key":"not ok","status":422,"error":"key .*"\}\]`+"\n")
+}
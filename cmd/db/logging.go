@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+	levelOff
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+// one JSON line per request
+type requestLog struct {
+	Time      string  `json:"time"`
+	Level     string  `json:"level"`
+	RequestID string  `json:"requestId,omitempty"`
+	TraceID   string  `json:"traceId,omitempty"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Key       string  `json:"key,omitempty"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	LatencyMs float64 `json:"latencyMs"`
+	Client    string  `json:"client"`
+}
+
+type requestLogger struct {
+	level logLevel
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// 5xx responses are logged as errors, 4xx as warnings and the rest as info
+func (l *requestLogger) log(entry requestLog) {
+	level := levelInfo
+	switch {
+	case entry.Status >= 500:
+		level = levelError
+	case entry.Status >= 400:
+		level = levelWarn
+	}
+	if level < l.level {
+		return
+	}
+	entry.Level = level.String()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(append(line, '\n'))
+}
+
+func loggingMiddleware(l *requestLogger, next http.Handler) http.Handler {
+	if l.level == levelOff {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		l.log(requestLog{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			RequestID: r.Header.Get(httptools.RequestIDHeader),
+			TraceID:   httptools.TraceID(r.Header),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Key:       requestKey(r.URL.Path),
+			Status:    rec.status,
+			Bytes:     rec.bytes,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Client:    client,
+		})
+	})
+}
+
+// key addressed by a /db/{key} request, empty for other routes
+func requestKey(path string) string {
+	key := strings.TrimPrefix(canonicalPath(path), apiPrefix+"/db/")
+	if key == path || key == "_bulk" || key == "_mget" || key == "_search" {
+		return ""
+	}
+	return strings.SplitN(key, "/", 2)[0] // drop sub-resources such as /watch
+}
+
+// remembers the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+// lets http.ResponseController reach the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestRequestLogging(c *check.C) {
+	var out bytes.Buffer
+	handler := loggingMiddleware(&requestLogger{level: levelInfo, out: &out}, s.router)
+	serve := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("X-Request-Id", "req-"+method)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// When
+	serve("POST", "/db/key", `{"value": "value"}`)
+	serve("GET", "/db/missing", "")
+
+	// Then
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	c.Assert(lines, check.HasLen, 2)
+
+	var entry requestLog
+	c.Assert(json.Unmarshal([]byte(lines[0]), &entry), check.IsNil)
+	c.Assert(entry.Level, check.Equals, "info")
+	c.Assert(entry.Method, check.Equals, "POST")
+	c.Assert(entry.Key, check.Equals, "key")
+	c.Assert(entry.Status, check.Equals, http.StatusOK)
+	c.Assert(entry.Bytes > 0, check.Equals, true)
+	c.Assert(entry.Client, check.Equals, "10.0.0.1")
+	c.Assert(entry.RequestID, check.Equals, "req-POST")
+	c.Assert(entry.TraceID, check.Equals, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	c.Assert(json.Unmarshal([]byte(lines[1]), &entry), check.IsNil)
+	c.Assert(entry.Level, check.Equals, "warn")
+	c.Assert(entry.Status, check.Equals, http.StatusNotFound)
+
+	// When only errors are logged
+	out.Reset()
+	handler = loggingMiddleware(&requestLogger{level: levelError, out: &out}, s.router)
+	serve("GET", "/db/missing", "")
+
+	// Then
+	c.Assert(out.Len(), check.Equals, 0)
+}
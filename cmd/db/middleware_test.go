@@ -0,0 +1,86 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestGzip(c *check.C) {
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+
+	// When
+	req := httptest.NewRequest("GET", "/db/key", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.9")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Encoding"), check.Equals, "gzip")
+	gz, err := gzip.NewReader(rec.Body)
+	c.Assert(err, check.IsNil)
+	body, err := ioutil.ReadAll(gz)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(body), check.Equals, `{"key":"key","value":"value"}`+"\n")
+
+	// plain response without Accept-Encoding
+	plain := s.do("GET", "/db/key", "")
+	c.Assert(plain.Header().Get("Content-Encoding"), check.Equals, "")
+	c.Assert(plain.Body.String(), check.Equals, `{"key":"key","value":"value"}`+"\n")
+
+	// no body to compress
+	req = httptest.NewRequest("DELETE", "/db/key", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNoContent)
+	c.Assert(rec.Body.Len(), check.Equals, 0)
+}
+
+func (s *MySuite) TestAcceptsGzip(c *check.C) {
+	for header, want := range map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"GZIP;Q=0.5":        true,
+		"deflate, gzip;q=0": false,
+		"gzip;q=0.000":      false,
+		"*":                 true,
+		"*;q=0":             false,
+		"gzip;q=0, *":       false,
+		"*;q=0, gzip;q=0.1": true,
+		"deflate, identity": false,
+	} {
+		r := httptest.NewRequest("GET", "/db/key", nil)
+		r.Header.Set("Accept-Encoding", header)
+		c.Assert(acceptsGzip(r), check.Equals, want, check.Commentf("Accept-Encoding: %s", header))
+	}
+}
+
+func (s *MySuite) TestBodyLimit(c *check.C) {
+	handler := bodyLimitMiddleware(32, s.router)
+	serve := func(target, body string, chunked bool) int {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	large := `{"value": "` + strings.Repeat("x", 64) + `"}`
+
+	// Then
+	c.Assert(serve("/db/key", `{"value": "small"}`, false), check.Equals, http.StatusOK)
+	c.Assert(serve("/db/key", large, false), check.Equals, http.StatusRequestEntityTooLarge)
+	c.Assert(serve("/db/key", large, true), check.Equals, http.StatusRequestEntityTooLarge)
+	c.Assert(serve("/db/_bulk", `[{"key": "a", "value": "`+strings.Repeat("x", 64)+`"}]`, true), check.Equals, http.StatusRequestEntityTooLarge)
+
+	value, err := s.db.Get("key")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "small")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestRateLimit(c *check.C) {
+	now := time.Now()
+	limiter := newRateLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+	handler := rateLimitMiddleware(limiter, s.router)
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/db/key", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// When the burst is used up
+	c.Assert(get("10.0.0.1:1000").Code, check.Equals, http.StatusNotFound)
+	c.Assert(get("10.0.0.1:1001").Code, check.Equals, http.StatusNotFound)
+	rec := get("10.0.0.1:1002")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusTooManyRequests)
+	c.Assert(rec.Header().Get("Retry-After"), check.Equals, "1")
+	c.Assert(get("10.0.0.2:1000").Code, check.Equals, http.StatusNotFound)
+
+	// When a token is refilled
+	now = now.Add(time.Second)
+
+	// Then
+	c.Assert(get("10.0.0.1:1003").Code, check.Equals, http.StatusNotFound)
+	c.Assert(get("10.0.0.1:1004").Code, check.Equals, http.StatusTooManyRequests)
+}
+
+func (s *MySuite) TestRateLimitIgnoresAuthorization(c *check.C) {
+	limiter := newRateLimiter(1, 1)
+	handler := rateLimitMiddleware(limiter, s.router)
+	get := func(authorization string) int {
+		req := httptest.NewRequest("GET", "/db/key", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// When every request sends another made up key
+	c.Assert(get("Bearer one"), check.Equals, http.StatusNotFound)
+
+	// Then they still share the bucket of their address
+	c.Assert(get("Bearer two"), check.Equals, http.StatusTooManyRequests)
+}
+
+func (s *MySuite) TestRateLimitBoundsClients(c *check.C) {
+	// Given buckets that never refill while the test runs
+	now := time.Now()
+	limiter := newRateLimiter(0.001, 1)
+	limiter.now = func() time.Time { return now }
+
+	// When more clients than tracked come
+	for i := 0; i <= maxTrackedClients; i++ {
+		now = now.Add(time.Nanosecond)
+		limiter.allow(fmt.Sprintf("ip:10.%d.%d.%d", i>>16, i>>8&255, i&255))
+	}
+
+	// Then the oldest is forgotten
+	c.Assert(len(limiter.buckets), check.Equals, maxTrackedClients)
+	_, ok := limiter.buckets["ip:10.0.0.0"]
+	c.Assert(ok, check.Equals, false)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+const (
+	rolePrimary = "primary"
+	roleReplica = "replica"
+
+	// changes a replica may lag behind while the snapshot is sent before it has to resync
+	replicationBuffer = 64 * 1024
+	// pause between attempts to reconnect to the primary
+	replicationRetryDelay = time.Second
+)
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opSynced = "synced" // the snapshot is complete, live changes follow
+)
+
+// line of the replication stream, values use the encoding of datastore.Event
+type ReplicationRecord struct {
+	Op      string `json:"op"`
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Expires int64  `json:"expires,omitempty"`
+}
+
+// stream a snapshot of all keys followed by live changes as JSON lines until the
+// replica disconnects. Changes are subscribed to before the snapshot so none is lost.
+func serveReplication(w http.ResponseWriter, r *http.Request, db *datastore.Db) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	clearWriteDeadline(w)
+	events, cancel := db.WatchBuffered("", replicationBuffer)
+	defer cancel()
+
+	w.Header().Set("content-type", contentTypeJSONLines)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	for it := db.NewIterator("", ""); it.Next(); {
+		rec, err := currentRecord(db, it.Key())
+		if err == datastore.ErrNotFound {
+			continue
+		} else if err != nil {
+			log.Println("Error reading", it.Key(), "for replication:", err)
+			return
+		}
+		if err := enc.Encode(rec); err != nil {
+			return
+		}
+	}
+	if err := enc.Encode(ReplicationRecord{Op: opSynced}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // the replica fell behind, it resyncs after reconnecting
+			}
+			rec := ReplicationRecord{Op: opPut, Key: event.Key, Value: event.Value, Type: event.Type, Expires: event.Expires}
+			if event.Deleted {
+				rec = ReplicationRecord{Op: opDelete, Key: event.Key}
+			} else if event.Streamed {
+				// streamed values are not in the event, the current one is sent like in the snapshot
+				var err error
+				rec, err = currentRecord(db, event.Key)
+				if err == datastore.ErrNotFound {
+					continue // deleted since, its event follows
+				} else if err != nil {
+					log.Println("Error reading", event.Key, "for replication:", err)
+					return
+				}
+			}
+			if err := enc.Encode(rec); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// put record of the value the key holds now
+func currentRecord(db *datastore.Db, key string) (ReplicationRecord, error) {
+	rec := ReplicationRecord{Op: opPut, Key: key}
+	value, err := readAnyValue(db, key)
+	if err != nil {
+		return rec, err
+	}
+	switch value := value.(type) {
+	case string:
+		rec.Value = value
+	case int64:
+		rec.Value, rec.Type = strconv.FormatInt(value, 10), datastore.TypeInt64
+	}
+	if ttl, err := db.TTL(key); err == nil && ttl > 0 {
+		rec.Expires = time.Now().Add(ttl).UnixNano()
+	}
+	return rec, nil
+}
+
+// follow the primary until ctx is done, reconnecting whenever the stream breaks
+func replicate(ctx context.Context, db *datastore.Db, primaryURL, apiKey string) {
+	for {
+		err := followPrimary(ctx, db, primaryURL, apiKey)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("Replication from", primaryURL, "interrupted:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(replicationRetryDelay):
+		}
+	}
+}
+
+func followPrimary(ctx context.Context, db *datastore.Db, primaryURL, apiKey string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(primaryURL, "/")+apiPrefix+"/admin/replication", nil)
+	if err != nil {
+		return err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("primary responded with %s", resp.Status)
+	}
+
+	// keys present locally but missing from the snapshot were deleted while disconnected
+	snapshot := make(map[string]bool)
+	synced := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), datastore.TenMegabytes)
+	for scanner.Scan() {
+		var rec ReplicationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return err
+		}
+		if rec.Op == opSynced {
+			if err := dropMissing(db, snapshot); err != nil {
+				return err
+			}
+			synced, snapshot = true, nil
+			log.Println("Replica is in sync with", primaryURL)
+			continue
+		}
+		if !synced {
+			snapshot[rec.Key] = true
+		}
+		if err := applyReplicationRecord(db, rec); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream closed by the primary")
+}
+
+func dropMissing(db *datastore.Db, keep map[string]bool) error {
+	var batch datastore.WriteBatch
+	for it := db.NewIterator("", ""); it.Next(); {
+		if !keep[it.Key()] {
+			batch.Delete(it.Key())
+		}
+	}
+	return db.Write(&batch)
+}
+
+func applyReplicationRecord(db *datastore.Db, rec ReplicationRecord) error {
+	switch rec.Op {
+	case opDelete:
+		if err := db.Delete(rec.Key); err != nil && err != datastore.ErrNotFound {
+			return err
+		}
+		return nil
+	case opPut:
+		if rec.Type == datastore.TypeInt64 {
+			n, err := strconv.ParseInt(rec.Value, 10, 64)
+			if err != nil {
+				return err
+			}
+			return db.PutInt64(rec.Key, n)
+		}
+		if rec.Expires != 0 {
+			ttl := time.Until(time.Unix(0, rec.Expires))
+			if ttl <= 0 {
+				return nil // expired on the way
+			}
+			return db.PutWithTTL(rec.Key, rec.Value, ttl)
+		}
+		return db.Put(rec.Key, rec.Value)
+	}
+	return fmt.Errorf("unknown replication op %q", rec.Op)
+}
+
+// redirect writes to the primary, reads are served by the replica
+func replicaMiddleware(primaryURL string, next http.Handler) http.Handler {
+	primaryURL = strings.TrimSuffix(primaryURL, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWrite(r) {
+			// 307 keeps the method and the body
+			http.Redirect(w, r, primaryURL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
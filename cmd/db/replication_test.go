@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+	check "gopkg.in/check.v1"
+)
+
+// wait for cond to hold while replication catches up
+func eventually(c *check.C, cond func() bool) {
+	for deadline := time.Now().Add(2 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			c.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *MySuite) TestReplication(c *check.C) {
+	primary := httptest.NewServer(s.router)
+	defer primary.Close()
+
+	// Given
+	c.Assert(s.db.Put("a", "1"), check.IsNil)
+	c.Assert(s.db.PutInt64("n", 2), check.IsNil)
+
+	replica, err := datastore.NewDb(c.MkDir())
+	c.Assert(err, check.IsNil)
+	defer replica.Close()
+	c.Assert(replica.Put("stale", "deleted on the primary"), check.IsNil)
+
+	// When
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		replicate(ctx, replica, primary.URL, "")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Then the snapshot is applied
+	eventually(c, func() bool {
+		_, err := replica.Get("stale")
+		return err == datastore.ErrNotFound
+	})
+	value, err := replica.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "1")
+	n, err := replica.GetInt64("n")
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, int64(2))
+
+	// Then live changes follow
+	c.Assert(s.db.PutWithTTL("t", "3", time.Minute), check.IsNil)
+	c.Assert(s.db.Delete("a"), check.IsNil)
+	eventually(c, func() bool {
+		_, err := replica.Get("a")
+		return err == datastore.ErrNotFound
+	})
+	value, err = replica.Get("t")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "3")
+	ttl, err := replica.TTL("t")
+	c.Assert(err, check.IsNil)
+	c.Assert(ttl > 0 && ttl <= time.Minute, check.Equals, true)
+}
+
+func (s *MySuite) TestReplicationOfStreamedValues(c *check.C) {
+	primary := httptest.NewServer(s.router)
+	defer primary.Close()
+	replica, err := datastore.NewDb(c.MkDir())
+	c.Assert(err, check.IsNil)
+	defer replica.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		replicate(ctx, replica, primary.URL, "")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	c.Assert(s.db.Put("ready", "1"), check.IsNil)
+	eventually(c, func() bool {
+		_, err := replica.Get("ready")
+		return err == nil
+	})
+
+	// When a value is uploaded as a stream after the snapshot
+	value := strings.Repeat("0123456789", 10000)
+	req, err := http.NewRequest("POST", primary.URL+apiPrefix+"/db/large", strings.NewReader(value))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+
+	// Then the replica stores the value, not an empty one
+	eventually(c, func() bool {
+		got, err := replica.Get("large")
+		return err == nil && got == value
+	})
+}
+
+func (s *MySuite) TestReplicaRedirectsWrites(c *check.C) {
+	handler := replicaMiddleware("http://primary:8080/", s.router)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	// When
+	rec := serve("POST", "/db/key?ttl=60")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusTemporaryRedirect)
+	c.Assert(rec.Header().Get("Location"), check.Equals, "http://primary:8080/db/key?ttl=60")
+	c.Assert(serve("DELETE", "/db/key").Code, check.Equals, http.StatusTemporaryRedirect)
+	c.Assert(serve("GET", "/db/key").Code, check.Equals, http.StatusNotFound)
+	c.Assert(serve("POST", "/db/_mget").Code, check.Equals, http.StatusBadRequest)
+}
+
+func (s *MySuite) TestReadOnly(c *check.C) {
+	c.Assert(s.db.Put("key", "value"), check.IsNil)
+	handler := readOnlyMiddleware(s.router)
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(`{"value": "changed"}`)))
+		return rec
+	}
+
+	// When
+	rec := serve("POST", "/db/key")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusMethodNotAllowed)
+	c.Assert(rec.Header().Get("Allow"), check.Equals, "GET, HEAD")
+	c.Assert(serve("DELETE", "/db/key").Code, check.Equals, http.StatusMethodNotAllowed)
+	c.Assert(serve("POST", "/admin/import").Code, check.Equals, http.StatusMethodNotAllowed)
+	// compaction rewrites the segment files
+	c.Assert(serve("POST", "/admin/compact").Code, check.Equals, http.StatusMethodNotAllowed)
+	c.Assert(serve("POST", "/db/_mget").Code, check.Not(check.Equals), http.StatusMethodNotAllowed)
+	c.Assert(serve("GET", "/db/key").Code, check.Equals, http.StatusOK)
+
+	value, err := s.db.Get("key")
+	c.Assert(err, check.IsNil)
+	c.Assert(value, check.Equals, "value")
+}
+
+func (s *MySuite) TestReplicationOutlivesWriteTimeout(c *check.C) {
+	primary := httptest.NewUnstartedServer(loggingMiddleware(&requestLogger{level: levelOff}, s.router))
+	primary.Config.WriteTimeout = 100 * time.Millisecond
+	primary.Start()
+	defer primary.Close()
+
+	// Given
+	resp, err := http.Get(primary.URL + apiPrefix + "/admin/replication")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+
+	// When a change comes after the write timeout passed
+	time.Sleep(300 * time.Millisecond)
+	c.Assert(s.db.Put("late", "1"), check.IsNil)
+
+	// Then it is still streamed after the end of the snapshot
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		c.Assert(err, check.IsNil)
+		if strings.Contains(line, `"key":"late"`) {
+			break
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+	"github.com/mikhmol/Architecture_Lab4/hashring"
+)
+
+const (
+	roleProxy = "proxy"
+
+	// time a node has to answer a health check
+	shardHealthTimeout = 2 * time.Second
+)
+
+// names under /db that span all keys, so no single shard can answer them
+var reservedKeys = map[string]bool{
+	"_bulk":   true,
+	"_mget":   true,
+	"_search": true,
+}
+
+// routes /db/{key} requests to the backend owning the key, nodes failing
+// health checks are taken off the ring until they recover. Whenever the ring
+// changes, keys stored on a live node that no longer owns them are moved to their
+// owner. Keys of a node that is down cannot be read until it comes back, and a
+// delete made meanwhile misses the copy it still holds.
+type shardProxy struct {
+	nodes   []string
+	apiKey  string // sent to the nodes when keys are moved
+	proxies map[string]*httputil.ReverseProxy
+	client  *http.Client
+	router  *mux.Router
+
+	mu      sync.RWMutex
+	healthy map[string]bool
+	ring    *hashring.Ring
+}
+
+// nodes are base URLs of the backends, e.g. http://db1:8080
+func newShardProxy(nodes []string, apiKey string) (*shardProxy, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no shard nodes given")
+	}
+	p := &shardProxy{
+		nodes:   nodes,
+		apiKey:  apiKey,
+		proxies: make(map[string]*httputil.ReverseProxy, len(nodes)),
+		client:  &http.Client{Timeout: shardHealthTimeout},
+		healthy: make(map[string]bool, len(nodes)),
+	}
+	for _, node := range nodes {
+		u, err := url.Parse(node)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid shard node %q", node)
+		}
+		proxy := httputil.NewSingleHostReverseProxy(u)
+		proxy.FlushInterval = -1 // pass watch events through as they come
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Error forwarding to shard %s: %s", u.Host, err)
+			jsonError(w, "shard is unavailable", http.StatusBadGateway)
+		}
+		p.proxies[node] = proxy
+		p.healthy[node] = true
+	}
+	p.ring = newShardRing(nodes)
+
+	p.router = mux.NewRouter()
+	p.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, "not found", http.StatusNotFound)
+	})
+	p.router.HandleFunc(apiPrefix+"/db/{key}", p.forward)
+	p.router.HandleFunc(apiPrefix+"/db/{key}/watch", p.forward)
+	return p, nil
+}
+
+func (p *shardProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	legacyPathMiddleware(p.router).ServeHTTP(w, r)
+}
+
+func (p *shardProxy) forward(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	if reservedKeys[key] {
+		jsonError(w, key+" is not supported by the shard proxy", http.StatusNotImplemented)
+		return
+	}
+	node := p.nodeFor(key)
+	if node == "" {
+		jsonError(w, "no healthy shards", http.StatusServiceUnavailable)
+		return
+	}
+	p.proxies[node].ServeHTTP(w, r)
+}
+
+// every node weighs the same on the ring
+func newShardRing(nodes []string) *hashring.Ring {
+	weighted := make([]hashring.Node, len(nodes))
+	for i, node := range nodes {
+		weighted[i] = hashring.Node{Name: node, Weight: 1}
+	}
+	return hashring.New(weighted)
+}
+
+func (p *shardProxy) nodeFor(key string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.ring.Get(key)
+}
+
+// check the nodes every interval until ctx is done
+func (p *shardProxy) watchHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.checkHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rebuild the ring from the nodes answering their health check when any of them changed
+// state, then move the keys to the nodes owning them on the new ring
+func (p *shardProxy) checkHealth(ctx context.Context) {
+	healthy := make(map[string]bool, len(p.nodes))
+	for _, node := range p.nodes {
+		healthy[node] = p.nodeHealthy(ctx, node)
+	}
+
+	p.mu.Lock()
+	changed := false
+	for node, ok := range healthy {
+		if p.healthy[node] != ok {
+			log.Printf("Shard %s healthy: %t", node, ok)
+			changed = true
+		}
+	}
+	if !changed {
+		p.mu.Unlock()
+		return
+	}
+	p.healthy = healthy
+	var live []string
+	for _, node := range p.nodes {
+		if healthy[node] {
+			live = append(live, node)
+		}
+	}
+	ring := newShardRing(live)
+	p.ring = ring
+	p.mu.Unlock()
+
+	for _, node := range live {
+		moved, err := p.moveKeys(ctx, ring, node)
+		if err != nil {
+			log.Printf("Error moving keys off shard %s: %s", node, err)
+		}
+		if moved > 0 {
+			log.Printf("Moved %d keys off shard %s", moved, node)
+		}
+	}
+}
+
+// export the keys of the node, import those owned by other nodes on the ring into
+// their owner and delete them from the node. A write reaching the owner while its
+// key is moved may be overwritten by the moved value.
+func (p *shardProxy) moveKeys(ctx context.Context, ring *hashring.Ring, node string) (int, error) {
+	resp, err := p.callNode(ctx, "GET", node+apiPrefix+"/admin/export", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("export answered %s", resp.Status)
+	}
+
+	records := make(map[string]*bytes.Buffer)
+	keys := make(map[string][]string)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), datastore.TenMegabytes)
+	for scanner.Scan() {
+		var rec struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return 0, err
+		}
+		owner := ring.Get(rec.Key)
+		if owner == node || owner == "" {
+			continue
+		}
+		if records[owner] == nil {
+			records[owner] = new(bytes.Buffer)
+		}
+		records[owner].Write(scanner.Bytes())
+		records[owner].WriteByte('\n')
+		keys[owner] = append(keys[owner], rec.Key)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	moved := 0
+	for owner, body := range records {
+		resp, err := p.callNode(ctx, "POST", owner+apiPrefix+"/admin/import", body)
+		if err != nil {
+			return moved, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return moved, fmt.Errorf("import into %s answered %s", owner, resp.Status)
+		}
+		// the copies left on the node would come back if it owned the keys again
+		for _, key := range keys[owner] {
+			resp, err := p.callNode(ctx, "DELETE", node+apiPrefix+"/db/"+escapeKey(key), nil)
+			if err != nil {
+				return moved, err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+				return moved, fmt.Errorf("deleting %s answered %s", key, resp.Status)
+			}
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+// call a node on behalf of the proxy itself, with no time limit but the one of ctx
+func (p *shardProxy) callNode(ctx context.Context, method, target string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("content-type", contentTypeJSONLines)
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// path of a stored key, values of buckets are stored under bucket/key
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (p *shardProxy) nodeHealthy(ctx context.Context, node string) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", node+"/ready", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestShardProxy(c *check.C) {
+	// Given
+	var nodes []string
+	var dbs []*datastore.Db
+	for i := 0; i < 2; i++ {
+		db, err := datastore.NewDb(c.MkDir())
+		c.Assert(err, check.IsNil)
+		defer db.Close()
+		server := httptest.NewServer(newRootHandler(openGate(newRouter(db))))
+		defer server.Close()
+		nodes = append(nodes, server.URL)
+		dbs = append(dbs, db)
+	}
+	// a node that is down is taken off the ring
+	dead := "http://127.0.0.1:1"
+	proxy, err := newShardProxy(append(nodes, dead), "")
+	c.Assert(err, check.IsNil)
+	proxy.checkHealth(context.Background())
+	c.Assert(proxy.healthy[dead], check.Equals, false)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	// When
+	for i := 0; i < 20; i++ {
+		c.Assert(do("PUT", fmt.Sprintf("/db/key-%d", i), `{"value": "value"}`).Code, check.Equals, http.StatusCreated)
+	}
+
+	// Then
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		rec := do("GET", "/db/"+key, "")
+		c.Assert(rec.Code, check.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), check.Equals, `{"key":"`+key+`","value":"value"}`+"\n")
+		owner := dbs[0]
+		if proxy.nodeFor(key) == nodes[1] {
+			owner = dbs[1]
+		}
+		c.Assert(owner.Has(key), check.Equals, true)
+	}
+	c.Assert(do("POST", "/db/_mget", `{"keys": []}`).Code, check.Equals, http.StatusNotImplemented)
+	c.Assert(do("GET", "/admin/stats", "").Code, check.Equals, http.StatusNotFound)
+
+}
+
+func (s *MySuite) TestShardProxyMovesKeys(c *check.C) {
+	// Given two nodes up and a third one still starting
+	var nodes []string
+	var dbs []*datastore.Db
+	var gates []*gate
+	for i := 0; i < 3; i++ {
+		db, err := datastore.NewDb(c.MkDir())
+		c.Assert(err, check.IsNil)
+		defer db.Close()
+		g := new(gate)
+		if i < 2 {
+			g.open(newRouter(db))
+		}
+		server := httptest.NewServer(newRootHandler(g))
+		defer server.Close()
+		nodes = append(nodes, server.URL)
+		dbs = append(dbs, db)
+		gates = append(gates, g)
+	}
+	proxy, err := newShardProxy(nodes, "")
+	c.Assert(err, check.IsNil)
+	proxy.checkHealth(context.Background())
+	c.Assert(proxy.healthy[nodes[2]], check.Equals, false)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	for i := 0; i < 30; i++ {
+		c.Assert(do("PUT", fmt.Sprintf("/db/key-%d", i), `{"value": "value"}`).Code, check.Equals, http.StatusCreated)
+	}
+
+	// When the third node comes up
+	gates[2].open(newRouter(dbs[2]))
+	proxy.checkHealth(context.Background())
+
+	// Then the keys it owns are moved to it and stay readable
+	movedKeys := 0
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		rec := do("GET", "/db/"+key, "")
+		c.Assert(rec.Code, check.Equals, http.StatusOK, check.Commentf("%s", key))
+		for j, db := range dbs {
+			owner := proxy.nodeFor(key) == nodes[j]
+			c.Assert(db.Has(key), check.Equals, owner, check.Commentf("%s on node %d", key, j))
+			if owner && j == 2 {
+				movedKeys++
+			}
+		}
+	}
+	c.Assert(movedKeys > 0, check.Equals, true)
+}
+
+func openGate(h http.Handler) *gate {
+	g := new(gate)
+	g.open(h)
+	return g
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+// response of a streamed upload
+type StreamResult struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// let a long-lived response stream past the WriteTimeout of the server
+func clearWriteDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		log.Printf("Failed to clear write deadline: %s", err)
+	}
+}
+
+func isOctetStream(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == contentTypeOctetStream
+}
+
+// store the raw request body as the value. The body is spooled to a temporary file
+// first so that slow or chunked uploads do not hold the database lock.
+func uploadValue(w http.ResponseWriter, r *http.Request, db *datastore.Db, key string) {
+	spool, err := ioutil.TempFile("", "db-upload")
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, r.Body)
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := db.PutStream(key, spool, size); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResponse(w, r, http.StatusOK, StreamResult{Key: key, Size: size})
+}
+
+// write the raw value as the response body without loading it into memory
+func downloadValue(w http.ResponseWriter, r *http.Request, db *datastore.Db, key string) {
+	value, size, err := db.GetStream(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer value.Close()
+
+	w.Header().Set("content-type", contentTypeOctetStream)
+	w.Header().Set("content-length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, value)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestStreamValue(c *check.C) {
+	value := strings.Repeat("0123456789", 100000)
+
+	// When the body is uploaded without a declared length
+	req := httptest.NewRequest("POST", "/db/large", io.MultiReader(strings.NewReader(value)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/octet-stream")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var result StreamResult
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &result), check.IsNil)
+	c.Assert(result, check.Equals, StreamResult{Key: "large", Size: int64(len(value))})
+
+	// When
+	req = httptest.NewRequest("GET", "/db/large", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("content-type"), check.Equals, "application/octet-stream")
+	c.Assert(rec.Body.String() == value, check.Equals, true)
+
+	// the value can be read as JSON as well
+	rec = s.do("GET", "/db/large", "")
+	var kv KeyValue
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &kv), check.IsNil)
+	c.Assert(kv.Value == value, check.Equals, true)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// report whether the request streams for as long as the client stays connected
+func isLongLived(r *http.Request) bool {
+	path := canonicalPath(r.URL.Path)
+	return path == apiPrefix+"/watch" || strings.HasSuffix(path, "/watch") ||
+		path == apiPrefix+"/admin/export" || path == apiPrefix+"/admin/replication"
+}
+
+// answer requests still without a response after timeout with 504 and cancel their context,
+// a non-positive timeout disables the check. Responses already started are left to finish.
+func timeoutMiddleware(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isLongLived(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case p := <-panicked:
+			panic(p)
+		case <-ctx.Done():
+			if !tw.timeout() {
+				// the handler is already writing its response
+				select {
+				case <-done:
+				case p := <-panicked:
+					panic(p)
+				}
+				return
+			}
+			jsonError(w, fmt.Sprintf("request not completed within %s", timeout), http.StatusGatewayTimeout)
+		}
+	})
+}
+
+// passes the response through until the request times out, writes after that fail
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.wroteHeader || tw.timedOut {
+		return
+	}
+	tw.wroteHeader = true
+	for name, values := range tw.header {
+		tw.w.Header()[name] = values
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(data)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// mark the request as timed out unless the response has started
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.wroteHeader {
+		return false
+	}
+	tw.timedOut = true
+	return true
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestTimeout(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	defer close(release)
+	canceled := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := timeoutMiddleware(10*time.Millisecond, slow)
+
+	// When
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/db/key", nil))
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusGatewayTimeout)
+	c.Assert(rec.Body.String(), check.Matches, `(?s)\{"error":\{"code":"gateway_timeout",.*`)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		c.Fatal("request context was not canceled")
+	}
+
+	// fast requests pass through
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/db/key", nil)
+	timeoutMiddleware(time.Second, s.router).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `{"key":"key","value":"value"}`+"\n")
+}
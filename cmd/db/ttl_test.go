@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestTTL(c *check.C) {
+	// When
+	rec := s.do("POST", "/db/session?ttl=90s", `{"value": "token"}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	rec = s.do("GET", "/db/session", "")
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("X-TTL"), check.Equals, "90")
+	var kv KeyValue
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &kv), check.IsNil)
+	c.Assert(kv.TTL, check.Equals, int64(90))
+
+	// When the TTL is given in a header
+	req := httptest.NewRequest("POST", "/db/header", strings.NewReader(`{"value": "value"}`))
+	req.Header.Set("X-TTL", "30")
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("GET", "/db/header", "").Header().Get("X-TTL"), check.Equals, "30")
+
+	// Then values without a TTL report none
+	c.Assert(s.do("POST", "/db/permanent", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+	rec = s.do("GET", "/db/permanent", "")
+	c.Assert(rec.Header().Get("X-TTL"), check.Equals, "")
+	c.Assert(strings.Contains(rec.Body.String(), "ttl"), check.Equals, false)
+
+	c.Assert(s.do("POST", "/db/bad?ttl=soon", `{"value": "value"}`).Code, check.Equals, http.StatusBadRequest)
+	c.Assert(s.do("POST", "/db/bad?ttl=-1", `{"value": "value"}`).Code, check.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// prefix of the current API, all endpoints except health probes live under it
+const apiPrefix = "/api/v1"
+
+// paths served before the API was versioned
+var legacyPrefixes = []string{"/db", "/watch", "/admin"}
+
+// path under apiPrefix serving the given path, unchanged when it is not a legacy path
+func canonicalPath(path string) string {
+	for _, prefix := range legacyPrefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return apiPrefix + path
+		}
+	}
+	return path
+}
+
+// serve legacy paths such as /db/{key} by the handlers of the current API version
+func legacyPathMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := canonicalPath(r.URL.Path)
+		if path == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = path
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = canonicalPath(r.URL.RawPath)
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestVersionedPaths(c *check.C) {
+	// Given
+	c.Assert(s.do("PUT", "/api/v1/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusCreated)
+
+	// Then
+	for _, target := range []string{"/api/v1/db/key", "/db/key"} {
+		rec := s.do("GET", target, "")
+		c.Assert(rec.Code, check.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), check.Equals, `{"key":"key","value":"value"}`+"\n")
+	}
+	c.Assert(s.do("GET", "/api/v1/db", "").Body.String(), check.Equals, `{"items":[{"key":"key"}]}`+"\n")
+	c.Assert(s.do("GET", "/api/v1/admin/stats", "").Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("GET", "/api/v2/db/key", "").Code, check.Equals, http.StatusNotFound)
+	c.Assert(s.do("GET", "/dbx", "").Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *MySuite) TestCanonicalPath(c *check.C) {
+	c.Assert(canonicalPath("/db/key"), check.Equals, "/api/v1/db/key")
+	c.Assert(canonicalPath("/db"), check.Equals, "/api/v1/db")
+	c.Assert(canonicalPath("/watch"), check.Equals, "/api/v1/watch")
+	c.Assert(canonicalPath("/api/v1/db/key"), check.Equals, "/api/v1/db/key")
+	c.Assert(canonicalPath("/health"), check.Equals, "/health")
+	c.Assert(canonicalPath("/dbx"), check.Equals, "/dbx")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mikhmol/Architecture_Lab4/datastore"
+)
+
+// stream changes of keys with the prefix (or of the single key when exact is set)
+// as Server-Sent Events until the client disconnects
+func serveWatch(w http.ResponseWriter, r *http.Request, db *datastore.Db, prefix string, exact bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	clearWriteDeadline(w)
+	events, cancel := db.Watch(prefix)
+	defer cancel()
+
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // the watcher fell behind or the database was closed, the client reconnects
+			}
+			if exact && event.Key != prefix {
+				continue
+			}
+			name := "put"
+			if event.Deleted {
+				name = "delete"
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
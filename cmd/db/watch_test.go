@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestWatch(c *check.C) {
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/db/key/watch")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("content-type"), check.Equals, "text/event-stream")
+
+	// When
+	c.Assert(s.do("POST", "/db/other", `{"value": "ignored"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("POST", "/db/key", `{"value": "value"}`).Code, check.Equals, http.StatusOK)
+	c.Assert(s.do("DELETE", "/db/key", "").Code, check.Equals, http.StatusNoContent)
+
+	// Then
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			c.Assert(err, check.IsNil)
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+	c.Assert(readEvent(), check.Equals, "event: put\ndata: {\"key\":\"key\",\"value\":\"value\"}\n")
+	c.Assert(readEvent(), check.Equals, "event: delete\ndata: {\"key\":\"key\",\"deleted\":true}\n")
+}
+
+func (s *MySuite) TestWatchOutlivesWriteTimeout(c *check.C) {
+	server := httptest.NewUnstartedServer(loggingMiddleware(&requestLogger{level: levelOff}, s.router))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/db/key/watch")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+
+	// When a change comes after the write timeout passed
+	time.Sleep(300 * time.Millisecond)
+	c.Assert(s.do("POST", "/db/key", `{"value": "late"}`).Code, check.Equals, http.StatusOK)
+
+	// Then it is still streamed
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	c.Assert(err, check.IsNil)
+	c.Assert(line, check.Equals, "event: put\n")
+}
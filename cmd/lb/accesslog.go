@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+	"github.com/mikhmol/Architecture_Lab4/logging"
+)
+
+// one line per proxied request
+type accessEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"requestId"`
+	Client     string  `json:"client"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Backend    string  `json:"backend"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+type accessLogger struct {
+	format string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// set from -log-format once flags are parsed
+var accessLog = &accessLogger{format: logging.FormatText, out: os.Stderr}
+
+// set from -log-format and -log-level once flags are parsed
+var events = logging.Default()
+
+func newAccessLogger(format string, out io.Writer) (*accessLogger, error) {
+	if format != logging.FormatText && format != logging.FormatJSON {
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	return &accessLogger{format: format, out: out}, nil
+}
+
+// log the request answered by backend with status after bytes of the response were written
+func (l *accessLogger) log(r *http.Request, backend string, status int, bytes int64, start time.Time) {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	entry := accessEntry{
+		Time:       start.UTC().Format(time.RFC3339Nano),
+		RequestID:  r.Header.Get(httptools.RequestIDHeader),
+		Client:     client,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Backend:    backend,
+		Status:     status,
+		Bytes:      bytes,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	var line []byte
+	if l.format == logging.FormatJSON {
+		if line, err = json.Marshal(entry); err != nil {
+			return
+		}
+	} else {
+		line = []byte(fmt.Sprintf("time=%s requestId=%s client=%s method=%s path=%q backend=%s status=%d bytes=%d durationMs=%g",
+			entry.Time, entry.RequestID, entry.Client, entry.Method, entry.Path, entry.Backend, entry.Status, entry.Bytes, entry.DurationMs))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(append(line, '\n'))
+}
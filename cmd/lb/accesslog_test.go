@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+	"github.com/mikhmol/Architecture_Lab4/logging"
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestAccessLog(c *check.C) {
+	// Given
+	var text, jsonOut bytes.Buffer
+	textLog, err := newAccessLogger(logging.FormatText, &text)
+	c.Assert(err, check.IsNil)
+	jsonLog, err := newAccessLogger(logging.FormatJSON, &jsonOut)
+	c.Assert(err, check.IsNil)
+	r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+	r.RemoteAddr = "10.0.0.7:51234"
+	r.Header.Set(httptools.RequestIDHeader, "abc123")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// When
+	textLog.log(r, "server1:8080", 200, 42, start)
+	jsonLog.log(r, "server1:8080", 200, 42, start)
+
+	// Then
+	c.Assert(text.String(), check.Matches,
+		`time=2023-05-01T10:00:00Z requestId=abc123 client=10.0.0.7 method=GET path="/api/v1/some-data" backend=server1:8080 status=200 bytes=42 durationMs=[0-9.e+]+\n`)
+	var entry accessEntry
+	c.Assert(json.Unmarshal(jsonOut.Bytes(), &entry), check.IsNil)
+	entry.DurationMs = 0
+	c.Assert(entry, check.Equals, accessEntry{
+		Time:      "2023-05-01T10:00:00Z",
+		RequestID: "abc123",
+		Client:    "10.0.0.7",
+		Method:    "GET",
+		Path:      "/api/v1/some-data",
+		Backend:   "server1:8080",
+		Status:    200,
+		Bytes:     42,
+	})
+
+	_, err = newAccessLogger("xml", &text)
+	c.Assert(err, check.NotNil)
+}
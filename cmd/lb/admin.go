@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	adminBackendsPath = "/admin/backends"
+	adminCanaryPath   = "/admin/canary"
+	adminReloadPath   = "/admin/reload"
+	adminLivePath     = "/admin/live"
+)
+
+// body of POST /admin/backends
+type BackendRequest struct {
+	Addr   string `json:"addr"`
+	Weight int    `json:"weight,omitempty"` // 1 when omitted
+	Canary bool   `json:"canary,omitempty"` // add to the canary pool
+	Color  string `json:"color,omitempty"`  // add to the blue or green pool
+}
+
+// body of PUT /admin/live and its answers
+type LiveRequest struct {
+	Color string `json:"color"` // blue or green
+}
+
+// body of PATCH /admin/backends/{host}
+type WeightRequest struct {
+	Weight *int `json:"weight"` // 0 drains the backend
+}
+
+// admin API managing the pool at runtime and exposing /metrics, served on its own port
+func newAdminHandler(lb *LoadBalancer) http.Handler {
+	h := new(http.ServeMux)
+	h.HandleFunc(adminBackendsPath, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(rw, http.StatusOK, lb.listBackends())
+		case http.MethodPost:
+			var req BackendRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			backend, err := parseBackend(strings.TrimSpace(req.Addr))
+			if err != nil || backend.Addr == "" || req.Weight < 0 {
+				http.Error(rw, "addr must be host:port and weight must not be negative", http.StatusBadRequest)
+				return
+			}
+			if req.Weight > 0 {
+				backend.Weight = req.Weight
+			}
+			if req.Color != "" && !validColor(req.Color) {
+				http.Error(rw, "color must be blue or green", http.StatusBadRequest)
+				return
+			}
+			if err := lb.addToPool(backend, req.Canary, req.Color); err != nil {
+				http.Error(rw, err.Error(), http.StatusConflict)
+				return
+			}
+			log.Printf("Backend %s added", backend.Addr)
+			writeJSON(rw, http.StatusCreated, backend)
+		default:
+			rw.Header().Set("Allow", "GET, POST")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	h.HandleFunc(adminBackendsPath+"/", func(rw http.ResponseWriter, r *http.Request) {
+		addr := strings.TrimPrefix(r.URL.Path, adminBackendsPath+"/")
+		switch r.Method {
+		case http.MethodPatch:
+			var req WeightRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Weight == nil || *req.Weight < 0 {
+				http.Error(rw, "weight must be given and not be negative", http.StatusBadRequest)
+				return
+			}
+			if !lb.updateWeight(addr, *req.Weight) {
+				http.Error(rw, "backend "+addr+" is not in the pool", http.StatusNotFound)
+				return
+			}
+			log.Printf("Weight of backend %s set to %d", addr, *req.Weight)
+			writeJSON(rw, http.StatusOK, Backend{Addr: addr, Weight: *req.Weight})
+		case http.MethodDelete:
+			if !lb.removeBackend(addr) {
+				http.Error(rw, "backend "+addr+" is not in the pool", http.StatusNotFound)
+				return
+			}
+			log.Printf("Backend %s removed", addr)
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			rw.Header().Set("Allow", "PATCH, DELETE")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	h.HandleFunc(adminCanaryPath, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(rw, http.StatusOK, canary.list())
+		case http.MethodPut:
+			var route CanaryRoute
+			if err := json.NewDecoder(r.Body).Decode(&route); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !strings.HasPrefix(route.Prefix, "/") || !validPercent(route.Percent) {
+				http.Error(rw, "prefix must start with / and percent must be from 0 to 100", http.StatusBadRequest)
+				return
+			}
+			canary.set(route)
+			log.Printf("Canary share of %s set to %g%%", route.Prefix, route.Percent)
+			writeJSON(rw, http.StatusOK, canary.list())
+		default:
+			rw.Header().Set("Allow", "GET, PUT")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	h.HandleFunc(adminReloadPath, func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", "POST")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := lb.reload(); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(rw, http.StatusOK, lb.listBackends())
+	})
+	h.HandleFunc(adminLivePath, func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(rw, http.StatusOK, LiveRequest{Color: lb.currentLive()})
+		case http.MethodPut:
+			var req LiveRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := lb.switchLive(req.Color); err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Traffic switched to the %s pool", req.Color)
+			writeJSON(rw, http.StatusOK, req)
+		default:
+			rw.Header().Set("Allow", "GET, PUT")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	h.Handle("/metrics", lb.metrics)
+	return h
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
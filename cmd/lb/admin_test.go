@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestAdminBackends(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	lb := newTestLoadBalancer()
+	admin := newAdminHandler(lb)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	get := func() int {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+		return rec.Code
+	}
+
+	// When
+	rec := do("POST", "/admin/backends", `{"addr": "`+addr+`", "weight": 2}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	c.Assert(get(), check.Equals, http.StatusOK)
+	c.Assert(do("GET", "/admin/backends", "").Body.String(), check.Equals,
+		`[{"addr":"`+addr+`","weight":2,"healthy":true,"bytes":2,"active":0}]`+"\n")
+	c.Assert(do("POST", "/admin/backends", `{"addr": "`+addr+`"}`).Code, check.Equals, http.StatusConflict)
+	c.Assert(do("POST", "/admin/backends", `{"addr": "http://`+addr+`"}`).Code, check.Equals, http.StatusBadRequest)
+
+	c.Assert(do("DELETE", "/admin/backends/"+addr, "").Code, check.Equals, http.StatusNoContent)
+	c.Assert(get(), check.Equals, http.StatusServiceUnavailable)
+	c.Assert(do("DELETE", "/admin/backends/"+addr, "").Code, check.Equals, http.StatusNotFound)
+	c.Assert(do("GET", "/admin/backends", "").Body.String(), check.Equals, "[]\n")
+}
+
+func (s *MySuite) TestAdminWeight(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer()
+	lb.checker = HealthCheckFunc(func(ctx context.Context, server string) bool {
+		return true
+	})
+	c.Assert(lb.addBackend(Backend{Addr: "a:80", Weight: 1}), check.IsNil)
+	defer lb.removeBackend("a:80")
+	admin := newAdminHandler(lb)
+	patch := func(addr, body string) int {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("PATCH", "/admin/backends/"+addr, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	// When
+	c.Assert(patch("a:80", `{"weight": 3}`), check.Equals, http.StatusOK)
+
+	// Then
+	c.Assert(lb.getHealthyBackends(), check.DeepEquals, []Backend{{"a:80", 3}})
+
+	// When the backend is drained
+	c.Assert(patch("a:80", `{"weight": 0}`), check.Equals, http.StatusOK)
+
+	// Then it gets no requests but stays in the pool
+	c.Assert(lb.getHealthyBackends(), check.HasLen, 0)
+	c.Assert(lb.listBackends(), check.DeepEquals, []BackendStatus{{Addr: "a:80", Weight: 0, Healthy: true}})
+
+	c.Assert(patch("a:80", `{}`), check.Equals, http.StatusBadRequest)
+	c.Assert(patch("a:80", `{"weight": -1}`), check.Equals, http.StatusBadRequest)
+	c.Assert(patch("b:80", `{"weight": 1}`), check.Equals, http.StatusNotFound)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pins clients to a server by IP
+const affinityIP = "ip"
+
+type pin struct {
+	server  string
+	expires time.Time
+}
+
+// remembers the server each client was sent to, pins expire ttl after the client's last request
+type affinityTable struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	pins      map[string]pin
+	lastSweep time.Time
+}
+
+func newAffinityTable(mode string, ttl time.Duration) (*affinityTable, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case affinityIP:
+		if ttl <= 0 {
+			return nil, fmt.Errorf("affinity TTL must be positive")
+		}
+		return &affinityTable{ttl: ttl, now: time.Now, pins: make(map[string]pin)}, nil
+	}
+	return nil, fmt.Errorf("unknown affinity %s", mode)
+}
+
+// server the client is pinned to if it is still among the healthy ones
+func (t *affinityTable) lookup(client string, healthy []Backend) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pins[client]
+	now := t.now()
+	if !ok || now.After(p.expires) {
+		return "", false
+	}
+	for _, b := range healthy {
+		if b.Addr == p.server {
+			t.pins[client] = pin{server: p.server, expires: now.Add(t.ttl)}
+			return p.server, true
+		}
+	}
+	return "", false
+}
+
+func (t *affinityTable) pin(client, server string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	t.pins[client] = pin{server: server, expires: now.Add(t.ttl)}
+
+	// drop clients that went away once per TTL
+	if now.Sub(t.lastSweep) < t.ttl {
+		return
+	}
+	t.lastSweep = now
+	for c, p := range t.pins {
+		if now.After(p.expires) {
+			delete(t.pins, c)
+		}
+	}
+}
+
+// original client address, taken from X-Forwarded-For when the balancer is behind a proxy
+func forwardedClient(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		if client := strings.TrimSpace(strings.Split(xff, ",")[0]); client != "" {
+			return client
+		}
+	}
+	return clientAddress(r)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http/httptest"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestAffinity(c *check.C) {
+	// Given
+	table, err := newAffinityTable(affinityIP, time.Minute)
+	c.Assert(err, check.IsNil)
+	now := time.Unix(1000, 0)
+	table.now = func() time.Time { return now }
+	healthy := []Backend{{"a:80", 1}, {"b:80", 1}}
+
+	// When
+	table.pin("192.0.2.1", "b:80")
+
+	// Then
+	server, ok := table.lookup("192.0.2.1", healthy)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(server, check.Equals, "b:80")
+	_, ok = table.lookup("192.0.2.2", healthy)
+	c.Assert(ok, check.Equals, false)
+	_, ok = table.lookup("192.0.2.1", healthy[:1])
+	c.Assert(ok, check.Equals, false)
+
+	// requests keep the pin alive
+	now = now.Add(50 * time.Second)
+	_, ok = table.lookup("192.0.2.1", healthy)
+	c.Assert(ok, check.Equals, true)
+	now = now.Add(50 * time.Second)
+	_, ok = table.lookup("192.0.2.1", healthy)
+	c.Assert(ok, check.Equals, true)
+	now = now.Add(2 * time.Minute)
+	_, ok = table.lookup("192.0.2.1", healthy)
+	c.Assert(ok, check.Equals, false)
+
+	table.pin("192.0.2.3", "a:80")
+	c.Assert(len(table.pins), check.Equals, 1)
+}
+
+func (s *MySuite) TestNewAffinityTable(c *check.C) {
+	table, err := newAffinityTable("", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(table, check.IsNil)
+	_, err = newAffinityTable("cookie", time.Minute)
+	c.Assert(err, check.NotNil)
+	_, err = newAffinityTable(affinityIP, 0)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *MySuite) TestForwardedClient(c *check.C) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	c.Assert(forwardedClient(r), check.Equals, "10.0.0.1")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	c.Assert(forwardedClient(r), check.Equals, "203.0.113.7")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync/atomic"
+)
+
+// Balancer picks the server a request is forwarded to. Pick is only called
+// with a non-empty list of healthy backends and may be called concurrently.
+type Balancer interface {
+	Pick(r *http.Request, healthy []Backend) Backend
+}
+
+const (
+	// the server that returned the fewest bytes recently
+	algorithmMinBytes = "min-bytes"
+	// servers in turn, each as many times in a row as its weight
+	algorithmRoundRobin = "round-robin"
+	// the server with the fewest requests in flight
+	algorithmLeastConn = "least-conn"
+	// the server the client IP hashes to, so clients stick to a server
+	algorithmHash = "hash"
+	// the server the request path hashes to, so responses are cached by one server
+	algorithmPathHash = "path-hash"
+)
+
+// the Balancer of the algorithm, weighing the load of the servers of pool
+func newBalancer(algorithm string, pool *LoadBalancer) (Balancer, error) {
+	switch algorithm {
+	case algorithmMinBytes:
+		return minBytesBalancer{pool}, nil
+	case algorithmRoundRobin:
+		return new(roundRobinBalancer), nil
+	case algorithmLeastConn:
+		return leastConnBalancer{pool}, nil
+	case algorithmHash:
+		return newHashBalancer(clientAddress), nil
+	case algorithmPathHash:
+		return newHashBalancer(requestPath), nil
+	case algorithmEWMA:
+		return newEWMABalancer(pool), nil
+	}
+	return nil, fmt.Errorf("unknown algorithm %s", algorithm)
+}
+
+// picks the server that returned the fewest bytes within -bytes-window per unit of weight
+type minBytesBalancer struct {
+	pool *LoadBalancer
+}
+
+func (mb minBytesBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	bytes := mb.pool.traffic.recentAt(mb.pool.now())
+	return minBy(healthy, func(b Backend) float64 {
+		return float64(bytes[b.Addr])
+	})
+}
+
+// picks the server with the fewest requests in flight per unit of weight
+type leastConnBalancer struct {
+	pool *LoadBalancer
+}
+
+func (lc leastConnBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	active := lc.pool.inFlight()
+	return minBy(healthy, func(b Backend) float64 {
+		return float64(active[b.Addr])
+	})
+}
+
+// backend with the lowest load divided by its weight, the first one on ties
+func minBy(backends []Backend, load func(Backend) float64) Backend {
+	var best Backend
+	bestLoad := math.Inf(1)
+	for _, b := range backends {
+		if weighted := load(b) / float64(b.Weight); weighted < bestLoad {
+			best, bestLoad = b, weighted
+		}
+	}
+	return best
+}
+
+// cycles through the servers, a server of weight n is picked n times per cycle
+type roundRobinBalancer struct {
+	next uint64
+}
+
+func (rr *roundRobinBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	total := 0
+	for _, b := range healthy {
+		total += b.Weight
+	}
+	n := int((atomic.AddUint64(&rr.next, 1) - 1) % uint64(total))
+	for _, b := range healthy {
+		if n < b.Weight {
+			return b
+		}
+		n -= b.Weight
+	}
+	return healthy[len(healthy)-1]
+}
+
+// get a healthy server which return minimal bytes per unit of weight, empty when none is healthy
+func (lb *LoadBalancer) getMinByteServer() string {
+	healthy := lb.getHealthyBackends()
+	if len(healthy) == 0 {
+		return ""
+	}
+	return minBytesBalancer{lb}.Pick(nil, healthy).Addr
+}
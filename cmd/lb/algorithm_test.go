@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestNewBalancer(c *check.C) {
+	for _, name := range []string{"min-bytes", "round-robin", "least-conn", "hash", "path-hash", "ewma"} {
+		b, err := newBalancer(name, newTestLoadBalancer())
+		c.Assert(err, check.IsNil)
+		c.Assert(b, check.NotNil)
+	}
+	_, err := newBalancer("random", newTestLoadBalancer())
+	c.Assert(err, check.NotNil)
+}
+
+func (s *MySuite) TestRoundRobin(c *check.C) {
+	// Given
+	healthy := []Backend{{"a:80", 1}, {"b:80", 2}, {"c:80", 1}}
+	balancer := new(roundRobinBalancer)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	// When
+	var picked []string
+	for i := 0; i < 8; i++ {
+		picked = append(picked, balancer.Pick(r, healthy).Addr)
+	}
+
+	// Then
+	c.Assert(picked, check.DeepEquals, []string{"a:80", "b:80", "b:80", "c:80", "a:80", "b:80", "b:80", "c:80"})
+	c.Assert(balancer.Pick(r, healthy[:1]).Addr, check.Equals, "a:80")
+}
+
+func (s *MySuite) TestLeastConn(c *check.C) {
+	// Given
+	healthy := []Backend{{"a:80", 1}, {"b:80", 1}, {"c:80", 3}}
+	lb := newTestLoadBalancer()
+	lb.active["a:80"] = 2
+	lb.active["b:80"] = 1
+	lb.active["c:80"] = 4
+
+	// When
+	picked := leastConnBalancer{lb}.Pick(httptest.NewRequest("GET", "/", nil), healthy)
+
+	// Then
+	c.Assert(picked.Addr, check.Equals, "b:80")
+
+	c.Assert(lb.reserve("b:80"), check.Equals, true)
+	c.Assert(leastConnBalancer{lb}.Pick(nil, healthy).Addr, check.Equals, "c:80")
+}
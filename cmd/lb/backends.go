@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// server requests are balanced to, a server with weight 3 gets three times
+// the traffic of a server with weight 1
+type Backend struct {
+	Addr   string
+	Weight int
+}
+
+// backends listed in the file, one host:port[=weight] per line, or else in the comma
+// separated list. Blank lines and lines starting with # are skipped.
+func loadBackends(list, file string) ([]Backend, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var backends []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				backends = append(backends, line)
+			}
+		}
+		list = strings.Join(backends, ",")
+	}
+
+	var backends []Backend
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		backend, err := parseBackend(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		if backend.Addr == "" || seen[backend.Addr] {
+			continue
+		}
+		seen[backend.Addr] = true
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("no backends configured")
+	}
+	return backends, nil
+}
+
+// parse host:port[=weight], the weight defaults to 1
+func parseBackend(s string) (Backend, error) {
+	backend := Backend{Addr: s, Weight: 1}
+	if i := strings.LastIndex(s, "="); i >= 0 {
+		weight, err := strconv.Atoi(s[i+1:])
+		if err != nil || weight <= 0 {
+			return backend, fmt.Errorf("weight of backend %q must be a positive integer", s)
+		}
+		backend.Addr, backend.Weight = s[:i], weight
+	}
+	if strings.Contains(backend.Addr, "://") || strings.Contains(backend.Addr, "/") {
+		return backend, fmt.Errorf("backend %q must be given as host:port", backend.Addr)
+	}
+	return backend, nil
+}
@@ -2,37 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"fmt"
 	"io"
 	"log"
-	"math"
+	"net"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/mikhmol/Architecture_Lab4/httptools"
+	"github.com/mikhmol/Architecture_Lab4/logging"
 	"github.com/mikhmol/Architecture_Lab4/signal"
 )
 
 var (
 	port       = flag.Int("port", 8090, "load balancer port")
-	timeoutSec = flag.Int("timeout-sec", 3, "request timeout time in seconds")
+	timeoutSec = flag.Int("timeout-sec", 3, "total time in seconds a request has to get its response, streams excepted")
+	routes     = flag.String("route-timeouts", "", "comma separated /prefix=duration pairs overriding -timeout-sec for matching paths")
 	https      = flag.Bool("https", false, "whether backends support HTTPs")
 
-	traceEnabled = flag.Bool("trace", false, "whether to include tracing information into responses")
+	tlsCert      = flag.String("tls-cert", "", "certificate file to serve HTTPS to clients with, requires -tls-key")
+	tlsKey       = flag.String("tls-key", "", "private key file of -tls-cert")
+	hsts         = flag.Duration("hsts", 0, "max-age of the Strict-Transport-Security header sent over HTTPS, 0 disables it")
+	redirectPort = flag.Int("http-redirect-port", 0, "port redirecting plain HTTP requests to HTTPS, 0 disables it")
+
+	maxIdleConnsPerHost = flag.Int("max-idle-conns-per-host", 32, "idle connections kept open to each server")
+	dialTimeout         = flag.Duration("dial-timeout", 2*time.Second, "time to connect to a server, including the TLS handshake")
+	headerTimeout       = flag.Duration("header-timeout", 0, "time a server has to send the response headers once the request is written, 0 leaves it to -timeout-sec")
+	flushInterval       = flag.Duration("flush-interval", 0, "how often responses are flushed to clients while they are copied, negative flushes after every write; 0 only flushes responses of unknown length right away")
+	idleConnTimeout     = flag.Duration("idle-conn-timeout", 90*time.Second, "time an idle connection to a server is kept open")
+	backendCert         = flag.String("backend-cert", "", "client certificate the balancer presents to -https backends")
+	backendKey          = flag.String("backend-key", "", "private key file of -backend-cert")
+	backendCA           = flag.String("backend-ca", "", "CA bundle backend certificates are verified against instead of the system roots")
+
+	healthInterval = flag.Duration("health-interval", 10*time.Second, "time between health checks of each server")
+	healthPath     = flag.String("health-path", "/health", "path servers answer health checks on")
+	healthStatus   = flag.Int("health-status", http.StatusOK, "status code of a passing health check")
+	healthBody     = flag.String("health-body", "", "text the body of a passing health check contains, not checked when empty")
+	healthTimeout  = flag.Duration("health-timeout", time.Second, "time a server has to answer a health check")
+
+	backends       = flag.String("backends", "server1:8080,server2:8080,server3:8080", "comma separated host:port[=weight] of the servers to balance")
+	configFile     = flag.String("config", "", "JSON file with backends, canaryBackends, timeout, routeTimeouts and canaryRoutes overriding the flags, read again on SIGHUP")
+	backendsFile   = flag.String("backends-file", "", "file listing a host:port[=weight] per line, overrides -backends")
+	canaryBackends = flag.String("canary-backends", "", "comma separated host:port[=weight] of the canary servers")
+	blueBackends   = flag.String("blue-backends", "", "comma separated host:port[=weight] of the blue pool, which gets traffic while it is live; pass -backends= to balance only blue and green")
+	greenBackends  = flag.String("green-backends", "", "comma separated host:port[=weight] of the green pool, which gets traffic while it is live")
+	livePool       = flag.String("live", colorBlue, "pool of -blue-backends and -green-backends getting traffic at start: blue or green")
+	canaryRoutes   = flag.String("canary-routes", "", "comma separated /prefix=percent pairs of the traffic sent to the canary servers")
+	discoverDNS    = flag.String("discover-dns", "", "host:port or SRV name resolved periodically to find the servers, replaces -backends")
+	discoverDocker = flag.String("discover-docker-label", "", "label of the running containers to balance, its value is their port; replaces -backends")
+	dockerHost     = flag.String("docker-host", "unix:///var/run/docker.sock", "address of the Docker API used by -discover-docker-label")
+	dockerNetwork  = flag.String("docker-network", "", "network of the container addresses, any network when empty")
+	discoverEvery  = flag.Duration("discovery-interval", 30*time.Second, "time between discoveries of the servers")
+
+	algorithm        = flag.String("algorithm", algorithmMinBytes, "how a server is picked: min-bytes, round-robin, least-conn, hash, path-hash or ewma")
+	affinityMode     = flag.String("affinity", "", "pin clients to a server: ip, or empty to balance every request")
+	affinityTTL      = flag.Duration("affinity-ttl", 10*time.Minute, "time a client stays pinned after its last request")
+	adminPort        = flag.Int("admin-port", 0, "port of the admin API adding and removing backends at runtime and serving /metrics, 0 disables it")
+	maxInflight      = flag.Int("max-inflight", 0, "requests forwarded at once, 0 means no limit")
+	maxQueue         = flag.Int("max-queue", 16, "requests over -max-inflight waiting for a slot before the rest get 503")
+	queueTimeout     = flag.Duration("queue-timeout", time.Second, "time a request waits for a slot under -max-inflight")
+	retryBudgetPct   = flag.Float64("retry-budget", 20, "percentage of the requests that may be retried on another server")
+	retryBackoff     = flag.Duration("retry-backoff", 25*time.Millisecond, "longest wait before the first retry, doubled for each further one")
+	retryBackoffMax  = flag.Duration("retry-backoff-max", time.Second, "longest wait before any retry")
+	maxPerServer     = flag.Int("max-per-backend", 0, "requests in flight to a single server, busier servers are skipped; 0 means no limit")
+	mirrorAddr       = flag.String("mirror", "", "host:port of a shadow server getting copies of requests, its answers are discarded")
+	mirrorPercent    = flag.Float64("mirror-percent", 100, "percentage of the requests copied to -mirror")
+	mirrorMaxBody    = flag.Int64("mirror-max-body", 1<<20, "largest request body in bytes copied to -mirror, larger requests are not mirrored")
+	outlierThreshold = flag.Float64("outlier-threshold", 0, "share of failed requests from 0 to 1 that ejects a server, 0 disables ejection")
+	outlierWindow    = flag.Duration("outlier-window", 30*time.Second, "time over which the failed requests of a server are counted")
+	outlierMin       = flag.Int("outlier-min-requests", 10, "requests a server must get within -outlier-window before it may be ejected")
+	outlierEjection  = flag.Duration("outlier-ejection", 30*time.Second, "time an ejected server gets no requests")
+	maxBodyBytes     = flag.Int64("max-body-bytes", 0, "largest request body in bytes forwarded, larger ones get 413; 0 means no limit")
+	bytesWindow      = flag.Duration("bytes-window", defaultTrafficWindow, "time over which the bytes of the min-bytes algorithm are counted")
+	shutdownTimeout  = flag.Duration("shutdown-timeout", 10*time.Second, "time given to in-flight requests to finish on shutdown")
+	logFormat        = flag.String("log-format", logging.FormatText, "format of the access log and event lines: text or json")
+	logLevel         = flag.String("log-level", "info", "least severe events logged: debug, info, warn or error")
+	traceEnabled     = flag.Bool("trace", false, "whether to include tracing information into responses")
 )
 
 var (
-	timeout     = time.Duration(*timeoutSec) * time.Second
-	serversPool = []string{
-		"server1:8080",
-		"server2:8080",
-		"server3:8080",
-	}
+	// set from -timeout-sec once flags are parsed, guarded by timeoutMu
+	timeout       = time.Duration(*timeoutSec) * time.Second
+	routeTimeouts []routeTimeout
+	timeoutMu     sync.Mutex
+	affinity      *affinityTable
+	limiter       *inflightLimiter
 )
 
-// keep track of the total number of bytes returned by each server
-var serverBytes = make(map[string]int64)
+// LoadBalancer forwards requests to the healthy servers of its pool and keeps their
+// health, weights, requests in flight and traffic. The health checker, clock and
+// client may be replaced before it serves, tests do so to run without real servers.
+type LoadBalancer struct {
+	checker  HealthChecker
+	now      func() time.Time
+	client   *http.Client
+	traffic  *TrafficCounter
+	metrics  *Metrics
+	balancer Balancer
+
+	mu sync.Mutex
+	// servers that passed their last health check
+	healthy map[string]bool
+	// weights given with -backends, servers missing here weigh 1
+	weights map[string]int
+	// requests being forwarded to each server
+	active map[string]int
+	// cancels the health checks of each server in the pool
+	checks map[string]context.CancelFunc
+	// servers of the canary pool. They only get the share of traffic given by
+	// the canary routes, everything else goes to the stable pool.
+	canaries map[string]bool
+	// blue/green pool of each server, servers of neither pool always get traffic
+	colors map[string]string
+	// blue/green pool getting the traffic
+	live string
+}
+
+// NewLoadBalancer creates a balancer with an empty pool forwarding through client,
+// it picks servers with min-bytes and checks them with GET -health-path
+func NewLoadBalancer(client *http.Client) *LoadBalancer {
+	lb := &LoadBalancer{
+		checker:  httpHealthChecker{client},
+		now:      time.Now,
+		client:   client,
+		traffic:  NewTrafficCounter(),
+		healthy:  make(map[string]bool),
+		weights:  make(map[string]int),
+		active:   make(map[string]int),
+		checks:   make(map[string]context.CancelFunc),
+		canaries: make(map[string]bool),
+		colors:   make(map[string]string),
+		live:     colorBlue,
+	}
+	lb.metrics = NewMetrics(lb)
+	lb.balancer = minBytesBalancer{lb}
+	return lb
+}
+
+func (lb *LoadBalancer) setHealthy(server string, healthy bool) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.healthy[server] = healthy
+}
+
+func (lb *LoadBalancer) setWeight(server string, weight int) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.weights[server] = weight
+}
+
+// weight of a server, lb.mu must be held
+func (lb *LoadBalancer) weightOf(server string) int {
+	if weight, ok := lb.weights[server]; ok {
+		return weight
+	}
+	return 1
+}
+
+// copy of the requests in flight to each server
+func (lb *LoadBalancer) inFlight() map[string]int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	active := make(map[string]int, len(lb.active))
+	for server, n := range lb.active {
+		active[server] = n
+	}
+	return active
+}
 
 func scheme() string {
 	if *https {
@@ -41,31 +180,97 @@ func scheme() string {
 	return "http"
 }
 
-func health(dst string) bool {
-	ctx, _ := context.WithTimeout(context.Background(), timeout)
-	req, _ := http.NewRequestWithContext(ctx, "GET",
-		fmt.Sprintf("%s://%s/health", scheme(), dst), nil)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false
-	}
-	if resp.StatusCode != http.StatusOK {
-		return false
+// returned by forward when the server failed and nothing was written, so the request can go elsewhere
+var errRetry = errors.New("server failed, retry on another one")
+
+// report whether the request may be sent to another server after a failure, bodies are not kept
+func retryable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return r.ContentLength == 0
 	}
-	return true
+	return false
 }
 
-func forward(dst string, rw http.ResponseWriter, r *http.Request) error {
-	ctx, _ := context.WithTimeout(r.Context(), timeout)
+// cause of the cancellation of forwarded requests that took longer than their timeout
+var errTotalTimeout = errors.New("request timeout exceeded")
+
+// forward the request to dst and copy back the response. With canRetry, connection
+// failures and 502/503 answers are not written but reported with errRetry.
+func (lb *LoadBalancer) forward(dst string, rw http.ResponseWriter, r *http.Request, canRetry bool) error {
+	// dst was reserved by handle
+	defer lb.release(dst)
+
+	// the client going away cancels the forwarded request as well,
+	// streams are not subject to the timeout once they started
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+	deadline := time.AfterFunc(requestTimeout(r), func() { cancel(errTotalTimeout) })
+	defer deadline.Stop()
 	fwdRequest := r.Clone(ctx)
 	fwdRequest.RequestURI = ""
 	fwdRequest.URL.Host = dst
 	fwdRequest.URL.Scheme = scheme()
 	fwdRequest.Host = dst
+	if r.TLS != nil {
+		// backends see plain HTTP from the balancer
+		fwdRequest.Header.Set("X-Forwarded-Proto", "https")
+	}
+	if *traceEnabled {
+		// lets the servers report which address the balancer picked
+		fwdRequest.Header.Set("lb-from", dst)
+	}
 
-	resp, err := http.DefaultClient.Do(fwdRequest)
+	start := lb.now()
+	resp, err := lb.client.Do(fwdRequest)
+	latency := lb.now().Sub(start)
+	if err != nil {
+		lb.metrics.ObserveError(dst)
+	} else {
+		lb.metrics.ObserveRequest(dst, resp.StatusCode, latency)
+	}
+	if o, ok := lb.balancer.(latencyObserver); ok {
+		if err != nil && latency < ewmaFailurePenalty {
+			o.ObserveLatency(dst, ewmaFailurePenalty)
+		} else {
+			o.ObserveLatency(dst, latency)
+		}
+	}
+	if outliers != nil && r.Context().Err() == nil && !bodyTooLarge(err) {
+		// requests the client gave up on or sent too much for say nothing about the server
+		outliers.record(dst, err != nil || resp.StatusCode >= http.StatusInternalServerError, lb.now())
+	}
+	// the request used up its time, another server would get none
+	timedOut := err != nil && errors.Is(context.Cause(ctx), errTotalTimeout)
+	if canRetry && !timedOut {
+		if err != nil {
+			events.Warn("backend failed, retrying", "backend", dst, "requestId", r.Header.Get(httptools.RequestIDHeader), "error", err)
+			return errRetry
+		}
+		if resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable {
+			events.Warn("backend unavailable, retrying", "backend", dst, "requestId", r.Header.Get(httptools.RequestIDHeader), "status", resp.StatusCode)
+			resp.Body.Close()
+			return errRetry
+		}
+	}
+	if err == nil && isStream(resp) {
+		deadline.Stop()
+		clearWriteDeadline(rw)
+	}
+	if err == nil && resp.StatusCode == http.StatusSwitchingProtocols {
+		byteCount, err := proxyUpgrade(rw, resp)
+		if err != nil {
+			events.Warn("upgraded connection failed", "backend", dst, "requestId", r.Header.Get(httptools.RequestIDHeader), "error", err)
+		}
+		lb.traffic.add(dst, byteCount, lb.now())
+		accessLog.log(r, dst, resp.StatusCode, byteCount, start)
+		return nil
+	}
 	if err == nil {
 		for k, values := range resp.Header {
+			if k == httptools.RequestIDHeader {
+				continue // already set by handle
+			}
 			for _, value := range values {
 				rw.Header().Add(k, value)
 			}
@@ -73,59 +278,209 @@ func forward(dst string, rw http.ResponseWriter, r *http.Request) error {
 		if *traceEnabled {
 			rw.Header().Set("lb-from", dst)
 		}
-		log.Println("fwd", resp.StatusCode, resp.Request.URL)
 		rw.WriteHeader(resp.StatusCode)
 		defer resp.Body.Close()
-		byteCount, err := io.Copy(rw, resp.Body)
+		w, stop := responseWriter(rw, resp)
+		byteCount, err := io.Copy(w, resp.Body)
+		stop()
 		if err != nil {
-			log.Printf("Failed to write response: %s", err)
+			events.Warn("response not written", "backend", dst, "requestId", r.Header.Get(httptools.RequestIDHeader), "error", err)
 		} else {
-			serverBytes[dst] += byteCount
-			log.Printf("Received bytes dst=%s, bytes=%d", dst, serverBytes[dst])
+			lb.traffic.add(dst, byteCount, lb.now())
 		}
+		accessLog.log(r, dst, resp.StatusCode, byteCount, start)
 		return nil
 	} else {
-		log.Printf("Failed to get response from %s: %s", dst, err)
-		rw.WriteHeader(http.StatusServiceUnavailable)
+		events.Error("backend failed", "backend", dst, "requestId", r.Header.Get(httptools.RequestIDHeader), "error", err)
+		status := http.StatusServiceUnavailable
+		var netErr net.Error
+		if bodyTooLarge(err) {
+			status = http.StatusRequestEntityTooLarge
+		} else if timedOut || errors.As(err, &netErr) && netErr.Timeout() {
+			// the server was too slow to connect or to answer
+			status = http.StatusGatewayTimeout
+		}
+		rw.WriteHeader(status)
+		accessLog.log(r, dst, status, 0, start)
 		return err
 	}
 }
 
-// get a server which return minimal bytes
-func getMinByteServer() string {
-	var minServer string
-	var minBytes int64 = math.MaxInt64
-	for server, bytes := range serverBytes {
-		if bytes < minBytes {
-			minServer = server
-			minBytes = bytes
+func (lb *LoadBalancer) handle(rw http.ResponseWriter, r *http.Request) {
+	id := httptools.RequestID(r)
+	r.Header.Set(httptools.RequestIDHeader, id)
+	rw.Header().Set(httptools.RequestIDHeader, id)
+	if shadow != nil {
+		shadow.maybeSend(r)
+	}
+	healthy := lb.poolFor(r)
+	if len(healthy) == 0 {
+		http.Error(rw, "no healthy servers", http.StatusServiceUnavailable)
+		return
+	}
+	retries.deposit()
+	retried := 0
+	for {
+		available := lb.withCapacity(healthy)
+		if len(available) == 0 {
+			rw.Header().Set("Retry-After", "1")
+			http.Error(rw, "all servers are at capacity", http.StatusServiceUnavailable)
+			return
+		}
+		dst := lb.pick(r, available)
+		if !lb.reserve(dst) {
+			// a concurrent request took its last slot
+			continue
+		}
+		// the retry is taken from the budget up front so a failed answer is
+		// only held back when it can be retried
+		canRetry := len(healthy) > 1 && retryable(r) && retries.withdraw()
+		if err := lb.forward(dst, rw, r, canRetry); err != errRetry {
+			if canRetry {
+				retries.refund()
+			}
+			return
+		}
+		healthy = without(healthy, dst)
+		retried++
+		if !backoff(r.Context(), retried) {
+			return
+		}
+	}
+}
+
+// server the client is pinned to or else the one chosen by the balancer
+func (lb *LoadBalancer) pick(r *http.Request, healthy []Backend) string {
+	if affinity == nil {
+		server := lb.balancer.Pick(r, healthy).Addr
+		lb.metrics.ObservePick(server, *algorithm)
+		return server
+	}
+	client := forwardedClient(r)
+	if server, ok := affinity.lookup(client, healthy); ok {
+		lb.metrics.ObservePick(server, "affinity")
+		return server
+	}
+	server := lb.balancer.Pick(r, healthy).Addr
+	lb.metrics.ObservePick(server, *algorithm)
+	affinity.pin(client, server)
+	return server
+}
+
+func without(backends []Backend, addr string) []Backend {
+	rest := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		if b.Addr != addr {
+			rest = append(rest, b)
 		}
 	}
-	return minServer
+	return rest
 }
 
 func main() {
 	flag.Parse()
+	s, err := loadSettings(!discoveryEnabled())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if accessLog, err = newAccessLogger(*logFormat, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+	if events, err = logging.New(*logFormat, *logLevel, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
+	tlsConfig, err := backendTLSConfig(*backendCert, *backendKey, *backendCA)
+	if err != nil {
+		log.Fatalf("Invalid backend TLS settings: %s", err)
+	}
+	lb := NewLoadBalancer(newClient(tlsConfig))
+	if *bytesWindow < trafficBuckets {
+		// each of the buckets needs at least a nanosecond
+		log.Fatalf("-bytes-window must be at least %dns", trafficBuckets)
+	}
+	lb.traffic = NewTrafficCounterWindow(*bytesWindow)
+	if lb.balancer, err = newBalancer(*algorithm, lb); err != nil {
+		log.Fatal(err)
+	}
+	retries = newRetryBudget(*retryBudgetPct)
+	shadow = newMirror(lb.client, *mirrorAddr, *mirrorPercent, *mirrorMaxBody)
+	if *outlierThreshold > 0 && *outlierWindow < outlierBuckets {
+		log.Fatalf("-outlier-window must be at least %dns", outlierBuckets)
+	}
+	outliers = newOutlierDetector(*outlierThreshold, *outlierWindow, *outlierMin, *outlierEjection)
+	limiter = newInflightLimiter(*maxInflight, *maxQueue, *queueTimeout)
+	if affinity, err = newAffinityTable(*affinityMode, *affinityTTL); err != nil {
+		log.Fatal(err)
+	}
 
-	// TODO: Використовуйте дані про стан сервреа, щоб підтримувати список тих серверів, яким можна відправляти ззапит.
-	for _, server := range serversPool {
-		server := server
-		serverBytes[server] = 0
-		go func() {
-			for range time.Tick(10 * time.Second) {
-				log.Println(server, health(server))
+	if discoveryEnabled() {
+		var source discoverer
+		if *discoverDocker != "" {
+			source, err = newDockerDiscovery(*dockerHost, *discoverDocker, *dockerNetwork)
+		} else {
+			source, err = newDNSDiscovery(*discoverDNS)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		go newDiscoverySync(lb, source).run(context.Background(), *discoverEvery)
+	}
+	lb.applySettings(s, !discoveryEnabled())
+	if err := lb.switchLive(*livePool); err != nil {
+		log.Fatalf("Invalid -live: %s", err)
+	}
+	for color, list := range map[string]string{colorBlue: *blueBackends, colorGreen: *greenBackends} {
+		if list == "" {
+			continue
+		}
+		pool, err := loadBackends(list, "")
+		if err != nil {
+			log.Fatalf("Invalid %s backends: %s", color, err)
+		}
+		for _, backend := range pool {
+			if err := lb.addToPool(backend, false, color); err != nil {
+				log.Fatal(err)
 			}
-		}()
+		}
+	}
+	signal.OnReloadSignal(func() {
+		if err := lb.reload(); err != nil {
+			log.Printf("Failed to reload configuration: %s", err)
+		}
+	})
+	if *adminPort > 0 {
+		admin := httptools.CreateServer(*adminPort, newAdminHandler(lb))
+		admin.Start()
+		defer admin.Shutdown(context.Background())
 	}
 
-	frontend := httptools.CreateServer(*port, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		// TODO: Рееалізуйте свій алгоритм балансувальника.
-		minServer := getMinByteServer()
-		forward(minServer, rw, r)
-	}))
+	var frontend httptools.Server
+	if *tlsCert != "" || *tlsKey != "" {
+		if *tlsCert == "" || *tlsKey == "" {
+			log.Fatal("-tls-cert and -tls-key must be given together")
+		}
+		frontend = httptools.CreateTLSServer(*port, hstsMiddleware(*hsts, newFrontendHandler(lb)), *tlsCert, *tlsKey)
+		if *redirectPort > 0 {
+			redirect := httptools.CreateServer(*redirectPort, httpsRedirect(*port))
+			redirect.Start()
+			defer redirect.Shutdown(context.Background())
+		}
+	} else {
+		frontend = httptools.CreateServer(*port, newFrontendHandler(lb))
+	}
 
 	log.Println("Starting load balancer (variant 8) ...")
 	log.Printf("Tracing support enabled: %t", *traceEnabled)
+	log.Printf("TLS enabled: %t", *tlsCert != "")
 	frontend.Start()
 	signal.WaitForTerminationSignal()
+
+	// stop accepting connections and let forwarded requests finish
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := frontend.Shutdown(ctx); err != nil {
+		log.Println("Error draining requests:", err)
+	}
+	log.Println("Load balancer stopped")
 }
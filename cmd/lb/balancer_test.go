@@ -1,8 +1,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mikhmol/Architecture_Lab4/httptools"
 	check "gopkg.in/check.v1"
 )
 
@@ -16,15 +23,261 @@ type MySuite struct{}
 
 var _ = check.Suite(&MySuite{})
 
+// balancer with a fresh client whose pool is made of the given servers, all healthy
+func newTestLoadBalancer(healthy ...string) *LoadBalancer {
+	lb := NewLoadBalancer(newClient(nil))
+	for _, server := range healthy {
+		lb.healthy[server] = true
+	}
+	return lb
+}
+
 func (s *MySuite) TestGetMinByteServer(c *check.C) {
 	// Given
-	serverBytes["server1:8080"] = 500
-	serverBytes["server2:8080"] = 200
-	serverBytes["server3:8080"] = 300
+	lb := newTestLoadBalancer("server1:8080", "server2:8080", "server3:8080")
+	lb.traffic.Add("server1:8080", 500)
+	lb.traffic.Add("server2:8080", 200)
+	lb.traffic.Add("server3:8080", 300)
 
 	// When
-	minServer := getMinByteServer()
+	minServer := lb.getMinByteServer()
 
 	// Then
 	c.Assert(minServer, check.Equals, "server2:8080")
 }
+
+func (s *MySuite) TestGetMinByteServerSkipsUnhealthy(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer("server1:8080", "server3:8080")
+	lb.traffic.Add("server1:8080", 500)
+	lb.traffic.Add("server2:8080", 200)
+	lb.traffic.Add("server3:8080", 300)
+	lb.setHealthy("server2:8080", false)
+
+	// When
+	minServer := lb.getMinByteServer()
+
+	// Then
+	c.Assert(minServer, check.Equals, "server3:8080")
+}
+
+func (s *MySuite) TestNoHealthyServers(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer()
+	for _, server := range []string{"server1:8080", "server2:8080", "server3:8080"} {
+		lb.setHealthy(server, false)
+	}
+
+	// When
+	rec := httptest.NewRecorder()
+	lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	// Then
+	c.Assert(lb.getMinByteServer(), check.Equals, "")
+	c.Assert(rec.Code, check.Equals, http.StatusServiceUnavailable)
+}
+
+func (s *MySuite) TestLoadBackends(c *check.C) {
+	// When
+	backends, err := loadBackends(" a:80, b:80,,a:80 ", "")
+
+	// Then
+	c.Assert(err, check.IsNil)
+	c.Assert(backends, check.DeepEquals, []Backend{{"a:80", 1}, {"b:80", 1}})
+
+	file := filepath.Join(c.MkDir(), "backends")
+	c.Assert(ioutil.WriteFile(file, []byte("# pool\nc:8080=3\n\nd:8080\n"), 0600), check.IsNil)
+	backends, err = loadBackends("a:80", file)
+	c.Assert(err, check.IsNil)
+	c.Assert(backends, check.DeepEquals, []Backend{{"c:8080", 3}, {"d:8080", 1}})
+
+	_, err = loadBackends("", "")
+	c.Assert(err, check.NotNil)
+	_, err = loadBackends("http://a:80", "")
+	c.Assert(err, check.NotNil)
+	_, err = loadBackends("a:80=0", "")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *MySuite) TestGetMinByteServerWeighted(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer("server1:8080", "server2:8080", "server3:8080")
+	lb.traffic.Add("server1:8080", 500)
+	lb.traffic.Add("server2:8080", 200)
+	lb.traffic.Add("server3:8080", 300)
+	lb.setWeight("server1:8080", 3)
+
+	// When
+	minServer := lb.getMinByteServer()
+
+	// Then
+	c.Assert(minServer, check.Equals, "server1:8080")
+}
+
+func (s *MySuite) TestGetMinByteServerForgetsOldTraffic(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer("server1:8080", "server2:8080")
+	now := time.Now()
+	lb.now = func() time.Time { return now }
+	lb.traffic = NewTrafficCounterWindow(time.Minute)
+	lb.traffic.add("server1:8080", 500, now)
+	now = now.Add(2 * time.Minute)
+	lb.traffic.add("server2:8080", 200, now)
+
+	// When
+	minServer := lb.getMinByteServer()
+
+	// Then the bytes of server1 left the window
+	c.Assert(minServer, check.Equals, "server1:8080")
+}
+
+// picks the preferred server while it is healthy
+type preferBalancer struct {
+	addr string
+}
+
+func (b preferBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	for _, backend := range healthy {
+		if backend.Addr == b.addr {
+			return backend
+		}
+	}
+	return healthy[0]
+}
+
+func (s *MySuite) TestRetryOnAnotherServer(c *check.C) {
+	// Given
+	failing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer working.Close()
+	failingAddr := strings.TrimPrefix(failing.URL, "http://")
+	lb := newTestLoadBalancer(failingAddr, strings.TrimPrefix(working.URL, "http://"))
+	lb.balancer = preferBalancer{failingAddr}
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest(method, "/api/v1/some-data", strings.NewReader(body)))
+		return rec
+	}
+
+	// When
+	rec := serve("GET", "")
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, "ok")
+	c.Assert(serve("DELETE", "").Code, check.Equals, http.StatusOK)
+	// not idempotent
+	c.Assert(serve("POST", "body").Code, check.Equals, http.StatusServiceUnavailable)
+
+	// no other server to retry on
+	lb.setHealthy(strings.TrimPrefix(working.URL, "http://"), false)
+	c.Assert(serve("GET", "").Code, check.Equals, http.StatusServiceUnavailable)
+}
+
+func (s *MySuite) TestHealth(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+		case "/slow":
+			<-release
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	addr := strings.TrimPrefix(server.URL, "http://")
+	savedPath, savedTimeout := *healthPath, *healthTimeout
+	defer func() {
+		*healthPath, *healthTimeout = savedPath, savedTimeout
+	}()
+	*healthTimeout = 50 * time.Millisecond
+	lb := newTestLoadBalancer()
+
+	// Then
+	*healthPath = "/ready"
+	c.Assert(lb.health(addr), check.Equals, true)
+	*healthPath = "/health"
+	c.Assert(lb.health(addr), check.Equals, false)
+	*healthPath = "/slow"
+	c.Assert(lb.health(addr), check.Equals, false)
+}
+
+func (s *MySuite) TestRequestID(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// echoed like the servers behind the balancer do
+		rw.Header().Set(httptools.RequestIDHeader, r.Header.Get(httptools.RequestIDHeader))
+		_, _ = rw.Write([]byte(r.Header.Get(httptools.RequestIDHeader)))
+	}))
+	defer server.Close()
+	lb := newTestLoadBalancer(strings.TrimPrefix(server.URL, "http://"))
+	serve := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+		if id != "" {
+			r.Header.Set(httptools.RequestIDHeader, id)
+		}
+		lb.handle(rec, r)
+		return rec
+	}
+
+	// When
+	rec := serve("")
+
+	// Then
+	id := rec.Header().Values(httptools.RequestIDHeader)
+	c.Assert(id, check.HasLen, 1)
+	c.Assert(id[0], check.Matches, "[0-9a-f]{32}")
+	c.Assert(rec.Body.String(), check.Equals, id[0])
+
+	rec = serve("client-chosen-id")
+	c.Assert(rec.Header().Get(httptools.RequestIDHeader), check.Equals, "client-chosen-id")
+	c.Assert(rec.Body.String(), check.Equals, "client-chosen-id")
+	c.Assert(serve("bad id").Body.String(), check.Matches, "[0-9a-f]{32}")
+}
+
+func (s *MySuite) TestHealthMatcher(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error-page":
+			_, _ = rw.Write([]byte("<h1>Internal error</h1>"))
+		case "/status":
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			_, _ = rw.Write([]byte(`{"status": "OK"}`))
+		}
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	savedPath, savedStatus, savedBody := *healthPath, *healthStatus, *healthBody
+	defer func() {
+		*healthPath, *healthStatus, *healthBody = savedPath, savedStatus, savedBody
+	}()
+	health := newTestLoadBalancer().health
+
+	// When the body must contain the status
+	*healthBody = `"status": "OK"`
+
+	// Then a 200 error page does not pass
+	*healthPath = "/health"
+	c.Assert(health(addr), check.Equals, true)
+	*healthPath = "/error-page"
+	c.Assert(health(addr), check.Equals, false)
+
+	// When another status code is expected
+	*healthBody, *healthStatus, *healthPath = "", http.StatusNoContent, "/status"
+
+	// Then
+	c.Assert(health(addr), check.Equals, true)
+	*healthPath = "/health"
+	c.Assert(health(addr), check.Equals, false)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+)
+
+// pools of a blue/green deployment, only the live one gets traffic
+const (
+	colorBlue  = "blue"
+	colorGreen = "green"
+)
+
+func validColor(color string) bool {
+	return color == colorBlue || color == colorGreen
+}
+
+// send the traffic to the servers of the pool from now on, the other pool keeps being health checked
+func (lb *LoadBalancer) switchLive(color string) error {
+	if !validColor(color) {
+		return fmt.Errorf("pool must be %s or %s", colorBlue, colorGreen)
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.live = color
+	return nil
+}
+
+func (lb *LoadBalancer) currentLive() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.live
+}
+
+// whether the server may get traffic, the caller holds lb.mu
+func (lb *LoadBalancer) inLivePool(server string) bool {
+	color, ok := lb.colors[server]
+	return !ok || color == lb.live
+}
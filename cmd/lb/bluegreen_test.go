@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestBlueGreen(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer()
+	// both are taken as healthy, the hosts do not exist
+	lb.checker = HealthCheckFunc(func(ctx context.Context, server string) bool {
+		return true
+	})
+	defer func() {
+		lb.removeBackend("blue:80")
+		lb.removeBackend("green:80")
+	}()
+	admin := newAdminHandler(lb)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	c.Assert(do("POST", "/admin/backends", `{"addr": "blue:80", "color": "blue"}`).Code, check.Equals, http.StatusCreated)
+	c.Assert(do("POST", "/admin/backends", `{"addr": "green:80", "color": "green"}`).Code, check.Equals, http.StatusCreated)
+	c.Assert(do("POST", "/admin/backends", `{"addr": "red:80", "color": "red"}`).Code, check.Equals, http.StatusBadRequest)
+
+	// Then only the live pool gets traffic
+	c.Assert(lb.getHealthyBackends(), check.DeepEquals, []Backend{{"blue:80", 1}})
+
+	// When
+	rec := do("PUT", "/admin/live", `{"color": "green"}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(lb.getHealthyBackends(), check.DeepEquals, []Backend{{"green:80", 1}})
+	c.Assert(do("GET", "/admin/live", "").Body.String(), check.Equals, `{"color":"green"}`+"\n")
+	c.Assert(do("PUT", "/admin/live", `{"color": "red"}`).Code, check.Equals, http.StatusBadRequest)
+	// the inactive pool stays in the pool and is health checked
+	c.Assert(lb.listBackends(), check.HasLen, 2)
+}
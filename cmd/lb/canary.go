@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// percentage of the requests to paths starting with prefix sent to the canary pool
+type CanaryRoute struct {
+	Prefix  string  `json:"prefix"`
+	Percent float64 `json:"percent"`
+}
+
+// canary routes adjustable at runtime
+type canarySplit struct {
+	mu     sync.Mutex
+	routes map[string]float64
+}
+
+// set from -canary-routes once flags are parsed
+var canary = newCanarySplit(nil)
+
+func newCanarySplit(routes []CanaryRoute) *canarySplit {
+	s := &canarySplit{routes: make(map[string]float64, len(routes))}
+	for _, route := range routes {
+		s.set(route)
+	}
+	return s
+}
+
+// parse comma separated prefix=percent pairs, e.g. /api/v1/some-data=10,/=1
+func parseCanaryRoutes(list string) ([]CanaryRoute, error) {
+	var routes []CanaryRoute
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i <= 0 || !strings.HasPrefix(item, "/") {
+			return nil, fmt.Errorf("canary route %q must be given as /prefix=percent", item)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(item[i+1:], "%"), 64)
+		if err != nil || !validPercent(percent) {
+			return nil, fmt.Errorf("canary route %q must have a percentage from 0 to 100", item)
+		}
+		routes = append(routes, CanaryRoute{Prefix: item[:i], Percent: percent})
+	}
+	return routes, nil
+}
+
+func validPercent(percent float64) bool {
+	return percent >= 0 && percent <= 100
+}
+
+// change the share of a prefix, a share of 0 removes the route
+func (s *canarySplit) set(route CanaryRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if route.Percent == 0 {
+		delete(s.routes, route.Prefix)
+		return
+	}
+	s.routes[route.Prefix] = route.Percent
+}
+
+// replace all routes
+func (s *canarySplit) replace(routes []CanaryRoute) {
+	fresh := newCanarySplit(routes)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = fresh.routes
+}
+
+// routes ordered by prefix
+func (s *canarySplit) list() []CanaryRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	routes := make([]CanaryRoute, 0, len(s.routes))
+	for prefix, percent := range s.routes {
+		routes = append(routes, CanaryRoute{Prefix: prefix, Percent: percent})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Prefix < routes[j].Prefix
+	})
+	return routes
+}
+
+// share of the route with the longest prefix matching the path, 0 when none matches
+func (s *canarySplit) percent(path string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, longest := 0.0, -1
+	for prefix, percent := range s.routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			result, longest = percent, len(prefix)
+		}
+	}
+	return result
+}
+
+// healthy servers of the pool the request is sent to
+func (lb *LoadBalancer) poolFor(r *http.Request) []Backend {
+	if percent := canary.percent(r.URL.Path); percent > 0 && rand.Float64()*100 < percent {
+		if canaries := withoutOutliers(lb.healthyBackends(true), lb.now()); len(canaries) > 0 {
+			return canaries
+		}
+	}
+	return withoutOutliers(lb.getHealthyBackends(), lb.now())
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestParseCanaryRoutes(c *check.C) {
+	routes, err := parseCanaryRoutes(" /api/v1/some-data=12.5, /=1% ,")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []CanaryRoute{{"/api/v1/some-data", 12.5}, {"/", 1}})
+
+	for _, invalid := range []string{"/api", "api=10", "/api=101", "/api=-1", "/api=x"} {
+		_, err := parseCanaryRoutes(invalid)
+		c.Assert(err, check.NotNil, check.Commentf(invalid))
+	}
+}
+
+func (s *MySuite) TestCanarySplit(c *check.C) {
+	// Given
+	backend := func(name string) (*httptest.Server, string) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte(name))
+		}))
+		return server, strings.TrimPrefix(server.URL, "http://")
+	}
+	stable, stableAddr := backend("stable")
+	defer stable.Close()
+	canaryServer, canaryAddr := backend("canary")
+	defer canaryServer.Close()
+
+	lb := newTestLoadBalancer()
+	savedSplit := canary
+	canary = newCanarySplit(nil)
+	defer func() {
+		lb.removeBackend(stableAddr)
+		lb.removeBackend(canaryAddr)
+		canary = savedSplit
+	}()
+	c.Assert(lb.addBackend(Backend{Addr: stableAddr, Weight: 1}), check.IsNil)
+	admin := newAdminHandler(lb)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Body.String()
+	}
+	c.Assert(do("POST", "/admin/backends", `{"addr": "`+canaryAddr+`", "canary": true}`).Code, check.Equals, http.StatusCreated)
+
+	// When no route sends traffic to the canary pool
+	// Then
+	c.Assert(get("/api/v1/some-data"), check.Equals, "stable")
+
+	// When
+	rec := do("PUT", "/admin/canary", `{"prefix": "/api/v1/some-data", "percent": 100}`)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, `[{"prefix":"/api/v1/some-data","percent":100}]`+"\n")
+	c.Assert(get("/api/v1/some-data"), check.Equals, "canary")
+	c.Assert(get("/api/v1/other"), check.Equals, "stable")
+
+	// When the canary is taken out of the route
+	c.Assert(do("PUT", "/admin/canary", `{"prefix": "/api/v1/some-data", "percent": 0}`).Code, check.Equals, http.StatusOK)
+
+	// Then
+	c.Assert(get("/api/v1/some-data"), check.Equals, "stable")
+	c.Assert(do("GET", "/admin/canary", "").Body.String(), check.Equals, "[]\n")
+	c.Assert(do("PUT", "/admin/canary", `{"prefix": "/api", "percent": 150}`).Code, check.Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settings read from the -config JSON file, they override the matching flags
+// and are read again on reload. Fields left out keep the value of the flag.
+type Config struct {
+	Backends       []string           `json:"backends,omitempty"`       // host:port[=weight]
+	CanaryBackends []string           `json:"canaryBackends,omitempty"` // host:port[=weight]
+	Timeout        string             `json:"timeout,omitempty"`        // e.g. 3s
+	RouteTimeouts  map[string]string  `json:"routeTimeouts,omitempty"`  // path prefix to timeout
+	CanaryRoutes   map[string]float64 `json:"canaryRoutes,omitempty"`   // path prefix to percent
+}
+
+// flags and config file resolved into what the balancer runs with
+type settings struct {
+	stable, canary []Backend
+	timeout        time.Duration
+	routes         []routeTimeout
+	canaryRoutes   []CanaryRoute
+}
+
+// serializes reloads from SIGHUP and the admin API
+var reloadMu sync.Mutex
+
+// whether the stable pool is found by discovery rather than configured
+func discoveryEnabled() bool {
+	return *discoverDNS != "" || *discoverDocker != ""
+}
+
+// resolve the settings from the flags and the -config file, the stable pool
+// is only loaded when withStable is set
+func loadSettings(withStable bool) (settings, error) {
+	s := settings{timeout: time.Duration(*timeoutSec) * time.Second}
+	var err error
+	if s.routes, err = parseRouteTimeouts(*routes); err != nil {
+		return s, fmt.Errorf("invalid route timeouts: %w", err)
+	}
+	if s.canaryRoutes, err = parseCanaryRoutes(*canaryRoutes); err != nil {
+		return s, fmt.Errorf("invalid canary routes: %w", err)
+	}
+	stableList, stableFile, canaryList := *backends, *backendsFile, *canaryBackends
+
+	if *configFile != "" {
+		var config Config
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return s, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return s, fmt.Errorf("invalid config %s: %w", *configFile, err)
+		}
+		if config.Backends != nil {
+			stableList, stableFile = strings.Join(config.Backends, ","), ""
+		}
+		if config.CanaryBackends != nil {
+			canaryList = strings.Join(config.CanaryBackends, ",")
+		}
+		if config.Timeout != "" {
+			if s.timeout, err = time.ParseDuration(config.Timeout); err != nil || s.timeout <= 0 {
+				return s, fmt.Errorf("timeout %q must be a positive duration", config.Timeout)
+			}
+		}
+		if config.RouteTimeouts != nil {
+			s.routes = nil
+			for prefix, value := range config.RouteTimeouts {
+				timeout, err := time.ParseDuration(value)
+				if err != nil || timeout <= 0 || !strings.HasPrefix(prefix, "/") {
+					return s, fmt.Errorf("route timeout %s=%s must have a /prefix and a positive duration", prefix, value)
+				}
+				s.routes = append(s.routes, routeTimeout{prefix: prefix, timeout: timeout})
+			}
+		}
+		if config.CanaryRoutes != nil {
+			s.canaryRoutes = nil
+			for prefix, percent := range config.CanaryRoutes {
+				if !strings.HasPrefix(prefix, "/") || !validPercent(percent) {
+					return s, fmt.Errorf("canary route %s=%g must have a /prefix and a percentage from 0 to 100", prefix, percent)
+				}
+				s.canaryRoutes = append(s.canaryRoutes, CanaryRoute{Prefix: prefix, Percent: percent})
+			}
+		}
+	}
+
+	// blue/green deployments may do without a stable pool
+	blueGreen := *blueBackends != "" || *greenBackends != ""
+	if withStable && !(blueGreen && stableList == "" && stableFile == "") {
+		if s.stable, err = loadBackends(stableList, stableFile); err != nil {
+			return s, fmt.Errorf("invalid backends: %w", err)
+		}
+	}
+	if canaryList != "" {
+		if s.canary, err = loadBackends(canaryList, ""); err != nil {
+			return s, fmt.Errorf("invalid canary backends: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// switch to the settings, servers staying in the pool keep their connections and health state
+func (lb *LoadBalancer) applySettings(s settings, withStable bool) {
+	timeoutMu.Lock()
+	timeout = s.timeout
+	routeTimeouts = s.routes
+	timeoutMu.Unlock()
+	canary.replace(s.canaryRoutes)
+	if withStable {
+		lb.syncPool(s.stable, false)
+	}
+	lb.syncPool(s.canary, true)
+}
+
+// make the stable or canary pool consist of the wanted servers. Servers added
+// through the admin API are removed unless they are wanted as well, servers of
+// the blue and green pools are left alone.
+func (lb *LoadBalancer) syncPool(wanted []Backend, canary bool) {
+	keep := make(map[string]bool, len(wanted))
+	for _, b := range wanted {
+		keep[b.Addr] = true
+	}
+	for _, b := range lb.listBackends() {
+		if b.Canary == canary && b.Color == "" && !keep[b.Addr] {
+			lb.removeBackend(b.Addr)
+			log.Printf("Backend %s removed", b.Addr)
+		}
+	}
+	for _, b := range wanted {
+		lb.mu.Lock()
+		_, exists := lb.checks[b.Addr]
+		if exists {
+			lb.weights[b.Addr] = b.Weight
+			if canary {
+				lb.canaries[b.Addr] = true
+			} else {
+				delete(lb.canaries, b.Addr)
+			}
+		}
+		lb.mu.Unlock()
+		if exists {
+			continue
+		}
+		if err := lb.addToPool(b, canary, ""); err != nil {
+			log.Printf("Failed to add backend: %s", err)
+			continue
+		}
+		log.Printf("Backend %s added", b.Addr)
+	}
+}
+
+// read the flags and the -config file again and apply them, the running settings
+// are kept when they are invalid
+func (lb *LoadBalancer) reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	s, err := loadSettings(!discoveryEnabled())
+	if err != nil {
+		return err
+	}
+	lb.applySettings(s, !discoveryEnabled())
+	log.Println("Configuration reloaded")
+	return nil
+}
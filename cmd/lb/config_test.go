@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestReload(c *check.C) {
+	// Given
+	backend := func() (*httptest.Server, string) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+		return server, strings.TrimPrefix(server.URL, "http://")
+	}
+	first, firstAddr := backend()
+	defer first.Close()
+	second, secondAddr := backend()
+	defer second.Close()
+
+	config := filepath.Join(c.MkDir(), "lb.json")
+	write := func(data string) {
+		c.Assert(ioutil.WriteFile(config, []byte(data), 0o644), check.IsNil)
+	}
+	*configFile = config
+	lb := newTestLoadBalancer()
+	savedTimeout, savedRoutes, savedSplit := timeout, routeTimeouts, canary
+	canary = newCanarySplit(nil)
+	defer func() {
+		*configFile = ""
+		lb.removeBackend(firstAddr)
+		lb.removeBackend(secondAddr)
+		timeout, routeTimeouts, canary = savedTimeout, savedRoutes, savedSplit
+	}()
+	write(`{"backends": ["` + firstAddr + `"], "timeout": "5s", "routeTimeouts": {"/api/v1/reports": "1m"}}`)
+
+	// When
+	c.Assert(lb.reload(), check.IsNil)
+
+	// Then
+	c.Assert(lb.listBackends(), check.DeepEquals, []BackendStatus{{Addr: firstAddr, Weight: 1, Healthy: true}})
+	c.Assert(requestTimeout(httptest.NewRequest("GET", "/api/v1/reports/2023", nil)), check.Equals, time.Minute)
+	c.Assert(requestTimeout(httptest.NewRequest("GET", "/api/v1/some-data", nil)), check.Equals, 5*time.Second)
+
+	// When the servers change pools and weights
+	write(`{"backends": ["` + secondAddr + `=3"], "canaryBackends": ["` + firstAddr + `"], "canaryRoutes": {"/": 5}}`)
+	rec := httptest.NewRecorder()
+	newAdminHandler(lb).ServeHTTP(rec, httptest.NewRequest("POST", "/admin/reload", nil))
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(lb.getHealthyBackends(), check.DeepEquals, []Backend{{secondAddr, 3}})
+	c.Assert(lb.healthyBackends(true), check.DeepEquals, []Backend{{firstAddr, 1}})
+	c.Assert(canary.list(), check.DeepEquals, []CanaryRoute{{"/", 5}})
+	c.Assert(requestTimeout(httptest.NewRequest("GET", "/api/v1/reports/2023", nil)), check.Equals, time.Duration(*timeoutSec)*time.Second)
+
+	// When the config is invalid
+	write(`{"backends": ["http://` + firstAddr + `"]}`)
+
+	// Then the running settings are kept
+	c.Assert(lb.reload(), check.NotNil)
+	c.Assert(lb.getHealthyBackends(), check.DeepEquals, []Backend{{secondAddr, 3}})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// finds the servers that should be in the pool
+type discoverer interface {
+	discover(ctx context.Context) ([]Backend, error)
+}
+
+// keeps the pool in line with a discoverer, only servers it added are ever removed
+type discoverySync struct {
+	pool   *LoadBalancer
+	source discoverer
+	known  map[string]bool
+}
+
+func newDiscoverySync(pool *LoadBalancer, source discoverer) *discoverySync {
+	return &discoverySync{pool: pool, source: source, known: make(map[string]bool)}
+}
+
+// add discovered servers missing from the pool and remove those no longer discovered.
+// The pool is left alone when discovery fails.
+func (d *discoverySync) sync(ctx context.Context) error {
+	found, err := d.source.discover(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool, len(found))
+	for _, backend := range found {
+		current[backend.Addr] = true
+		if d.known[backend.Addr] {
+			continue
+		}
+		if err := d.pool.addBackend(backend); err != nil {
+			log.Printf("Failed to add discovered backend: %s", err)
+			continue
+		}
+		d.known[backend.Addr] = true
+		log.Printf("Backend %s discovered", backend.Addr)
+	}
+	for addr := range d.known {
+		if !current[addr] {
+			d.pool.removeBackend(addr)
+			delete(d.known, addr)
+			log.Printf("Backend %s is gone", addr)
+		}
+	}
+	return nil
+}
+
+// sync every interval until ctx is done
+func (d *discoverySync) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.sync(ctx); err != nil {
+			log.Printf("Discovery failed: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// servers behind a DNS name: every A/AAAA record of host:port, or the targets
+// of an SRV name such as _http._tcp.server
+type dnsDiscovery struct {
+	name string
+
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	lookupSRV  func(ctx context.Context, name string) ([]*net.SRV, error)
+}
+
+func newDNSDiscovery(name string) (*dnsDiscovery, error) {
+	if !strings.HasPrefix(name, "_") {
+		if _, _, err := net.SplitHostPort(name); err != nil {
+			return nil, fmt.Errorf("DNS name %q must be host:port or an SRV name", name)
+		}
+	}
+	return &dnsDiscovery{
+		name:       name,
+		lookupHost: net.DefaultResolver.LookupHost,
+		lookupSRV: func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+			return records, err
+		},
+	}, nil
+}
+
+func (d *dnsDiscovery) discover(ctx context.Context) ([]Backend, error) {
+	if strings.HasPrefix(d.name, "_") {
+		records, err := d.lookupSRV(ctx, d.name)
+		if err != nil {
+			return nil, err
+		}
+		backends := make([]Backend, 0, len(records))
+		for _, srv := range records {
+			host := strings.TrimSuffix(srv.Target, ".")
+			backends = append(backends, Backend{Addr: net.JoinHostPort(host, strconv.Itoa(int(srv.Port))), Weight: 1})
+		}
+		return backends, nil
+	}
+
+	host, port, _ := net.SplitHostPort(d.name)
+	addrs, err := d.lookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	backends := make([]Backend, 0, len(addrs))
+	for _, addr := range addrs {
+		backends = append(backends, Backend{Addr: net.JoinHostPort(addr, port), Weight: 1})
+	}
+	return backends, nil
+}
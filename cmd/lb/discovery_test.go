@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestDNSDiscovery(c *check.C) {
+	// Given
+	addrs := []string{"127.0.0.1", "127.0.0.2"}
+	source, err := newDNSDiscovery("server:1")
+	c.Assert(err, check.IsNil)
+	source.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if host != "server" {
+			return nil, fmt.Errorf("unexpected host %s", host)
+		}
+		return addrs, nil
+	}
+	lb := newTestLoadBalancer()
+	d := newDiscoverySync(lb, source)
+	defer func() {
+		lb.removeBackend("127.0.0.1:1")
+		lb.removeBackend("127.0.0.2:1")
+		lb.removeBackend("127.0.0.3:1")
+	}()
+	pool := func() []string {
+		var addrs []string
+		for _, b := range lb.listBackends() {
+			addrs = append(addrs, b.Addr)
+		}
+		return addrs
+	}
+
+	// When
+	c.Assert(d.sync(context.Background()), check.IsNil)
+
+	// Then
+	c.Assert(pool(), check.DeepEquals, []string{"127.0.0.1:1", "127.0.0.2:1"})
+
+	addrs = []string{"127.0.0.2", "127.0.0.3"}
+	c.Assert(d.sync(context.Background()), check.IsNil)
+	c.Assert(pool(), check.DeepEquals, []string{"127.0.0.2:1", "127.0.0.3:1"})
+
+	// a failed resolution keeps the pool
+	source.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, fmt.Errorf("no such host")
+	}
+	c.Assert(d.sync(context.Background()), check.NotNil)
+	c.Assert(pool(), check.DeepEquals, []string{"127.0.0.2:1", "127.0.0.3:1"})
+}
+
+func (s *MySuite) TestDNSDiscoverySRV(c *check.C) {
+	// Given
+	source, err := newDNSDiscovery("_http._tcp.server")
+	c.Assert(err, check.IsNil)
+	source.lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+		return []*net.SRV{{Target: "server-1.local.", Port: 8080}, {Target: "server-2.local.", Port: 8081}}, nil
+	}
+
+	// When
+	backends, err := source.discover(context.Background())
+
+	// Then
+	c.Assert(err, check.IsNil)
+	c.Assert(backends, check.DeepEquals, []Backend{{"server-1.local:8080", 1}, {"server-2.local:8081", 1}})
+
+	_, err = newDNSDiscovery("server")
+	c.Assert(err, check.NotNil)
+}
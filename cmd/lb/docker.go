@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// running containers carrying a label, the label value is the port they serve on
+type dockerDiscovery struct {
+	client  *http.Client
+	baseURL string
+	label   string
+	network string // network whose address is used, any when empty
+}
+
+// host is the Docker API address, e.g. unix:///var/run/docker.sock or http://docker:2375
+func newDockerDiscovery(host, label, network string) (*dockerDiscovery, error) {
+	d := &dockerDiscovery{client: http.DefaultClient, baseURL: strings.TrimSuffix(host, "/"), label: label, network: network}
+	if socket := strings.TrimPrefix(host, "unix://"); socket != host {
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		d.client = &http.Client{Transport: transport}
+		d.baseURL = "http://docker"
+	} else if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		return nil, fmt.Errorf("docker host %q must be a unix:// or http(s):// address", host)
+	}
+	return d, nil
+}
+
+// fields of GET /containers/json used for discovery
+type dockerContainer struct {
+	Names           []string
+	Labels          map[string]string
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+func (d *dockerDiscovery) discover(ctx context.Context) ([]Backend, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {d.label}, "status": {"running"}})
+	req, err := http.NewRequestWithContext(ctx, "GET", d.baseURL+"/containers/json?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker API answered %s", resp.Status)
+	}
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+
+	var backends []Backend
+	for _, container := range containers {
+		port, err := strconv.Atoi(container.Labels[d.label])
+		if err != nil || port <= 0 {
+			return nil, fmt.Errorf("label %s of container %v must be a port", d.label, container.Names)
+		}
+		if ip := d.address(container); ip != "" {
+			backends = append(backends, Backend{Addr: net.JoinHostPort(ip, strconv.Itoa(port)), Weight: 1})
+		}
+	}
+	return backends, nil
+}
+
+// IP of the container on the configured network, or on the first network by name
+func (d *dockerDiscovery) address(container dockerContainer) string {
+	networks := container.NetworkSettings.Networks
+	if d.network != "" {
+		return networks[d.network].IPAddress
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := networks[name].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
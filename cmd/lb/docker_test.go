@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestDockerDiscovery(c *check.C) {
+	// Given
+	var filters string
+	api := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		filters = r.URL.Query().Get("filters")
+		_, _ = rw.Write([]byte(`[
+			{"Names": ["/server-1"], "Labels": {"lb.backend": "8080"},
+			 "NetworkSettings": {"Networks": {"servers": {"IPAddress": "172.18.0.3"}, "bridge": {"IPAddress": "172.17.0.3"}}}},
+			{"Names": ["/server-2"], "Labels": {"lb.backend": "8081"},
+			 "NetworkSettings": {"Networks": {"servers": {"IPAddress": "172.18.0.4"}}}}
+		]`))
+	}))
+	defer api.Close()
+	docker, err := newDockerDiscovery(api.URL, "lb.backend", "servers")
+	c.Assert(err, check.IsNil)
+
+	// When
+	backends, err := docker.discover(context.Background())
+
+	// Then
+	c.Assert(err, check.IsNil)
+	c.Assert(filters, check.Equals, `{"label":["lb.backend"],"status":["running"]}`)
+	c.Assert(backends, check.DeepEquals, []Backend{{"172.18.0.3:8080", 1}, {"172.18.0.4:8081", 1}})
+
+	docker.network = ""
+	backends, err = docker.discover(context.Background())
+	c.Assert(err, check.IsNil)
+	c.Assert(backends[0].Addr, check.Equals, "172.17.0.3:8080")
+
+	_, err = newDockerDiscovery("tcp://docker:2375", "lb.backend", "")
+	c.Assert(err, check.NotNil)
+	unix, err := newDockerDiscovery("unix:///var/run/docker.sock", "lb.backend", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(unix.baseURL, check.Equals, "http://docker")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// the server with the lowest average latency times requests in flight
+const algorithmEWMA = "ewma"
+
+// time after which an old latency sample weighs 1/e of a new one
+const ewmaDecay = 10 * time.Second
+
+// latency counted for a request that failed, a refused connection fails faster than
+// any server answers and would otherwise make the server look like the fastest one
+const ewmaFailurePenalty = 5 * time.Second
+
+// a Balancer implementing latencyObserver is told how long each forwarded request took
+type latencyObserver interface {
+	ObserveLatency(addr string, latency time.Duration)
+}
+
+type latencyAverage struct {
+	value float64 // seconds
+	last  time.Time
+}
+
+// picks the server with the lowest cost, its exponentially weighted moving average
+// latency times its requests in flight plus one. Servers without samples cost nothing,
+// so new ones are tried right away.
+type ewmaBalancer struct {
+	pool *LoadBalancer
+
+	mu       sync.Mutex
+	averages map[string]*latencyAverage
+}
+
+func newEWMABalancer(pool *LoadBalancer) *ewmaBalancer {
+	return &ewmaBalancer{pool: pool, averages: make(map[string]*latencyAverage)}
+}
+
+func (e *ewmaBalancer) ObserveLatency(addr string, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.pool.now()
+	avg, ok := e.averages[addr]
+	if !ok {
+		e.averages[addr] = &latencyAverage{value: latency.Seconds(), last: now}
+		return
+	}
+	// samples arriving close together move the average less than ones far apart
+	w := math.Exp(-float64(now.Sub(avg.last)) / float64(ewmaDecay))
+	avg.value = avg.value*w + latency.Seconds()*(1-w)
+	avg.last = now
+}
+
+func (e *ewmaBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	latencies := make(map[string]float64, len(healthy))
+	e.mu.Lock()
+	for _, b := range healthy {
+		if avg, ok := e.averages[b.Addr]; ok {
+			latencies[b.Addr] = avg.value
+		}
+	}
+	e.mu.Unlock()
+
+	active := e.pool.inFlight()
+	return minBy(healthy, func(b Backend) float64 {
+		return latencies[b.Addr] * float64(active[b.Addr]+1)
+	})
+}
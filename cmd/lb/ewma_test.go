@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestEWMA(c *check.C) {
+	// Given
+	healthy := []Backend{{"a:80", 1}, {"b:80", 1}, {"c:80", 1}}
+	lb := newTestLoadBalancer()
+	balancer := newEWMABalancer(lb)
+	balancer.ObserveLatency("a:80", 2*time.Second)
+	balancer.ObserveLatency("b:80", 10*time.Millisecond)
+
+	// When a server has no samples yet
+	// Then it is tried first
+	c.Assert(balancer.Pick(nil, healthy).Addr, check.Equals, "c:80")
+
+	// When
+	balancer.ObserveLatency("c:80", 50*time.Millisecond)
+
+	// Then the fastest server is picked
+	c.Assert(balancer.Pick(nil, healthy).Addr, check.Equals, "b:80")
+
+	// When the fastest server is busy
+	lb.active["b:80"] = 10
+
+	// Then
+	c.Assert(balancer.Pick(nil, healthy).Addr, check.Equals, "c:80")
+}
+
+func (s *MySuite) TestEWMADecay(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer()
+	now := time.Now()
+	lb.now = func() time.Time { return now }
+	balancer := newEWMABalancer(lb)
+	balancer.ObserveLatency("a:80", time.Second)
+
+	// When a sample arrives long after the previous one
+	now = now.Add(time.Hour)
+	balancer.ObserveLatency("a:80", 10*time.Millisecond)
+
+	// Then it replaces the average almost entirely
+	c.Assert(balancer.averages["a:80"].value < 0.011, check.Equals, true)
+}
+
+func (s *MySuite) TestEWMAPenalizesFailures(c *check.C) {
+	// Given a server refusing connections and a slow one
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refusedAddr := strings.TrimPrefix(refused.URL, "http://")
+	refused.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer working.Close()
+	workingAddr := strings.TrimPrefix(working.URL, "http://")
+	lb := newTestLoadBalancer(refusedAddr, workingAddr)
+	balancer := newEWMABalancer(lb)
+	lb.balancer = balancer
+
+	// When both got requests
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+		c.Assert(rec.Code, check.Equals, http.StatusOK)
+	}
+
+	// Then the failures count as slow and the working server is picked
+	c.Assert(balancer.averages[refusedAddr].value >= ewmaFailurePenalty.Seconds(), check.Equals, true)
+	healthy := []Backend{{refusedAddr, 1}, {workingAddr, 1}}
+	c.Assert(balancer.Pick(nil, healthy).Addr, check.Equals, workingAddr)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+)
+
+const (
+	selfHealthPath = "/lb/health"
+	selfReadyPath  = "/lb/ready"
+)
+
+// serves the endpoints of the balancer itself and forwards everything else to the backends untouched
+func newFrontendHandler(lb *LoadBalancer) http.Handler {
+	own := map[string]http.HandlerFunc{
+		statsPath:      lb.serveStats,
+		selfHealthPath: serveHealth,
+		selfReadyPath:  lb.serveReady,
+	}
+	forward := bodyLimitMiddleware(*maxBodyBytes, limitMiddleware(limiter, http.HandlerFunc(lb.handle)))
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		serve, ok := own[r.URL.Path]
+		if !ok {
+			forward.ServeHTTP(rw, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			rw.Header().Set("Allow", "GET, HEAD")
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		serve(rw, r)
+	})
+}
+
+// the balancer process is up
+func serveHealth(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("content-type", "text/plain")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write([]byte("OK"))
+}
+
+// at least one backend can take requests
+func (lb *LoadBalancer) serveReady(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("content-type", "text/plain")
+	if len(lb.getHealthyBackends()) > 0 || len(lb.healthyBackends(true)) > 0 {
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("OK"))
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte("NOT READY"))
+	}
+}
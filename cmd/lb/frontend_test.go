@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestHealthAndReady(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer()
+	lb.setHealthy("server1:8080", false)
+	frontend := newFrontendHandler(lb)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		frontend.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// When no backend is healthy
+	// Then the balancer is alive but not ready
+	c.Assert(get("/lb/health").Code, check.Equals, http.StatusOK)
+	c.Assert(get("/lb/ready").Code, check.Equals, http.StatusServiceUnavailable)
+
+	// When
+	lb.setHealthy("server1:8080", true)
+
+	// Then
+	rec := get("/lb/ready")
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, "OK")
+}
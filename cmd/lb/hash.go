@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mikhmol/Architecture_Lab4/hashring"
+)
+
+// picks the server a request key hashes to on a ring of the healthy servers
+type hashBalancer struct {
+	key func(r *http.Request) string
+
+	mu      sync.Mutex
+	servers string // healthy servers the ring was built from
+	ring    *hashring.Ring
+}
+
+func newHashBalancer(key func(r *http.Request) string) *hashBalancer {
+	return &hashBalancer{key: key}
+}
+
+func (hb *hashBalancer) Pick(r *http.Request, healthy []Backend) Backend {
+	addr := hb.ringOf(healthy).Get(hb.key(r))
+	for _, b := range healthy {
+		if b.Addr == addr {
+			return b
+		}
+	}
+	return Backend{}
+}
+
+// ring of the servers, rebuilt only when the set of healthy servers changes
+func (hb *hashBalancer) ringOf(healthy []Backend) *hashring.Ring {
+	var key strings.Builder
+	nodes := make([]hashring.Node, len(healthy))
+	for i, backend := range healthy {
+		fmt.Fprintf(&key, "%s=%d,", backend.Addr, backend.Weight)
+		nodes[i] = hashring.Node{Name: backend.Addr, Weight: backend.Weight}
+	}
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.ring == nil || hb.servers != key.String() {
+		hb.servers = key.String()
+		hb.ring = hashring.New(nodes)
+	}
+	return hb.ring
+}
+
+// healthy servers with their weights in a stable order
+func (lb *LoadBalancer) getHealthyBackends() []Backend {
+	return lb.healthyBackends(false)
+}
+
+// healthy servers of the canary pool or of the stable one ordered by address,
+// drained servers of weight 0 and those of the blue or green pool that is not live are left out
+func (lb *LoadBalancer) healthyBackends(canary bool) []Backend {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	var backends []Backend
+	for server, healthy := range lb.healthy {
+		if healthy && lb.canaries[server] == canary && lb.weightOf(server) > 0 && lb.inLivePool(server) {
+			backends = append(backends, Backend{Addr: server, Weight: lb.weightOf(server)})
+		}
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Addr < backends[j].Addr
+	})
+	return backends
+}
+
+// IP the request came from, without the port
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestPath(r *http.Request) string {
+	return r.URL.Path
+}
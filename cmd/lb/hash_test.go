@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestClientHash(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer("server1:8080", "server2:8080", "server3:8080")
+	balancer := newHashBalancer(clientAddress)
+	request := func(addr string) string {
+		r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+		r.RemoteAddr = addr
+		return balancer.Pick(r, lb.getHealthyBackends()).Addr
+	}
+
+	// When
+	server := request("192.0.2.1:1234")
+
+	// Then
+	c.Assert(server, check.Not(check.Equals), "")
+	c.Assert(request("192.0.2.1:5678"), check.Equals, server)
+
+	lb.setHealthy(server, false)
+	other := request("192.0.2.1:1234")
+	c.Assert(other, check.Not(check.Equals), "")
+	c.Assert(other, check.Not(check.Equals), server)
+}
+
+func (s *MySuite) TestPathHash(c *check.C) {
+	// Given
+	lb := newTestLoadBalancer("server1:8080", "server2:8080", "server3:8080")
+	balancer := newHashBalancer(requestPath)
+	request := func(target, addr string) string {
+		r := httptest.NewRequest("GET", target, nil)
+		r.RemoteAddr = addr
+		return balancer.Pick(r, lb.getHealthyBackends()).Addr
+	}
+
+	// When
+	server := request("/api/v1/some-data?key=a", "192.0.2.1:1234")
+
+	// Then
+	c.Assert(server, check.Not(check.Equals), "")
+	c.Assert(request("/api/v1/some-data?key=b", "192.0.2.2:1234"), check.Equals, server)
+
+	paths := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		paths[request(fmt.Sprintf("/api/v1/item-%d", i), "192.0.2.1:1234")] = true
+	}
+	c.Assert(len(paths), check.Equals, 3)
+}
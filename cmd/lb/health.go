@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// longest part of a health check body searched for -health-body
+const maxHealthBody = 64 << 10
+
+// HealthChecker reports whether a server can take requests, it gives up when ctx is done
+type HealthChecker interface {
+	Check(ctx context.Context, server string) bool
+}
+
+// HealthCheckFunc lets an ordinary function be used as a HealthChecker
+type HealthCheckFunc func(ctx context.Context, server string) bool
+
+func (f HealthCheckFunc) Check(ctx context.Context, server string) bool {
+	return f(ctx, server)
+}
+
+// passes servers answering GET -health-path with -health-status and a body containing -health-body
+type httpHealthChecker struct {
+	client *http.Client
+}
+
+func (c httpHealthChecker) Check(ctx context.Context, server string) bool {
+	req, _ := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("%s://%s%s", scheme(), server, *healthPath), nil)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != *healthStatus {
+		return false
+	}
+	if *healthBody == "" {
+		return true
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+	return err == nil && strings.Contains(string(body), *healthBody)
+}
+
+// check the server once within the health check timeout
+func (lb *LoadBalancer) health(server string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout())
+	defer cancel()
+	return lb.checker.Check(ctx, server)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// caps the requests forwarded at once, a few more may wait for a slot for up to wait
+type inflightLimiter struct {
+	slots    *semaphore.Weighted
+	maxQueue int64
+	wait     time.Duration
+	queued   atomic.Int64
+}
+
+// nil when max is not positive, which disables the limit
+func newInflightLimiter(max, maxQueue int, wait time.Duration) *inflightLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &inflightLimiter{
+		slots:    semaphore.NewWeighted(int64(max)),
+		maxQueue: int64(maxQueue),
+		wait:     wait,
+	}
+}
+
+// take a slot, waiting in the queue when all are busy. False when the queue is
+// full or no slot was freed in time, otherwise release must be called when done.
+func (l *inflightLimiter) acquire(ctx context.Context) bool {
+	if l.slots.TryAcquire(1) {
+		return true
+	}
+	if l.queued.Add(1) > l.maxQueue {
+		l.queued.Add(-1)
+		return false
+	}
+	defer l.queued.Add(-1)
+	ctx, cancel := context.WithTimeout(ctx, l.wait)
+	defer cancel()
+	return l.slots.Acquire(ctx, 1) == nil
+}
+
+func (l *inflightLimiter) release() {
+	l.slots.Release(1)
+}
+
+// answer 503 with Retry-After to requests over the limit, a nil limiter disables the check
+func limitMiddleware(l *inflightLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !l.acquire(r.Context()) {
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(l.wait.Seconds())))))
+			http.Error(rw, "too many requests in flight", http.StatusServiceUnavailable)
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// answer 413 to requests with bodies over limit bytes before they are forwarded,
+// a non-positive limit disables the check
+func bodyLimitMiddleware(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			http.Error(rw, fmt.Sprintf("request body exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// bodies of unknown length are cut off while forwarding
+		r.Body = http.MaxBytesReader(rw, r.Body, limit)
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// report whether err comes from a body cut off by bodyLimitMiddleware
+func bodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// servers with a free slot under -max-per-backend
+func (lb *LoadBalancer) withCapacity(backends []Backend) []Backend {
+	if *maxPerServer <= 0 {
+		return backends
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	free := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		if lb.active[b.Addr] < *maxPerServer {
+			free = append(free, b)
+		}
+	}
+	return free
+}
+
+// count a request in flight to the server, false when it has no free slot
+func (lb *LoadBalancer) reserve(server string) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if *maxPerServer > 0 && lb.active[server] >= *maxPerServer {
+		return false
+	}
+	lb.active[server]++
+	return true
+}
+
+func (lb *LoadBalancer) release(server string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.active[server]--
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestLimitMiddleware(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	l := newInflightLimiter(1, 1, 50*time.Millisecond)
+	handler := limitMiddleware(l, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	serve := func(done chan<- *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		done <- rec
+	}
+	first := make(chan *httptest.ResponseRecorder, 1)
+	go serve(first)
+	<-started
+
+	// When the only slot is busy
+	timedOut := make(chan *httptest.ResponseRecorder, 1)
+	go serve(timedOut)
+	rec := <-timedOut
+
+	// Then queued requests give up after the wait
+	c.Assert(rec.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(rec.Header().Get("Retry-After"), check.Equals, "1")
+
+	// When the queue is full as well
+	l.wait = time.Minute
+	queued := make(chan *httptest.ResponseRecorder, 1)
+	go serve(queued)
+	for l.queued.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	rejected := make(chan *httptest.ResponseRecorder, 1)
+	serve(rejected)
+
+	// Then
+	c.Assert((<-rejected).Code, check.Equals, http.StatusServiceUnavailable)
+
+	// When the slot is freed the queued request is served
+	close(release)
+	c.Assert((<-first).Code, check.Equals, http.StatusOK)
+	c.Assert((<-queued).Code, check.Equals, http.StatusOK)
+	c.Assert(newInflightLimiter(0, 1, time.Second), check.IsNil)
+}
+
+func (s *MySuite) TestBodyLimit(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+	lb := newTestLoadBalancer(strings.TrimPrefix(server.URL, "http://"))
+	handler := bodyLimitMiddleware(8, http.HandlerFunc(lb.handle))
+	serve := func(body io.Reader, length int64) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/api/v1/some-data", body)
+		r.ContentLength = length
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// When
+	rec := serve(strings.NewReader("small"), 5)
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, "small")
+	c.Assert(serve(strings.NewReader("far too large"), 13).Code, check.Equals, http.StatusRequestEntityTooLarge)
+	// body of unknown length
+	c.Assert(serve(ioutil.NopCloser(strings.NewReader("far too large")), -1).Code, check.Equals, http.StatusRequestEntityTooLarge)
+}
+
+func (s *MySuite) TestMaxPerBackend(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	lb := newTestLoadBalancer("busy:80", addr)
+	lb.active["busy:80"] = 1
+	lb.balancer = preferBalancer{"busy:80"}
+	*maxPerServer = 1
+	defer func() {
+		*maxPerServer = 0
+	}()
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+		return rec
+	}
+
+	// When the preferred server is at capacity
+	rec := serve()
+
+	// Then another one is picked
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(lb.reserve("busy:80"), check.Equals, false)
+
+	// When every server is at capacity
+	c.Assert(lb.reserve(addr), check.Equals, true)
+	rec = serve()
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusServiceUnavailable)
+	c.Assert(rec.Header().Get("Retry-After"), check.Equals, "1")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/metrics"
+)
+
+// backend and the second label of a series, the status code or the picking algorithm
+type seriesKey struct {
+	backend string
+	label   string
+}
+
+// Metrics collects what the balancer did, written in the Prometheus text format by WriteTo
+type Metrics struct {
+	// pool whose traffic and health are written along, none when nil
+	pool *LoadBalancer
+
+	mu        sync.Mutex
+	requests  map[seriesKey]uint64
+	errors    map[string]uint64
+	picks     map[seriesKey]uint64
+	latencies map[string]*metrics.Histogram
+}
+
+func NewMetrics(pool *LoadBalancer) *Metrics {
+	return &Metrics{
+		pool:      pool,
+		requests:  make(map[seriesKey]uint64),
+		errors:    make(map[string]uint64),
+		picks:     make(map[seriesKey]uint64),
+		latencies: make(map[string]*metrics.Histogram),
+	}
+}
+
+// ObserveRequest counts a response of the backend and the time it took
+func (m *Metrics) ObserveRequest(backend string, code int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[seriesKey{backend, strconv.Itoa(code)}]++
+	h, ok := m.latencies[backend]
+	if !ok {
+		h = metrics.NewHistogram()
+		m.latencies[backend] = h
+	}
+	h.Observe(duration)
+}
+
+// ObserveError counts a request the backend failed to answer
+func (m *Metrics) ObserveError(backend string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[backend]++
+}
+
+// ObservePick counts the backend being picked by the algorithm, "affinity" for pinned clients
+func (m *Metrics) ObservePick(backend, algorithm string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.picks[seriesKey{backend, algorithm}]++
+}
+
+// WriteTo writes the metrics together with the traffic and health of the pool
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &metrics.CountingWriter{W: w}
+	var backends []BackendStatus
+	if m.pool != nil {
+		backends = m.pool.listBackends()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(cw, "# HELP lb_requests_total Responses forwarded from each backend by status code.")
+	fmt.Fprintln(cw, "# TYPE lb_requests_total counter")
+	writeSeries(cw, "lb_requests_total", "code", m.requests)
+	writeCounters(cw, "lb_errors_total", "Requests a backend failed to answer.", m.errors)
+	fmt.Fprintln(cw, "# HELP lb_picks_total Times each backend was picked, by algorithm.")
+	fmt.Fprintln(cw, "# TYPE lb_picks_total counter")
+	writeSeries(cw, "lb_picks_total", "algorithm", m.picks)
+
+	fmt.Fprintln(cw, "# HELP lb_request_duration_seconds Time until a backend answered.")
+	fmt.Fprintln(cw, "# TYPE lb_request_duration_seconds histogram")
+	for _, backend := range sortedKeys(m.latencies) {
+		metrics.WriteHistogram(cw, "lb_request_duration_seconds", fmt.Sprintf("backend=%q", backend), m.latencies[backend])
+	}
+
+	fmt.Fprintln(cw, "# HELP lb_bytes_forwarded_total Response bytes forwarded from each backend in the pool.")
+	fmt.Fprintln(cw, "# TYPE lb_bytes_forwarded_total counter")
+	for _, b := range backends {
+		fmt.Fprintf(cw, "lb_bytes_forwarded_total{backend=%q} %d\n", b.Addr, b.Bytes)
+	}
+	fmt.Fprintln(cw, "# HELP lb_backend_healthy Whether the backend passed its last health check.")
+	fmt.Fprintln(cw, "# TYPE lb_backend_healthy gauge")
+	for _, b := range backends {
+		healthy := 0
+		if b.Healthy {
+			healthy = 1
+		}
+		fmt.Fprintf(cw, "lb_backend_healthy{backend=%q} %d\n", b.Addr, healthy)
+	}
+	return cw.N, cw.Err
+}
+
+func writeCounters(w io.Writer, name, help string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, backend := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{backend=%q} %d\n", name, backend, values[backend])
+	}
+}
+
+func writeSeries(w io.Writer, name, label string, values map[seriesKey]uint64) {
+	keys := make([]seriesKey, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].backend != keys[j].backend {
+			return keys[i].backend < keys[j].backend
+		}
+		return keys[i].label < keys[j].label
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{backend=%q,%s=%q} %d\n", name, key.backend, label, key.label, values[key])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("content-type", "text/plain; version=0.0.4")
+	_, _ = m.WriteTo(rw)
+}
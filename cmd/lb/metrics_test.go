@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http/httptest"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestMetrics(c *check.C) {
+	// Given
+	m := NewMetrics(nil)
+
+	// When
+	m.ObserveRequest("server1:8080", 200, 30*time.Millisecond)
+	m.ObserveRequest("server1:8080", 200, 3*time.Second)
+	m.ObserveRequest("server1:8080", 404, time.Millisecond)
+	m.ObserveError("server2:8080")
+	m.ObservePick("server1:8080", "round-robin")
+	m.ObservePick("server1:8080", "affinity")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Then
+	body := rec.Body.String()
+	c.Assert(rec.Header().Get("content-type"), check.Matches, "text/plain.*")
+	c.Assert(body, check.Matches, `(?s).*lb_requests_total\{backend="server1:8080",code="200"\} 2\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_requests_total\{backend="server1:8080",code="404"\} 1\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_errors_total\{backend="server2:8080"\} 1\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_picks_total\{backend="server1:8080",algorithm="affinity"\} 1\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_request_duration_seconds_bucket\{backend="server1:8080",le="0.005"\} 1\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_request_duration_seconds_bucket\{backend="server1:8080",le="0.05"\} 2\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_request_duration_seconds_bucket\{backend="server1:8080",le="\+Inf"\} 3\n.*`)
+	c.Assert(body, check.Matches, `(?s).*lb_request_duration_seconds_count\{backend="server1:8080"\} 3\n.*`)
+}
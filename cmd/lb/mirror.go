@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+)
+
+// mirrors sent at once, requests to mirror while all are busy are not mirrored
+const maxMirrorsInFlight = 64
+
+// copies a share of the requests to a shadow server and discards its answers
+type mirror struct {
+	client  *http.Client
+	addr    string
+	percent float64
+	// longest body buffered to be sent twice, larger requests are not mirrored
+	maxBody int64
+	slots   chan struct{}
+}
+
+// set from -mirror once flags are parsed, nil disables mirroring
+var shadow *mirror
+
+func newMirror(client *http.Client, addr string, percent float64, maxBody int64) *mirror {
+	if addr == "" || percent <= 0 {
+		return nil
+	}
+	return &mirror{client: client, addr: addr, percent: percent, maxBody: maxBody, slots: make(chan struct{}, maxMirrorsInFlight)}
+}
+
+// send a copy of the request to the shadow server in the background when it falls
+// into the mirrored share. The body is buffered so r can still be forwarded.
+func (m *mirror) maybeSend(r *http.Request) {
+	if r.Header.Get("Upgrade") != "" || rand.Float64()*100 >= m.percent {
+		return
+	}
+	if r.ContentLength < 0 || r.ContentLength > m.maxBody {
+		return
+	}
+	var body []byte
+	if r.ContentLength > 0 {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		// whatever was read is still forwarded
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Failed to read body to mirror: %s", err)
+			return
+		}
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	// the copy outlives the client request, so it gets a context of its own
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout(r))
+	req := r.Clone(ctx)
+	req.RequestURI = ""
+	req.URL.Host = m.addr
+	req.URL.Scheme = scheme()
+	req.Host = m.addr
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	go func() {
+		defer func() { <-m.slots }()
+		defer cancel()
+		resp, err := m.client.Do(req)
+		if err != nil {
+			log.Printf("Failed to mirror request %s to %s: %s", r.Header.Get(httptools.RequestIDHeader), m.addr, err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestMirror(c *check.C) {
+	// Given
+	mirrored := make(chan string, 1)
+	shadowServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mirrored <- r.Method + " " + r.URL.Path + " " + string(body)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadowServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = rw.Write(body)
+	}))
+	defer server.Close()
+
+	lb := newTestLoadBalancer(strings.TrimPrefix(server.URL, "http://"))
+	shadow = newMirror(lb.client, strings.TrimPrefix(shadowServer.URL, "http://"), 100, 8)
+	defer func() {
+		shadow = nil
+	}()
+	serve := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		lb.handle(rec, httptest.NewRequest("POST", "/api/v1/some-data", strings.NewReader(body)))
+		return rec
+	}
+
+	// When
+	rec := serve("payload")
+
+	// Then the client gets the answer of the server only
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals, "payload")
+	select {
+	case got := <-mirrored:
+		c.Assert(got, check.Equals, "POST /api/v1/some-data payload")
+	case <-time.After(time.Second):
+		c.Fatal("request was not mirrored")
+	}
+
+	// When the body is too large to buffer
+	rec = serve("a larger payload")
+
+	// Then
+	c.Assert(rec.Body.String(), check.Equals, "a larger payload")
+	select {
+	case got := <-mirrored:
+		c.Fatalf("mirrored %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Assert(newMirror(http.DefaultClient, "", 100, 8), check.IsNil)
+	c.Assert(newMirror(http.DefaultClient, "shadow:8080", 0, 8), check.IsNil)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// buckets the sliding window of each backend is divided into
+const outlierBuckets = 10
+
+// requests answered within one part of the window
+type outcomeBucket struct {
+	start         time.Time
+	total, failed int
+}
+
+// takes backends out of rotation for a while when too many of their recent
+// requests failed with 5xx or timeouts, even while their health checks pass
+type outlierDetector struct {
+	window      time.Duration
+	threshold   float64 // share of failed requests that ejects a backend
+	minRequests int     // requests in the window needed before a backend is judged
+	ejectFor    time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*[outlierBuckets]outcomeBucket
+	ejected map[string]time.Time // until when
+}
+
+// set from the -outlier flags once they are parsed, nil disables ejection
+var outliers *outlierDetector
+
+// nil when threshold is not positive
+func newOutlierDetector(threshold float64, window time.Duration, minRequests int, ejectFor time.Duration) *outlierDetector {
+	if threshold <= 0 {
+		return nil
+	}
+	return &outlierDetector{
+		window:      window,
+		threshold:   threshold,
+		minRequests: minRequests,
+		ejectFor:    ejectFor,
+		buckets:     make(map[string]*[outlierBuckets]outcomeBucket),
+		ejected:     make(map[string]time.Time),
+	}
+}
+
+// count a request to the backend and eject it when the failures in the window exceed the threshold
+func (d *outlierDetector) record(addr string, failed bool, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if until, ok := d.ejected[addr]; ok && now.Before(until) {
+		// answers to requests sent before the ejection
+		return
+	}
+	buckets, ok := d.buckets[addr]
+	if !ok {
+		buckets = new([outlierBuckets]outcomeBucket)
+		d.buckets[addr] = buckets
+	}
+	width := d.window / outlierBuckets
+	start := now.Truncate(width)
+	b := &buckets[start.UnixNano()/int64(width)%outlierBuckets]
+	if !b.start.Equal(start) {
+		*b = outcomeBucket{start: start}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+
+	total, failures := 0, 0
+	for _, b := range buckets {
+		if now.Sub(b.start) < d.window {
+			total += b.total
+			failures += b.failed
+		}
+	}
+	if total >= d.minRequests && float64(failures) >= d.threshold*float64(total) {
+		log.Printf("Ejecting %s for %s, %d of its last %d requests failed", addr, d.ejectFor, failures, total)
+		d.ejected[addr] = now.Add(d.ejectFor)
+		// judged anew once it is back
+		delete(d.buckets, addr)
+	}
+}
+
+// report whether the backend is ejected at now
+func (d *outlierDetector) isEjected(addr string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	until, ok := d.ejected[addr]
+	if ok && !now.Before(until) {
+		delete(d.ejected, addr)
+		return false
+	}
+	return ok
+}
+
+func (d *outlierDetector) remove(addr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.buckets, addr)
+	delete(d.ejected, addr)
+}
+
+// backends that are not ejected, all of them when every one is so traffic still flows
+func withoutOutliers(backends []Backend, now time.Time) []Backend {
+	if outliers == nil {
+		return backends
+	}
+	kept := make([]Backend, 0, len(backends))
+	for _, b := range backends {
+		if !outliers.isEjected(b.Addr, now) {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) == 0 {
+		return backends
+	}
+	return kept
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestOutlierDetector(c *check.C) {
+	// Given
+	d := newOutlierDetector(0.5, 10*time.Second, 4, time.Minute)
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// When failures are old enough to have left the window
+	d.record("a:80", true, now)
+	d.record("a:80", true, now)
+	now = now.Add(15 * time.Second)
+	d.record("a:80", false, now)
+	d.record("a:80", true, now)
+	d.record("a:80", false, now)
+
+	// Then
+	c.Assert(d.isEjected("a:80", now), check.Equals, false)
+
+	// When half of the requests in the window failed
+	d.record("a:80", true, now.Add(time.Second))
+
+	// Then
+	c.Assert(d.isEjected("a:80", now.Add(time.Second)), check.Equals, true)
+	c.Assert(d.isEjected("b:80", now), check.Equals, false)
+	c.Assert(d.isEjected("a:80", now.Add(2*time.Minute)), check.Equals, false)
+
+	c.Assert(newOutlierDetector(0, time.Second, 1, time.Second), check.IsNil)
+}
+
+func (s *MySuite) TestWithoutOutliers(c *check.C) {
+	// Given
+	outliers = newOutlierDetector(0.5, 10*time.Second, 1, time.Minute)
+	defer func() { outliers = nil }()
+	backends := []Backend{{"a:80", 1}, {"b:80", 1}}
+
+	// When
+	outliers.record("a:80", true, time.Now())
+
+	// Then
+	c.Assert(withoutOutliers(backends, time.Now()), check.DeepEquals, []Backend{{"b:80", 1}})
+
+	// When every backend is ejected
+	outliers.record("b:80", true, time.Now())
+
+	// Then traffic still goes to them
+	c.Assert(withoutOutliers(backends, time.Now()), check.DeepEquals, backends)
+}
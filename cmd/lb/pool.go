@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// state of a server in the pool
+type BackendStatus struct {
+	Addr    string `json:"addr"`
+	Weight  int    `json:"weight"`
+	Healthy bool   `json:"healthy"`
+	Bytes   int64  `json:"bytes"`
+	Active  int    `json:"active"` // requests in flight
+	Canary  bool   `json:"canary,omitempty"`
+	Ejected bool   `json:"ejected,omitempty"` // for failing too many requests
+	Color   string `json:"color,omitempty"`   // blue or green pool
+}
+
+// add a server to the pool after checking its health, requests go to it as soon as it is healthy
+func (lb *LoadBalancer) addBackend(b Backend) error {
+	return lb.addToPool(b, false, "")
+}
+
+// add a server to the stable pool or with canary to the canary pool. A server
+// given a color belongs to the blue or green pool and only gets traffic while it is live.
+func (lb *LoadBalancer) addToPool(b Backend, canary bool, color string) error {
+	lb.mu.Lock()
+	_, exists := lb.checks[b.Addr]
+	lb.mu.Unlock()
+	if exists {
+		return fmt.Errorf("backend %s is already in the pool", b.Addr)
+	}
+
+	healthy := lb.health(b.Addr)
+	ctx, cancel := context.WithCancel(context.Background())
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if _, exists := lb.checks[b.Addr]; exists {
+		cancel()
+		return fmt.Errorf("backend %s is already in the pool", b.Addr)
+	}
+	lb.checks[b.Addr] = cancel
+	lb.weights[b.Addr] = b.Weight
+	lb.healthy[b.Addr] = healthy
+	if canary {
+		lb.canaries[b.Addr] = true
+	}
+	if color != "" {
+		lb.colors[b.Addr] = color
+	}
+	go lb.watchHealth(ctx, b.Addr)
+	return nil
+}
+
+// take a server out of the pool, reports false when it is not in the pool
+func (lb *LoadBalancer) removeBackend(addr string) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	cancel, ok := lb.checks[addr]
+	if !ok {
+		return false
+	}
+	cancel()
+	delete(lb.checks, addr)
+	delete(lb.healthy, addr)
+	delete(lb.weights, addr)
+	delete(lb.canaries, addr)
+	delete(lb.colors, addr)
+	lb.traffic.Remove(addr)
+	if outliers != nil {
+		outliers.remove(addr)
+	}
+	return true
+}
+
+// change the weight of a server in the pool, a server of weight 0 gets no new
+// requests but stays health checked. Reports false when it is not in the pool.
+func (lb *LoadBalancer) updateWeight(addr string, weight int) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if _, ok := lb.checks[addr]; !ok {
+		return false
+	}
+	lb.weights[addr] = weight
+	return true
+}
+
+// servers in the pool ordered by address
+func (lb *LoadBalancer) listBackends() []BackendStatus {
+	bytes := lb.traffic.Snapshot()
+	now := lb.now()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	backends := make([]BackendStatus, 0, len(lb.checks))
+	for addr := range lb.checks {
+		backends = append(backends, BackendStatus{
+			Addr:    addr,
+			Weight:  lb.weightOf(addr),
+			Healthy: lb.healthy[addr],
+			Bytes:   bytes[addr],
+			Active:  lb.active[addr],
+			Canary:  lb.canaries[addr],
+			Color:   lb.colors[addr],
+			Ejected: outliers != nil && outliers.isEjected(addr, now),
+		})
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].Addr < backends[j].Addr
+	})
+	return backends
+}
+
+// check the server every -health-interval until it is removed
+func (lb *LoadBalancer) watchHealth(ctx context.Context, server string) {
+	ticker := time.NewTicker(*healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		healthy := lb.health(server)
+		events.Debug("health checked", "backend", server, "healthy", healthy)
+
+		lb.mu.Lock()
+		// a check still running when the server was removed must not bring it back
+		changed := ctx.Err() == nil && lb.healthy[server] != healthy
+		if ctx.Err() == nil {
+			lb.healthy[server] = healthy
+		}
+		lb.mu.Unlock()
+		if changed && healthy {
+			events.Info("backend healthy", "backend", server)
+		} else if changed {
+			events.Warn("backend unhealthy", "backend", server)
+		}
+	}
+}
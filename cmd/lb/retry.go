@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// retries the budget holds when the balancer starts, so early failures can be retried
+const retryBudgetBurst = 10
+
+// limits retries to a share of the requests, so retrying cannot multiply the
+// load on servers that are already failing. Every request adds ratio of a retry
+// to the budget and every retry takes a whole one.
+type retryBudget struct {
+	ratio float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// set from -retry-budget once flags are parsed
+var retries = newRetryBudget(20)
+
+// percent of the requests that may be retried
+func newRetryBudget(percent float64) *retryBudget {
+	return &retryBudget{ratio: percent / 100, tokens: retryBudgetBurst}
+}
+
+// count a request against the budget
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetBurst {
+		b.tokens = retryBudgetBurst
+	}
+}
+
+// take a retry from the budget, false when it is used up
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// give back a retry that was not needed
+func (b *retryBudget) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+// wait a random time up to -retry-backoff doubled for each earlier retry but
+// at most -retry-backoff-max, false when ctx is done first
+func backoff(ctx context.Context, attempt int) bool {
+	ceiling := *retryBackoffMax
+	if shift := attempt - 1; shift < 32 && *retryBackoff<<shift < ceiling {
+		ceiling = *retryBackoff << shift
+	}
+	if ceiling <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(ceiling) + 1)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestRetryBudget(c *check.C) {
+	// Given
+	budget := newRetryBudget(50)
+
+	// When the initial retries are used up
+	for i := 0; i < retryBudgetBurst; i++ {
+		c.Assert(budget.withdraw(), check.Equals, true)
+	}
+
+	// Then
+	c.Assert(budget.withdraw(), check.Equals, false)
+
+	// When two more requests came in
+	budget.deposit()
+	c.Assert(budget.withdraw(), check.Equals, false)
+	budget.deposit()
+
+	// Then one of them may be retried
+	c.Assert(budget.withdraw(), check.Equals, true)
+	c.Assert(budget.withdraw(), check.Equals, false)
+	budget.refund()
+	c.Assert(budget.withdraw(), check.Equals, true)
+}
+
+func (s *MySuite) TestBackoff(c *check.C) {
+	// Given
+	saved, savedMax := *retryBackoff, *retryBackoffMax
+	*retryBackoff, *retryBackoffMax = 10*time.Millisecond, 20*time.Millisecond
+	defer func() { *retryBackoff, *retryBackoffMax = saved, savedMax }()
+
+	// When
+	start := time.Now()
+	for attempt := 1; attempt <= 5; attempt++ {
+		c.Assert(backoff(context.Background(), attempt), check.Equals, true)
+	}
+
+	// Then waits are capped
+	c.Assert(time.Since(start) < 5*30*time.Millisecond, check.Equals, true)
+
+	// When the client is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	*retryBackoff, *retryBackoffMax = time.Hour, time.Hour
+
+	// Then
+	c.Assert(backoff(ctx, 1), check.Equals, false)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+)
+
+const statsPath = "/lb/stats"
+
+// what GET /lb/stats answers
+type Stats struct {
+	Algorithm string          `json:"algorithm"`
+	Affinity  bool            `json:"affinity"`
+	Backends  []BackendStatus `json:"backends"`
+}
+
+func (lb *LoadBalancer) currentStats() Stats {
+	return Stats{
+		Algorithm: *algorithm,
+		Affinity:  affinity != nil,
+		Backends:  lb.listBackends(),
+	}
+}
+
+func (lb *LoadBalancer) serveStats(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, lb.currentStats())
+}
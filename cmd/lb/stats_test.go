@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestStats(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	lb := newTestLoadBalancer()
+	c.Assert(lb.addBackend(Backend{Addr: addr, Weight: 1}), check.IsNil)
+	defer lb.removeBackend(addr)
+	frontend := newFrontendHandler(lb)
+	frontend.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	// When
+	rec := httptest.NewRecorder()
+	frontend.ServeHTTP(rec, httptest.NewRequest("GET", "/lb/stats", nil))
+
+	// Then
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), check.Equals,
+		`{"algorithm":"min-bytes","affinity":false,"backends":[{"addr":"`+addr+`","weight":1,"healthy":true,"bytes":5,"active":0}]}`+"\n")
+
+	rec = httptest.NewRecorder()
+	frontend.ServeHTTP(rec, httptest.NewRequest("POST", "/lb/stats", nil))
+	c.Assert(rec.Code, check.Equals, http.StatusMethodNotAllowed)
+}
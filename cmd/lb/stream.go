@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// report whether the response stays open for as long as the server keeps sending,
+// such as a protocol switch to WebSocket or a server-sent event stream
+func isStream(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// flushes after every write so chunks reach the client as soon as the server sends them.
+// Streams and by default responses of unknown length are copied through it.
+type flushWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func newFlushWriter(w http.ResponseWriter) io.Writer {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return w
+	}
+	return flushWriter{w: w, f: f}
+}
+
+func (fw flushWriter) Write(data []byte) (int, error) {
+	n, err := fw.w.Write(data)
+	fw.f.Flush()
+	return n, err
+}
+
+// flushes at most interval after a write, so small writes are sent together but
+// none waits for the response to end
+type intervalFlushWriter struct {
+	w        http.ResponseWriter
+	f        http.Flusher
+	interval time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending bool
+}
+
+func (iw *intervalFlushWriter) Write(data []byte) (int, error) {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	n, err := iw.w.Write(data)
+	if iw.pending {
+		return n, err
+	}
+	iw.pending = true
+	if iw.timer == nil {
+		iw.timer = time.AfterFunc(iw.interval, iw.flush)
+	} else {
+		iw.timer.Reset(iw.interval)
+	}
+	return n, err
+}
+
+func (iw *intervalFlushWriter) flush() {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if !iw.pending {
+		return
+	}
+	iw.f.Flush()
+	iw.pending = false
+}
+
+// stop flushing, the handler flushes what is left when it returns
+func (iw *intervalFlushWriter) stop() {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	iw.pending = false
+	if iw.timer != nil {
+		iw.timer.Stop()
+	}
+}
+
+// writer the response body is copied to according to -flush-interval, stop
+// must be called once the copy is done
+func responseWriter(rw http.ResponseWriter, resp *http.Response) (io.Writer, func()) {
+	f, ok := rw.(http.Flusher)
+	switch {
+	case !ok:
+		return rw, func() {}
+	case *flushInterval < 0 || isStream(resp) || resp.ContentLength < 0 && *flushInterval == 0:
+		return newFlushWriter(rw), func() {}
+	case *flushInterval > 0:
+		iw := &intervalFlushWriter{w: rw, f: f, interval: *flushInterval}
+		return iw, iw.stop
+	}
+	return rw, func() {}
+}
+
+// take over the client connection after the server switched protocols and copy
+// data both ways until either side closes. Returns the bytes sent to the client.
+func proxyUpgrade(rw http.ResponseWriter, resp *http.Response) (int64, error) {
+	backend, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		http.Error(rw, "server switched protocols without a connection", http.StatusBadGateway)
+		return 0, fmt.Errorf("response to the upgrade is not writable")
+	}
+	defer backend.Close()
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "connection cannot be upgraded", http.StatusBadGateway)
+		return 0, fmt.Errorf("connection cannot be taken over")
+	}
+	conn, client, err := hijacker.Hijack()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if err := writeResponseHead(client.Writer, resp); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// client.Reader holds what the client sent after the request
+		if _, err := io.Copy(backend, client.Reader); err != nil {
+			log.Printf("Failed to copy upgraded request: %s", err)
+		}
+		backend.Close()
+	}()
+	n, err := io.Copy(conn, backend)
+	conn.Close()
+	wg.Wait()
+	return n, err
+}
+
+func writeResponseHead(w *bufio.Writer, resp *http.Response) error {
+	if _, err := fmt.Fprintf(w, "HTTP/1.1 %s\r\n", resp.Status); err != nil {
+		return err
+	}
+	if err := resp.Header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// let the response stream past the write timeout of the frontend server
+func clearWriteDeadline(rw http.ResponseWriter) {
+	if err := http.NewResponseController(rw).SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported {
+		log.Printf("Failed to clear write deadline: %s", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+// serve handle in front of a single healthy server
+func frontendFor(backend *httptest.Server) (*httptest.Server, func()) {
+	lb := newTestLoadBalancer(strings.TrimPrefix(backend.URL, "http://"))
+	frontend := httptest.NewServer(http.HandlerFunc(lb.handle))
+	return frontend, frontend.Close
+}
+
+func (s *MySuite) TestServerSentEvents(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(rw, "data: first\n\n")
+		rw.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(rw, "data: second\n\n")
+	}))
+	defer backend.Close()
+	frontend, done := frontendFor(backend)
+	defer done()
+	savedTimeout := timeout
+	timeout = 50 * time.Millisecond
+	defer func() { timeout = savedTimeout }()
+
+	// When
+	resp, err := http.Get(frontend.URL + "/events")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	// Then
+	line, err := events.ReadString('\n')
+	c.Assert(err, check.IsNil)
+	c.Assert(line, check.Equals, "data: first\n")
+
+	// the stream outlives the request timeout
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	_, _ = events.ReadString('\n')
+	line, err = events.ReadString('\n')
+	c.Assert(err, check.IsNil)
+	c.Assert(line, check.Equals, "data: second\n")
+}
+
+func (s *MySuite) TestUpgrade(c *check.C) {
+	// Given
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, buf, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		buf.Flush()
+		for {
+			line, err := buf.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Fprint(buf, "echo "+line)
+			buf.Flush()
+		}
+	}))
+	defer backend.Close()
+	frontend, done := frontendFor(backend)
+	defer done()
+
+	// When
+	conn, err := net.Dial("tcp", strings.TrimPrefix(frontend.URL, "http://"))
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /chat HTTP/1.1\r\nHost: lb\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\nhello\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	c.Assert(err, check.IsNil)
+
+	// Then
+	c.Assert(resp.StatusCode, check.Equals, http.StatusSwitchingProtocols)
+	line, err := reader.ReadString('\n')
+	c.Assert(err, check.IsNil)
+	c.Assert(line, check.Equals, "echo hello\n")
+	fmt.Fprint(conn, "again\n")
+	line, err = reader.ReadString('\n')
+	c.Assert(err, check.IsNil)
+	c.Assert(line, check.Equals, "echo again\n")
+}
+
+func (s *MySuite) TestFlushInterval(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Length", "12")
+		fmt.Fprint(rw, "first ")
+		rw.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(rw, "second")
+	}))
+	defer backend.Close()
+	defer close(release)
+	frontend, done := frontendFor(backend)
+	defer done()
+	saved := *flushInterval
+	*flushInterval = 10 * time.Millisecond
+	defer func() { *flushInterval = saved }()
+
+	// When
+	start := time.Now()
+	resp, err := http.Get(frontend.URL + "/poll")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+
+	// Then the first part arrives while the server still holds the rest
+	first := make([]byte, 6)
+	_, err = io.ReadFull(resp.Body, first)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(first), check.Equals, "first ")
+	c.Assert(time.Since(start) < time.Second, check.Equals, true)
+}
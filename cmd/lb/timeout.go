@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// timeout of requests to paths starting with prefix, overriding -timeout-sec
+type routeTimeout struct {
+	prefix  string
+	timeout time.Duration
+}
+
+// parse comma separated prefix=duration pairs, e.g. /api/v1/reports=30s,/admin=1m
+func parseRouteTimeouts(list string) ([]routeTimeout, error) {
+	var routes []routeTimeout
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i <= 0 || !strings.HasPrefix(item, "/") {
+			return nil, fmt.Errorf("route timeout %q must be given as /prefix=duration", item)
+		}
+		timeout, err := time.ParseDuration(item[i+1:])
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("route timeout %q must have a positive duration", item)
+		}
+		routes = append(routes, routeTimeout{prefix: item[:i], timeout: timeout})
+	}
+	return routes, nil
+}
+
+// timeout of the route with the longest prefix matching the request path, the default otherwise
+func requestTimeout(r *http.Request) time.Duration {
+	timeoutMu.Lock()
+	defer timeoutMu.Unlock()
+	result, longest := timeout, -1
+	for _, route := range routeTimeouts {
+		if strings.HasPrefix(r.URL.Path, route.prefix) && len(route.prefix) > longest {
+			result, longest = route.timeout, len(route.prefix)
+		}
+	}
+	return result
+}
+
+// time given to a health check, never more than a request gets
+func healthCheckTimeout() time.Duration {
+	timeoutMu.Lock()
+	defer timeoutMu.Unlock()
+	if timeout < *healthTimeout {
+		return timeout
+	}
+	return *healthTimeout
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestRouteTimeouts(c *check.C) {
+	// Given
+	routes, err := parseRouteTimeouts("/api/v1=10s, /api/v1/reports=1m,")
+	c.Assert(err, check.IsNil)
+	saved := routeTimeouts
+	routeTimeouts = routes
+	defer func() { routeTimeouts = saved }()
+	timeoutOf := func(target string) time.Duration {
+		return requestTimeout(httptest.NewRequest("GET", target, nil))
+	}
+
+	// Then
+	c.Assert(timeoutOf("/api/v1/reports/2023"), check.Equals, time.Minute)
+	c.Assert(timeoutOf("/api/v1/some-data"), check.Equals, 10*time.Second)
+	c.Assert(timeoutOf("/health"), check.Equals, timeout)
+
+	for _, invalid := range []string{"api=1s", "/api", "/api=soon", "/api=-1s"} {
+		_, err := parseRouteTimeouts(invalid)
+		c.Assert(err, check.NotNil, check.Commentf(invalid))
+	}
+}
+
+func (s *MySuite) TestHealthCheckTimeout(c *check.C) {
+	saved := timeout
+	defer func() { timeout = saved }()
+
+	timeout = 3 * time.Second
+	c.Assert(healthCheckTimeout(), check.Equals, *healthTimeout)
+	timeout = time.Millisecond
+	c.Assert(healthCheckTimeout(), check.Equals, time.Millisecond)
+}
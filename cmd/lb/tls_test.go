@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestHSTS(c *check.C) {
+	// Given
+	handler := hstsMiddleware(24*time.Hour, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	// When
+	resp, err := server.Client().Get(server.URL)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+
+	// Then
+	c.Assert(resp.Header.Get("Strict-Transport-Security"), check.Equals, "max-age=86400")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	c.Assert(rec.Header().Get("Strict-Transport-Security"), check.Equals, "")
+}
+
+func (s *MySuite) TestHTTPSRedirect(c *check.C) {
+	serve := func(port int, host, target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", target, nil)
+		r.Host = host
+		rec := httptest.NewRecorder()
+		httpsRedirect(port).ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve(443, "lb.example:8080", "/api/v1/some-data?key=a")
+	c.Assert(rec.Code, check.Equals, http.StatusPermanentRedirect)
+	c.Assert(rec.Header().Get("Location"), check.Equals, "https://lb.example/api/v1/some-data?key=a")
+	c.Assert(serve(8443, "lb.example", "/").Header().Get("Location"), check.Equals, "https://lb.example:8443/")
+}
+
+func (s *MySuite) TestBackendTLSConfig(c *check.C) {
+	// Given
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := filepath.Join(c.MkDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	c.Assert(ioutil.WriteFile(caFile, ca, 0600), check.IsNil)
+
+	// When
+	config, err := backendTLSConfig("", "", caFile)
+	c.Assert(err, check.IsNil)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+
+	// Then
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+
+	// system roots do not know the test certificate
+	config, err = backendTLSConfig("", "", "")
+	c.Assert(err, check.IsNil)
+	transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	c.Assert(err, check.NotNil)
+
+	_, err = backendTLSConfig("cert.pem", "", "")
+	c.Assert(err, check.NotNil)
+	_, err = backendTLSConfig("", "", filepath.Join(c.MkDir(), "missing.pem"))
+	c.Assert(err, check.NotNil)
+}
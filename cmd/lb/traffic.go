@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// time over which recent traffic is counted unless -bytes-window says otherwise
+	defaultTrafficWindow = time.Minute
+	// parts the window is divided into, traffic leaves the window one part at a time
+	trafficBuckets = 12
+)
+
+type trafficBucket struct {
+	start time.Time
+	bytes int64
+}
+
+// TrafficCounter keeps track of the total number of bytes returned by each server
+// and of the bytes returned within the last window, it is safe for concurrent use
+type TrafficCounter struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	bytes  map[string]int64
+	recent map[string]*[trafficBuckets]trafficBucket
+}
+
+func NewTrafficCounter() *TrafficCounter {
+	return NewTrafficCounterWindow(defaultTrafficWindow)
+}
+
+// NewTrafficCounterWindow creates a counter whose Recent counts cover window
+func NewTrafficCounterWindow(window time.Duration) *TrafficCounter {
+	return &TrafficCounter{
+		window: window,
+		bytes:  make(map[string]int64),
+		recent: make(map[string]*[trafficBuckets]trafficBucket),
+	}
+}
+
+// Add counts n more bytes returned by the server and returns its new total
+func (t *TrafficCounter) Add(server string, n int64) int64 {
+	return t.add(server, n, time.Now())
+}
+
+func (t *TrafficCounter) add(server string, n int64, now time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes[server] += n
+
+	buckets, ok := t.recent[server]
+	if !ok {
+		buckets = new([trafficBuckets]trafficBucket)
+		t.recent[server] = buckets
+	}
+	width := t.window / trafficBuckets
+	start := now.Truncate(width)
+	b := &buckets[start.UnixNano()/int64(width)%trafficBuckets]
+	if !b.start.Equal(start) {
+		*b = trafficBucket{start: start}
+	}
+	b.bytes += n
+	return t.bytes[server]
+}
+
+// Snapshot returns a copy of the totals, servers that returned nothing may be missing
+func (t *TrafficCounter) Snapshot() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := make(map[string]int64, len(t.bytes))
+	for server, n := range t.bytes {
+		snapshot[server] = n
+	}
+	return snapshot
+}
+
+// Recent returns the bytes each server returned within the window, so servers
+// added or restarted later are not favoured until they catch up with the totals
+func (t *TrafficCounter) Recent() map[string]int64 {
+	return t.recentAt(time.Now())
+}
+
+func (t *TrafficCounter) recentAt(now time.Time) map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recent := make(map[string]int64, len(t.recent))
+	for server, buckets := range t.recent {
+		for _, b := range buckets {
+			if now.Sub(b.start) < t.window {
+				recent[server] += b.bytes
+			}
+		}
+	}
+	return recent
+}
+
+// Remove forgets the counts of a server
+func (t *TrafficCounter) Remove(server string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.bytes, server)
+	delete(t.recent, server)
+}
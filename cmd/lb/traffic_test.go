@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestTrafficCounter(c *check.C) {
+	// Given
+	counter := NewTrafficCounter()
+	var wg sync.WaitGroup
+
+	// When
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.Add("server1:8080", 2)
+				counter.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Then
+	snapshot := counter.Snapshot()
+	c.Assert(snapshot, check.DeepEquals, map[string]int64{"server1:8080": 2000})
+	snapshot["server1:8080"] = 0
+	c.Assert(counter.Add("server1:8080", 1), check.Equals, int64(2001))
+}
+
+func (s *MySuite) TestTrafficWindow(c *check.C) {
+	// Given
+	counter := NewTrafficCounterWindow(time.Minute)
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// When
+	counter.add("server1:8080", 1000, start)
+	counter.add("server1:8080", 10, start.Add(50*time.Second))
+	counter.add("server2:8080", 20, start.Add(50*time.Second))
+
+	// Then only the window counts for picking while totals keep growing
+	c.Assert(counter.recentAt(start.Add(55*time.Second)), check.DeepEquals, map[string]int64{"server1:8080": 1010, "server2:8080": 20})
+	c.Assert(counter.recentAt(start.Add(90*time.Second)), check.DeepEquals, map[string]int64{"server1:8080": 10, "server2:8080": 20})
+	c.Assert(counter.recentAt(start.Add(5*time.Minute)), check.DeepEquals, map[string]int64{})
+	c.Assert(counter.Snapshot(), check.DeepEquals, map[string]int64{"server1:8080": 1010, "server2:8080": 20})
+}
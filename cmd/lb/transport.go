@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// client shared by forwards and health checks, configured by the transport flags.
+// A nil tlsConfig uses the defaults for -https backends.
+func newClient(tlsConfig *tls.Config) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   *dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   *dialTimeout,
+		ResponseHeaderTimeout: *headerTimeout,
+		MaxIdleConnsPerHost:   *maxIdleConnsPerHost,
+		IdleConnTimeout:       *idleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		// redirects of the servers are passed on to the clients
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
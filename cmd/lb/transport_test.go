@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	check "gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestClient(c *check.C) {
+	// Given
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Redirect(rw, r, "/elsewhere", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+	client := newClient(nil)
+
+	// When
+	resp, err := client.Get(server.URL)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+
+	// Then
+	c.Assert(resp.StatusCode, check.Equals, http.StatusTemporaryRedirect)
+	transport := client.Transport.(*http.Transport)
+	c.Assert(transport.MaxIdleConnsPerHost, check.Equals, *maxIdleConnsPerHost)
+	c.Assert(transport.TLSHandshakeTimeout, check.Equals, *dialTimeout)
+}
+
+func (s *MySuite) TestHeaderTimeout(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	saved := *headerTimeout
+	*headerTimeout = 20 * time.Millisecond
+	defer func() {
+		*headerTimeout = saved
+	}()
+	lb := newTestLoadBalancer(strings.TrimPrefix(server.URL, "http://"))
+
+	// When
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	// Then the request fails well before the total timeout
+	c.Assert(rec.Code, check.Equals, http.StatusGatewayTimeout)
+	c.Assert(time.Since(start) < time.Second, check.Equals, true)
+}
+
+func (s *MySuite) TestTotalTimeout(c *check.C) {
+	// Given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	timeoutMu.Lock()
+	saved := timeout
+	timeout = 20 * time.Millisecond
+	timeoutMu.Unlock()
+	defer func() {
+		timeoutMu.Lock()
+		timeout = saved
+		timeoutMu.Unlock()
+	}()
+	lb := newTestLoadBalancer(strings.TrimPrefix(server.URL, "http://"))
+
+	// When
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	lb.handle(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	// Then the server too slow to answer at all is reported as a timeout
+	c.Assert(rec.Code, check.Equals, http.StatusGatewayTimeout)
+	c.Assert(time.Since(start) < time.Second, check.Equals, true)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// returned instead of calling the database while the circuit is open
+var errCircuitOpen = errors.New("database is failing, calls are suspended")
+
+// stops calling the database after failures consecutive failures. Once cooldown
+// passed a single trial call is let through, its success closes the circuit
+// again and its failure keeps it open for another cooldown.
+type circuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	failed   int
+	openedAt time.Time
+	trial    bool // a trial call is in flight
+}
+
+// nil when failures is not positive, which disables the breaker
+func newCircuitBreaker(failures int, cooldown time.Duration) *circuitBreaker {
+	if failures <= 0 {
+		return nil
+	}
+	return &circuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+// whether a call may be made, each allowed call must be followed by record or cancel
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failed < b.failures {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// count the outcome of an allowed call
+func (b *circuitBreaker) record(ok bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.failed = 0
+		return
+	}
+	b.failed++
+	if b.failed >= b.failures {
+		b.openedAt = b.now()
+	}
+}
+
+// give back an allowed call whose outcome says nothing about the database,
+// such as one the client gave up on
+func (b *circuitBreaker) cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// time until a trial call is let through, 0 while the circuit is closed
+func (b *circuitBreaker) retryIn() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failed < b.failures {
+		return 0
+	}
+	if wait := b.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
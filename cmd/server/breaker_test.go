@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, time.Second)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatalf("Call %d not allowed while closed", i)
+		}
+		b.record(false)
+	}
+	if b.allow() {
+		t.Error("Call allowed while open")
+	}
+
+	now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("Trial call not allowed after the cooldown")
+	}
+	if b.allow() {
+		t.Error("Second call allowed while the trial is in flight")
+	}
+	b.record(false)
+	if b.allow() || b.retryIn() != time.Second {
+		t.Errorf("Failed trial did not reopen the circuit, retry in %s", b.retryIn())
+	}
+
+	now = now.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("Trial call not allowed after the cooldown")
+	}
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Error("Successful trial did not close the circuit")
+	}
+	if newCircuitBreaker(0, time.Second) != nil {
+		t.Error("Breaker without failures is enabled")
+	}
+}
+
+func TestDataHandlerBreaker(t *testing.T) {
+	calls := 0
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer db.Close()
+	d := &dataHandler{client: newDatabaseClient(time.Second), url: db.URL, breaker: newCircuitBreaker(3, time.Minute)}
+
+	for i := 0; i < 5; i++ {
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/some-data", nil))
+	}
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	if calls != 3 {
+		t.Errorf("Database called %d times, want 3", calls)
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("Unexpected answer while open: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestDatabaseClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer db.Close()
+	defer close(release)
+	d := &dataHandler{client: newDatabaseClient(20 * time.Millisecond), url: db.URL}
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+
+	if rec.Code != http.StatusInternalServerError || time.Since(start) > time.Second {
+		t.Errorf("Hung database answered with %d after %s", rec.Code, time.Since(start))
+	}
+}
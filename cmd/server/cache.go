@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// values of the X-Cache response header telling where the data came from
+const (
+	cacheHit    = "HIT"    // cached value within the ttl
+	cacheMiss   = "MISS"   // fetched from the database
+	cacheStale  = "STALE"  // cached value past the ttl, the database was slow or failed
+	cacheBypass = "BYPASS" // fetched from the database as the request asked for
+)
+
+// the last value fetched from the database. It is fresh for ttl and may stand in
+// for the database for up to maxStale after it was fetched.
+type valueCache struct {
+	ttl      time.Duration
+	maxStale time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	body   []byte
+	stored time.Time
+}
+
+// nil when ttl is not positive, which disables caching
+func newValueCache(ttl, maxStale time.Duration) *valueCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if maxStale < ttl {
+		maxStale = ttl
+	}
+	return &valueCache{ttl: ttl, maxStale: maxStale, now: time.Now}
+}
+
+// the cached value while it is fresh
+func (c *valueCache) fresh() ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.younger(c.ttl)
+}
+
+// the cached value while it may still stand in for the database
+func (c *valueCache) stale() ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.younger(c.maxStale)
+}
+
+func (c *valueCache) younger(age time.Duration) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body == nil || c.now().Sub(c.stored) >= age {
+		return nil, false
+	}
+	return c.body, true
+}
+
+func (c *valueCache) put(body []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.body = body
+	c.stored = c.now()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const adminChaosPath = "/admin/chaos"
+
+// longest delay of the data requests in seconds, larger ones are ignored
+const maxDelaySec = 300
+
+// body of POST /admin/chaos, omitted fields stay as they are
+type ChaosRequest struct {
+	HealthFailure *bool `json:"healthFailure,omitempty"`
+	DelaySec      *int  `json:"delaySec,omitempty"`
+}
+
+// answer of /admin/chaos
+type ChaosSettings struct {
+	HealthFailure bool `json:"healthFailure"`
+	DelaySec      int  `json:"delaySec"`
+}
+
+// failure mode and delay set at runtime, they replace CONF_HEALTH_FAILURE and
+// CONF_RESPONSE_DELAY_SEC until reset
+type chaosOverrides struct {
+	mu            sync.Mutex
+	healthFailure *bool
+	delaySec      *int
+}
+
+var chaos = new(chaosOverrides)
+
+// whether health checks fail, by CONF_HEALTH_FAILURE unless set at runtime
+func healthFailure() bool {
+	chaos.mu.Lock()
+	defer chaos.mu.Unlock()
+	if chaos.healthFailure != nil {
+		return *chaos.healthFailure
+	}
+	return os.Getenv(confHealthFailure) == "true"
+}
+
+// delay of the data requests, by CONF_RESPONSE_DELAY_SEC unless set at runtime.
+// 0 when unset or out of range.
+func responseDelay() time.Duration {
+	chaos.mu.Lock()
+	defer chaos.mu.Unlock()
+	delaySec := 0
+	if chaos.delaySec != nil {
+		delaySec = *chaos.delaySec
+	} else if env, err := strconv.Atoi(os.Getenv(confResponseDelaySec)); err == nil {
+		delaySec = env
+	}
+	if delaySec <= 0 || delaySec >= maxDelaySec {
+		return 0
+	}
+	return time.Duration(delaySec) * time.Second
+}
+
+func (c *chaosOverrides) set(req ChaosRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if req.HealthFailure != nil {
+		failure := *req.HealthFailure
+		c.healthFailure = &failure
+	}
+	if req.DelaySec != nil {
+		delaySec := *req.DelaySec
+		c.delaySec = &delaySec
+	}
+}
+
+// back to the environment
+func (c *chaosOverrides) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.healthFailure, c.delaySec = nil, nil
+}
+
+func currentChaos() ChaosSettings {
+	return ChaosSettings{HealthFailure: healthFailure(), DelaySec: int(responseDelay() / time.Second)}
+}
+
+// GET answers the settings in effect, POST changes them and DELETE goes back to the environment
+func chaosHandler(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(rw, http.StatusOK, currentChaos())
+	case http.MethodPost:
+		var req ChaosRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.DelaySec != nil && (*req.DelaySec < 0 || *req.DelaySec >= maxDelaySec) {
+			http.Error(rw, "delaySec must be from 0 to "+strconv.Itoa(maxDelaySec-1), http.StatusBadRequest)
+			return
+		}
+		chaos.set(req)
+		settings := currentChaos()
+		events.Info("chaos settings changed", "healthFailure", settings.HealthFailure, "delaySec", settings.DelaySec)
+		writeJSON(rw, http.StatusOK, settings)
+	case http.MethodDelete:
+		chaos.reset()
+		settings := currentChaos()
+		events.Info("chaos settings reset", "healthFailure", settings.HealthFailure, "delaySec", settings.DelaySec)
+		writeJSON(rw, http.StatusOK, settings)
+	default:
+		rw.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosHandler(t *testing.T) {
+	defer chaos.reset()
+	t.Setenv(confHealthFailure, "")
+	t.Setenv(confResponseDelaySec, "2")
+	call := func(method, body string) (int, ChaosSettings) {
+		rec := httptest.NewRecorder()
+		chaosHandler(rec, httptest.NewRequest(method, adminChaosPath, strings.NewReader(body)))
+		var settings ChaosSettings
+		_ = json.Unmarshal(rec.Body.Bytes(), &settings)
+		return rec.Code, settings
+	}
+
+	if code, settings := call("GET", ""); code != http.StatusOK || settings != (ChaosSettings{DelaySec: 2}) {
+		t.Errorf("Unexpected settings %d %+v from the environment", code, settings)
+	}
+	if code, settings := call("POST", `{"healthFailure": true}`); code != http.StatusOK || settings != (ChaosSettings{HealthFailure: true, DelaySec: 2}) {
+		t.Errorf("Unexpected settings %d %+v after failing health checks", code, settings)
+	}
+	if !healthFailure() || responseDelay() != 2*time.Second {
+		t.Errorf("Unexpected failure mode %t and delay %s in effect", healthFailure(), responseDelay())
+	}
+	if code, settings := call("POST", `{"delaySec": 0}`); code != http.StatusOK || settings != (ChaosSettings{HealthFailure: true}) {
+		t.Errorf("Unexpected settings %d %+v after removing the delay", code, settings)
+	}
+	if code, _ := call("POST", `{"delaySec": 300}`); code != http.StatusBadRequest {
+		t.Errorf("Unexpected status %d for a delay out of range", code)
+	}
+	if code, _ := call("POST", `{`); code != http.StatusBadRequest {
+		t.Errorf("Unexpected status %d for a malformed body", code)
+	}
+	if code, settings := call("DELETE", ""); code != http.StatusOK || settings != (ChaosSettings{DelaySec: 2}) {
+		t.Errorf("Unexpected settings %d %+v after the reset", code, settings)
+	}
+	if code, _ := call("PUT", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Unexpected status %d for PUT", code)
+	}
+}
+
+func TestChaosFailsReadiness(t *testing.T) {
+	defer chaos.reset()
+	t.Setenv(confHealthFailure, "")
+	failure := true
+	chaos.set(ChaosRequest{HealthFailure: &failure})
+
+	rec := httptest.NewRecorder()
+	readyHandler(nil)(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status %d while failing at runtime", rec.Code)
+	}
+}
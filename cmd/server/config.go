@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const (
+	configFlag      = "config"
+	configEnvPrefix = "SERVER_"
+)
+
+// read by loadConfig through the flag set
+var configPath = flag.String(configFlag, "", "JSON file of settings by flag name such as {\"db-timeout\": \"2s\"}, SERVER_* variables and flags win over it")
+
+// variables read for a setting when its SERVER_ one is not set
+var envAliases = map[string]string{
+	"key": confTeamName,
+}
+
+// environment variable of the setting of a flag, such as SERVER_DB_TIMEOUT for -db-timeout
+func envName(flagName string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig sets the flags not given on the command line from the environment
+// and then from the config file, so one binary runs in compose, k8s and locally.
+// Parse the flags first.
+func loadConfig(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	env := func(name string) (string, bool) {
+		if value, ok := lookupEnv(envName(name)); ok {
+			return value, true
+		}
+		if alias, ok := envAliases[name]; ok {
+			return lookupEnv(alias)
+		}
+		return "", false
+	}
+
+	path := ""
+	if f := fs.Lookup(configFlag); f != nil {
+		path = f.Value.String()
+		if value, ok := env(configFlag); ok && !given[configFlag] {
+			path = value
+		}
+	}
+	file, err := readConfigFile(fs, path)
+	if err != nil {
+		return err
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] || f.Name == configFlag {
+			return
+		}
+		value, source := "", ""
+		if v, ok := env(f.Name); ok {
+			value, source = v, "environment"
+		} else if v, ok := file[f.Name]; ok {
+			value, source = v, path
+		} else {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid %s %q from %s: %w", f.Name, value, source, setErr)
+		}
+	})
+	return err
+}
+
+// the settings of the file as flag values, none when path is empty
+func readConfigFile(fs *flag.FlagSet, path string) (map[string]string, error) {
+	settings := make(map[string]string)
+	if path == "" {
+		return settings, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	var unknown []string
+	for name, value := range raw {
+		if fs.Lookup(name) == nil || name == configFlag {
+			unknown = append(unknown, name)
+			continue
+		}
+		// strings are unquoted, numbers and booleans are taken as written
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			s = string(bytes.TrimSpace(value))
+		}
+		settings[name] = s
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("invalid config %s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return settings, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestFlagSet() (*flag.FlagSet, *int, *string, *time.Duration) {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.String(configFlag, "", "")
+	port := fs.Int("port", 8080, "")
+	key := fs.String("key", "", "")
+	timeout := fs.Duration("db-timeout", 2*time.Second, "")
+	return fs, port, key, timeout
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "server.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envOf(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `{"port": 9000, "key": "from-file", "db-timeout": "3s"}`)
+	fs, port, key, timeout := newTestFlagSet()
+	if err := fs.Parse([]string{"-config", path, "-db-timeout", "4s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := loadConfig(fs, envOf(map[string]string{"SERVER_KEY": "from-env", "SERVER_DB_TIMEOUT": "5s"}))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if *port != 9000 || *key != "from-env" || *timeout != 4*time.Second {
+		t.Errorf("Unexpected settings port %d key %q timeout %s, want 9000 from-env 4s", *port, *key, *timeout)
+	}
+}
+
+func TestLoadConfigFromEnvironment(t *testing.T) {
+	path := writeConfig(t, `{"port": 9000}`)
+	fs, port, key, timeout := newTestFlagSet()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is named by SERVER_CONFIG and TEAM_NAME stands in for SERVER_KEY
+	err := loadConfig(fs, envOf(map[string]string{"SERVER_CONFIG": path, confTeamName: "team"}))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err)
+	}
+	if *port != 9000 || *key != "team" || *timeout != 2*time.Second {
+		t.Errorf("Unexpected settings port %d key %q timeout %s, want 9000 team 2s", *port, *key, *timeout)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for name, setup := range map[string]struct {
+		file string
+		env  map[string]string
+	}{
+		"unknown setting": {file: `{"prot": 9000}`},
+		"malformed file":  {file: `{"port": `},
+		"invalid value":   {file: `{"port": "eighty"}`},
+		"invalid env":     {file: `{}`, env: map[string]string{"SERVER_DB_TIMEOUT": "soon"}},
+	} {
+		fs, _, _, _ := newTestFlagSet()
+		if err := fs.Parse([]string{"-config", writeConfig(t, setup.file)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadConfig(fs, envOf(setup.env)); err == nil {
+			t.Errorf("Unexpected config accepted with %s", name)
+		}
+	}
+
+	fs, _, _, _ := newTestFlagSet()
+	if err := fs.Parse([]string{"-config", filepath.Join(t.TempDir(), "missing.json")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(fs, envOf(nil)); err == nil {
+		t.Errorf("Unexpected missing config file accepted")
+	}
+}
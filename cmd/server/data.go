@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+)
+
+// header telling whether the data came from the cache
+const cacheHeader = "X-Cache"
+
+// header asking for a delay of the request like ?delay=, the query parameter wins
+const delayHeader = "X-Delay"
+
+// answers the data requests with the value from the database or from the cache
+type dataHandler struct {
+	client  *http.Client
+	url     string // of the value of the configured key
+	keysURL string // followed by an escaped key, of the values asked for with ?key=
+	cache   *valueCache
+	breaker *circuitBreaker
+	metrics *Metrics
+	// time to wait for the database before a stale cached value is served instead
+	staleAfter time.Duration
+	// longest delay a request may ask for, 0 refuses such requests
+	maxDelay time.Duration
+}
+
+// key served when neither -key nor TEAM_NAME names one
+const defaultKey = "solo"
+
+// the key given by the flag, otherwise the one in TEAM_NAME, otherwise defaultKey
+func resolveKey(flagKey string) string {
+	if flagKey != "" {
+		return flagKey
+	}
+	if team := os.Getenv(confTeamName); team != "" {
+		return team
+	}
+	return defaultKey
+}
+
+// database address of the value stored under key
+func keyURL(keysURL, key string) string {
+	return keysURL + url.PathEscape(key)
+}
+
+// ask the database for the value, passing the request id and trace context on. Fails with
+// errCircuitOpen without calling it while the breaker is open.
+func (d *dataHandler) fetch(ctx context.Context, r *http.Request, url string) ([]byte, int, error) {
+	requestID, traceID := r.Header.Get(httptools.RequestIDHeader), httptools.TraceID(r.Header)
+	if !d.breaker.allow() {
+		events.Warn("database call suspended", "requestId", requestID, "traceId", traceID, "retryIn", d.breaker.retryIn())
+		return nil, 0, errCircuitOpen
+	}
+	start := time.Now()
+	body, status, err := d.call(ctx, r, url)
+	duration := time.Since(start)
+	d.metrics.ObserveDatabase(duration)
+	switch {
+	case err != nil:
+		events.Warn("database call failed", "requestId", requestID, "traceId", traceID, "duration", duration, "error", err)
+	case status >= http.StatusInternalServerError:
+		events.Warn("database call failed", "requestId", requestID, "traceId", traceID, "duration", duration, "status", status)
+	default:
+		events.Debug("database called", "requestId", requestID, "traceId", traceID, "duration", duration, "status", status)
+	}
+	if err != nil && r.Context().Err() != nil {
+		d.breaker.cancel()
+	} else {
+		d.breaker.record(err == nil && status < http.StatusInternalServerError)
+	}
+	return body, status, err
+}
+
+func (d *dataHandler) call(ctx context.Context, r *http.Request, url string) ([]byte, int, error) {
+	dbReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	httptools.PropagateTrace(dbReq, r)
+	resp, err := d.client.Do(dbReq)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return body, resp.StatusCode, err
+}
+
+func (d *dataHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	requested, err := requestDelay(r)
+	if err == nil && requested > d.maxDelay {
+		err = fmt.Errorf("delay must not exceed %s", d.maxDelay)
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// on top of the delay of all the requests
+	if delay := responseDelay() + requested; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			// the client is gone, nobody waits for the answer
+			return
+		}
+	}
+
+	// ?key= asks for another key than the configured one, its value is not cached
+	if key := r.URL.Query().Get("key"); key != "" {
+		d.serveKey(rw, r, keyURL(d.keysURL, key))
+		return
+	}
+
+	// Cache-Control: no-cache always goes to the database, tests use it
+	bypass := r.Header.Get("Cache-Control") == "no-cache"
+	if !bypass {
+		if body, ok := d.cache.fresh(); ok {
+			d.writeData(rw, body, cacheHit)
+			return
+		}
+	}
+	stale, hasStale := d.cache.stale()
+	hasStale = hasStale && !bypass
+
+	ctx := r.Context()
+	if hasStale && d.staleAfter > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.staleAfter)
+		defer cancel()
+	}
+	body, status, err := d.fetch(ctx, r, d.url)
+	if (err != nil || status >= http.StatusInternalServerError) && hasStale && r.Context().Err() == nil {
+		d.writeData(rw, stale, cacheStale)
+		return
+	}
+	if d.writeFailure(rw, r, status, err) {
+		return
+	}
+	if status == http.StatusOK {
+		d.cache.put(body)
+	}
+	source := cacheMiss
+	if bypass {
+		source = cacheBypass
+	}
+	d.writeData(rw, body, source)
+}
+
+// delay the request asks for with ?delay= or X-Delay, such as 2s or 150ms
+func requestDelay(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("delay")
+	if value == "" {
+		value = r.Header.Get(delayHeader)
+	}
+	if value == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("delay must be a duration such as 2s, got %q", value)
+	}
+	return delay, nil
+}
+
+// the value of a key asked for with ?key=, straight from the database
+func (d *dataHandler) serveKey(rw http.ResponseWriter, r *http.Request, url string) {
+	body, status, err := d.fetch(r.Context(), r, url)
+	if d.writeFailure(rw, r, status, err) {
+		return
+	}
+	d.writeData(rw, body, cacheBypass)
+}
+
+// answer a failed fetch, telling whether it failed
+func (d *dataHandler) writeFailure(rw http.ResponseWriter, r *http.Request, status int, err error) bool {
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(1, d.breaker.retryIn().Seconds())))))
+		http.Error(rw, "Database unavailable: calls are suspended after repeated failures", http.StatusServiceUnavailable)
+	case err != nil:
+		events.Error("data not fetched", "requestId", r.Header.Get(httptools.RequestIDHeader),
+			"traceId", httptools.TraceID(r.Header), "error", err)
+		http.Error(rw, "Error getting data", http.StatusInternalServerError)
+	case status == http.StatusNotFound:
+		http.Error(rw, "Key not found", http.StatusNotFound)
+	case status < http.StatusOK || status >= http.StatusMultipleChoices:
+		// the database refused the call, the client gets the same status
+		http.Error(rw, "Database answered "+http.StatusText(status), status)
+	default:
+		return false
+	}
+	return true
+}
+
+func (d *dataHandler) writeData(rw http.ResponseWriter, body []byte, source string) {
+	d.metrics.ObserveCache(source)
+	rw.Header().Set("content-type", "application/json")
+	rw.Header().Set(cacheHeader, source)
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(body)
+}
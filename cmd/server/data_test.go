@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
+	"github.com/mikhmol/Architecture_Lab4/logging"
+)
+
+func TestDataHandlerCache(t *testing.T) {
+	calls, value, status := 0, `{"key":"solo","value":"a"}`, http.StatusOK
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(value))
+	}))
+	defer db.Close()
+	now := time.Now()
+	cache := newValueCache(time.Second, time.Minute)
+	cache.now = func() time.Time { return now }
+	d := &dataHandler{client: db.Client(), url: db.URL, cache: cache}
+	get := func(header string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+		if header != "" {
+			r.Header.Set("Cache-Control", header)
+		}
+		d.ServeHTTP(rec, r)
+		return rec
+	}
+	check := func(rec *httptest.ResponseRecorder, source, body string, wantCalls int) {
+		t.Helper()
+		if rec.Code != http.StatusOK || rec.Header().Get(cacheHeader) != source || rec.Body.String() != body || calls != wantCalls {
+			t.Errorf("Got %d %s %q after %d database calls, want %s %q after %d",
+				rec.Code, rec.Header().Get(cacheHeader), rec.Body.String(), calls, source, body, wantCalls)
+		}
+	}
+
+	check(get(""), cacheMiss, `{"key":"solo","value":"a"}`, 1)
+	value = `{"key":"solo","value":"b"}`
+	check(get(""), cacheHit, `{"key":"solo","value":"a"}`, 1)
+	check(get("no-cache"), cacheBypass, `{"key":"solo","value":"b"}`, 2)
+
+	// the database fails once the value is no longer fresh
+	now = now.Add(2 * time.Second)
+	status = http.StatusServiceUnavailable
+	check(get(""), cacheStale, `{"key":"solo","value":"b"}`, 3)
+
+	// the cached value is too old to stand in
+	now = now.Add(time.Minute)
+	if rec := get(""); rec.Code != http.StatusServiceUnavailable || calls != 4 {
+		t.Errorf("Got %d after %d database calls, want 503 after 4", rec.Code, calls)
+	}
+}
+
+func TestDataHandlerWithoutCache(t *testing.T) {
+	d := &dataHandler{client: http.DefaultClient, url: "http://127.0.0.1:1/api/v1/db/solo", cache: newValueCache(0, time.Minute)}
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected status without database: %d", rec.Code)
+	}
+}
+
+func TestDataHandlerPassesDatabaseStatus(t *testing.T) {
+	status := http.StatusUnprocessableEntity
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(status)
+	}))
+	defer db.Close()
+	d := &dataHandler{client: db.Client(), url: db.URL + "/keys/solo", keysURL: db.URL + "/keys/"}
+
+	for _, status = range []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusInternalServerError} {
+		for _, target := range []string{"/api/v1/some-data", "/api/v1/some-data?key=other"} {
+			rec := httptest.NewRecorder()
+			d.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+			if rec.Code != status {
+				t.Errorf("Unexpected status %d of %s when the database answered %d", rec.Code, target, status)
+			}
+		}
+	}
+}
+
+func TestDataHandlerPropagatesTrace(t *testing.T) {
+	var got http.Header
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		_, _ = rw.Write([]byte(`{"key":"solo","value":"a"}`))
+	}))
+	defer db.Close()
+	d := &dataHandler{client: db.Client(), url: db.URL}
+	serve := func(traceparent string) {
+		r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+		r.Header.Set(httptools.RequestIDHeader, "req-1")
+		r.Header.Set(httptools.TraceParentHeader, traceparent)
+		r.Header.Set(httptools.TraceStateHeader, "congo=t61rcWkgMzE")
+		d.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if got.Get(httptools.RequestIDHeader) != "req-1" ||
+		got.Get(httptools.TraceParentHeader) != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" ||
+		got.Get(httptools.TraceStateHeader) != "congo=t61rcWkgMzE" {
+		t.Errorf("Unexpected headers of the database call %v", got)
+	}
+
+	// an invalid trace context is dropped, the request id is still passed on
+	serve("00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	if got.Get(httptools.RequestIDHeader) != "req-1" || got.Get(httptools.TraceParentHeader) != "" || got.Get(httptools.TraceStateHeader) != "" {
+		t.Errorf("Unexpected headers of the database call %v", got)
+	}
+}
+
+func TestResolveKey(t *testing.T) {
+	t.Setenv(confTeamName, "")
+	if key := resolveKey(""); key != defaultKey {
+		t.Errorf("Unexpected key %q without settings, want %q", key, defaultKey)
+	}
+	t.Setenv(confTeamName, "team one")
+	if key := resolveKey(""); key != "team one" {
+		t.Errorf("Unexpected key %q from %s", key, confTeamName)
+	}
+	if key := resolveKey("flagged"); key != "flagged" {
+		t.Errorf("Unexpected key %q, the flag comes first", key)
+	}
+	if u := keyURL("http://database:8080/api/v1/db/", "team one"); u != "http://database:8080/api/v1/db/team%20one" {
+		t.Errorf("Unexpected url %q", u)
+	}
+}
+
+func TestDataHandlerKeyParameter(t *testing.T) {
+	var paths []string
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if r.URL.Path == "/keys/missing" {
+			http.Error(rw, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"key":"` + r.URL.Path + `"}`))
+	}))
+	defer db.Close()
+	d := &dataHandler{client: db.Client(), url: db.URL + "/keys/solo", keysURL: db.URL + "/keys/", cache: newValueCache(time.Minute, time.Minute)}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		d.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	if rec := get("/api/v1/some-data?key=foo%20bar"); rec.Code != http.StatusOK || rec.Body.String() != `{"key":"/keys/foo bar"}` || rec.Header().Get(cacheHeader) != cacheBypass {
+		t.Errorf("Unexpected answer %d %s %q for key foo bar", rec.Code, rec.Header().Get(cacheHeader), rec.Body.String())
+	}
+	if rec := get("/api/v1/some-data?key=missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("Unexpected status %d for a missing key", rec.Code)
+	}
+	// the configured key is still cached, other keys are not
+	get("/api/v1/some-data")
+	get("/api/v1/some-data?key=")
+	get("/api/v1/some-data?key=foo%20bar")
+	want := []string{"/keys/foo%20bar", "/keys/missing", "/keys/solo", "/keys/foo%20bar"}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("Unexpected database calls %v, want %v", paths, want)
+	}
+}
+
+func TestDataHandlerRequestDelay(t *testing.T) {
+	t.Setenv(confResponseDelaySec, "")
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"key":"solo","value":"a"}`))
+	}))
+	defer db.Close()
+	d := &dataHandler{client: db.Client(), url: db.URL, maxDelay: time.Second}
+	get := func(target, header string) (int, time.Duration) {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", target, nil)
+		if header != "" {
+			r.Header.Set(delayHeader, header)
+		}
+		start := time.Now()
+		d.ServeHTTP(rec, r)
+		return rec.Code, time.Since(start)
+	}
+
+	if code, took := get("/api/v1/some-data?delay=50ms", ""); code != http.StatusOK || took < 50*time.Millisecond {
+		t.Errorf("Unexpected %d after %s, want 200 after 50ms", code, took)
+	}
+	if code, took := get("/api/v1/some-data", "30ms"); code != http.StatusOK || took < 30*time.Millisecond {
+		t.Errorf("Unexpected %d after %s, want 200 after 30ms", code, took)
+	}
+	for _, delay := range []string{"2s", "-1s", "soon"} {
+		if code, _ := get("/api/v1/some-data?delay="+delay, ""); code != http.StatusBadRequest {
+			t.Errorf("Unexpected status %d for delay %s", code, delay)
+		}
+	}
+}
+
+func TestDataHandlerLogsDatabaseFailures(t *testing.T) {
+	var out bytes.Buffer
+	logger, _ := logging.New(logging.FormatText, "info", &out)
+	saved := events
+	events = logger
+	defer func() { events = saved }()
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer db.Close()
+	d := &dataHandler{client: db.Client(), url: db.URL}
+
+	r := httptest.NewRequest("GET", "/api/v1/some-data", nil)
+	r.Header.Set(httptools.RequestIDHeader, "req-1")
+	d.ServeHTTP(httptest.NewRecorder(), r)
+
+	if line := out.String(); !strings.Contains(line, `msg="database call failed" requestId=req-1`) || !strings.Contains(line, "status=500") {
+		t.Errorf("Unexpected events %q", line)
+	}
+}
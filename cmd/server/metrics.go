@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikhmol/Architecture_Lab4/metrics"
+)
+
+// path and status code of a response
+type requestKey struct {
+	path string
+	code int
+}
+
+// Metrics collects what the server did, written in the Prometheus text format by
+// WriteTo. A nil *Metrics ignores observations.
+type Metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*metrics.Histogram
+	db        *metrics.Histogram
+	cache     map[string]uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*metrics.Histogram),
+		db:        metrics.NewHistogram(),
+		cache:     make(map[string]uint64),
+	}
+}
+
+// ObserveRequest counts a response to the path and the time it took
+func (m *Metrics) ObserveRequest(path string, code int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{path, code}]++
+	h, ok := m.durations[path]
+	if !ok {
+		h = metrics.NewHistogram()
+		m.durations[path] = h
+	}
+	h.Observe(duration)
+}
+
+// ObserveDatabase records the time a database call took, failed ones included
+func (m *Metrics) ObserveDatabase(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db.Observe(duration)
+}
+
+// ObserveCache counts where the data of a request came from, one of the X-Cache values
+func (m *Metrics) ObserveCache(source string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache[source]++
+}
+
+// Instrument counts the requests handled by next under path
+func (m *Metrics) Instrument(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		m.ObserveRequest(path, recorder.status, time.Since(start))
+	})
+}
+
+// WriteTo writes the metrics together with the failure mode and delay in effect
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &metrics.CountingWriter{W: w}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(cw, "# HELP server_requests_total Responses by path and status code.")
+	fmt.Fprintln(cw, "# TYPE server_requests_total counter")
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		fmt.Fprintf(cw, "server_requests_total{path=%q,code=\"%d\"} %d\n", key.path, key.code, m.requests[key])
+	}
+
+	fmt.Fprintln(cw, "# HELP server_request_duration_seconds Time until a response was written, by path.")
+	fmt.Fprintln(cw, "# TYPE server_request_duration_seconds histogram")
+	paths := make([]string, 0, len(m.durations))
+	for path := range m.durations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		metrics.WriteHistogram(cw, "server_request_duration_seconds", fmt.Sprintf("path=%q", path), m.durations[path])
+	}
+
+	fmt.Fprintln(cw, "# HELP server_db_request_duration_seconds Time until the database answered.")
+	fmt.Fprintln(cw, "# TYPE server_db_request_duration_seconds histogram")
+	metrics.WriteHistogram(cw, "server_db_request_duration_seconds", "", m.db)
+
+	fmt.Fprintln(cw, "# HELP server_cache_lookups_total Data requests by where the data came from.")
+	fmt.Fprintln(cw, "# TYPE server_cache_lookups_total counter")
+	var lookups uint64
+	for _, source := range []string{cacheHit, cacheMiss, cacheStale, cacheBypass} {
+		fmt.Fprintf(cw, "server_cache_lookups_total{result=%q} %d\n", source, m.cache[source])
+		lookups += m.cache[source]
+	}
+	fmt.Fprintln(cw, "# HELP server_cache_hit_ratio Share of the data requests answered from the cache.")
+	fmt.Fprintln(cw, "# TYPE server_cache_hit_ratio gauge")
+	ratio := 0.0
+	if lookups > 0 {
+		ratio = float64(m.cache[cacheHit]+m.cache[cacheStale]) / float64(lookups)
+	}
+	fmt.Fprintf(cw, "server_cache_hit_ratio %g\n", ratio)
+
+	fmt.Fprintf(cw, "# HELP server_response_delay_seconds Delay added to data requests by %s or at runtime.\n", confResponseDelaySec)
+	fmt.Fprintln(cw, "# TYPE server_response_delay_seconds gauge")
+	fmt.Fprintf(cw, "server_response_delay_seconds %d\n", responseDelay()/time.Second)
+	fmt.Fprintf(cw, "# HELP server_health_failure Whether %s or a runtime setting makes health checks fail.\n", confHealthFailure)
+	fmt.Fprintln(cw, "# TYPE server_health_failure gauge")
+	failure := 0
+	if healthFailure() {
+		failure = 1
+	}
+	fmt.Fprintf(cw, "server_health_failure %d\n", failure)
+	return cw.N, cw.Err
+}
+
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("content-type", "text/plain; version=0.0.4")
+	_, _ = m.WriteTo(rw)
+}
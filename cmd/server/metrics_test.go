@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestMetricsCountRequestsPerPath(t *testing.T) {
+	m := NewMetrics()
+	ok := m.Instrument("/health", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	failing := m.Instrument("/ready", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	for i := 0; i < 2; i++ {
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ready", nil))
+
+	out := scrape(m)
+	for _, line := range []string{
+		`server_requests_total{path="/health",code="200"} 2`,
+		`server_requests_total{path="/ready",code="503"} 1`,
+		`server_request_duration_seconds_bucket{path="/health",le="+Inf"} 2`,
+		`server_request_duration_seconds_count{path="/ready"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Unexpected metrics without %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestMetricsOfDataRequests(t *testing.T) {
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"key":"solo","value":"a"}`))
+	}))
+	defer db.Close()
+	m := NewMetrics()
+	d := &dataHandler{client: db.Client(), url: db.URL, cache: newValueCache(time.Minute, time.Minute), metrics: m}
+	for i := 0; i < 4; i++ {
+		d.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/some-data", nil))
+	}
+
+	out := scrape(m)
+	for _, line := range []string{
+		`server_db_request_duration_seconds_count 1`,
+		`server_cache_lookups_total{result="HIT"} 3`,
+		`server_cache_lookups_total{result="MISS"} 1`,
+		`server_cache_hit_ratio 0.75`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Unexpected metrics without %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestMetricsShowConfiguration(t *testing.T) {
+	t.Setenv(confResponseDelaySec, "3")
+	t.Setenv(confHealthFailure, "true")
+
+	out := scrape(NewMetrics())
+	for _, line := range []string{"server_response_delay_seconds 3", "server_health_failure 1", "server_cache_hit_ratio 0"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Unexpected metrics without %q:\n%s", line, out)
+		}
+	}
+}
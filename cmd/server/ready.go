@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// time a probe of the database has
+const probeTimeout = time.Second
+
+// tells whether a dependency answers its readiness endpoint, the outcome is
+// reused for ttl so frequent readiness checks do not load the dependency
+type dependencyProbe struct {
+	client *http.Client
+	url    string
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	checked time.Time
+	ok      bool
+}
+
+func newDependencyProbe(client *http.Client, url string, ttl time.Duration) *dependencyProbe {
+	return &dependencyProbe{client: client, url: url, ttl: ttl, now: time.Now}
+}
+
+func (p *dependencyProbe) healthy(ctx context.Context) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now := p.now(); p.checked.IsZero() || now.Sub(p.checked) >= p.ttl {
+		p.ok = p.probe(ctx)
+		p.checked = now
+	}
+	return p.ok
+}
+
+func (p *dependencyProbe) probe(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// answers 200 while the server can answer data requests: it is alive and not
+// shutting down, the database is seeded and db answers its probe
+func readyHandler(db *dependencyProbe) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("content-type", "text/plain")
+		status, text := http.StatusOK, "OK"
+		if healthFailure() {
+			// a server failing its liveness is not ready either
+			status, text = http.StatusInternalServerError, "FAILURE"
+		} else if draining.Load() {
+			status, text = http.StatusServiceUnavailable, "SHUTTING DOWN"
+		} else if !seeded.Load() {
+			status, text = http.StatusServiceUnavailable, "NOT READY"
+		} else if !db.healthy(r.Context()) {
+			status, text = http.StatusServiceUnavailable, "DATABASE UNAVAILABLE"
+		}
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(text))
+	}
+}
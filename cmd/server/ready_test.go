@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDependencyProbe(t *testing.T) {
+	probes, status := 0, http.StatusOK
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		probes++
+		rw.WriteHeader(status)
+	}))
+	defer db.Close()
+	p := newDependencyProbe(db.Client(), db.URL+"/ready", time.Second)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	if !p.healthy(context.Background()) {
+		t.Error("Database answering 200 is not healthy")
+	}
+	status = http.StatusServiceUnavailable
+	if !p.healthy(context.Background()) || probes != 1 {
+		t.Errorf("Outcome not reused within the ttl, %d probes", probes)
+	}
+	now = now.Add(time.Second)
+	if p.healthy(context.Background()) || probes != 2 {
+		t.Errorf("Outcome not refreshed after the ttl, %d probes", probes)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	defer seeded.Store(false)
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	handler := readyHandler(newDependencyProbe(db.Client(), db.URL+"/ready", 0))
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status before seeding: %d", code)
+	}
+	seeded.Store(true)
+	if code := ready(); code != http.StatusOK {
+		t.Errorf("Unexpected status once seeded: %d", code)
+	}
+	db.Close()
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status without database: %d", code)
+	}
+}
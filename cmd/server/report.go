@@ -2,31 +2,143 @@ package main
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/mikhmol/Architecture_Lab4/httptools"
 )
 
 const reportMaxLen = 100
 
-type Report map[string][]string
+// Report keeps what the server was asked: the last request counters of each
+// lb-author, and the requests per path, per lb-from balancer target and per
+// answered status code. It is safe for concurrent use.
+type Report struct {
+	mu       sync.Mutex
+	authors  map[string][]string
+	paths    map[string]int
+	backends map[string]int
+	statuses map[int]int
+}
+
+// what GET /report?view=full answers, plain GET /report answers only the authors
+// as the stats tool expects
+type reportSnapshot struct {
+	Authors  map[string][]string `json:"authors"`
+	Paths    map[string]int      `json:"paths"`
+	Backends map[string]int      `json:"backends"`
+	Statuses map[string]int      `json:"statuses"`
+}
 
-func (r Report) Process(req *http.Request) {
+func NewReport() *Report {
+	r := new(Report)
+	r.Reset()
+	return r
+}
+
+func (r *Report) Process(req *http.Request) {
 	author := req.Header.Get("lb-author")
 	counter := req.Header.Get("lb-req-cnt")
-	log.Printf("GET some-data from [%s] request [%s]", author, counter)
+	events.Debug("request received", "path", req.URL.Path, "author", author, "counter", counter,
+		"requestId", req.Header.Get(httptools.RequestIDHeader))
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths[req.URL.Path]++
+	// the address the balancer sent the request to, only set when it traces
+	if from := req.Header.Get("lb-from"); from != "" {
+		r.backends[from]++
+	}
 	if len(author) > 0 {
-		list := r[author]
+		list := r.authors[author]
 		list = append(list, counter)
 		if len(list) > reportMaxLen {
 			list = list[len(list)-reportMaxLen:]
 		}
-		r[author] = list
+		r.authors[author] = list
+	}
+}
+
+// count a response with the status code
+func (r *Report) Status(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[code]++
+}
+
+// Track processes every request handled by next together with the status it is answered with
+func (r *Report) Track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r.Process(req)
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		r.Status(recorder.status)
+	})
+}
+
+// Reset forgets everything reported so far
+func (r *Report) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.authors = make(map[string][]string)
+	r.paths = make(map[string]int)
+	r.backends = make(map[string]int)
+	r.statuses = make(map[int]int)
+}
+
+func (r *Report) snapshot() reportSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := reportSnapshot{
+		Authors:  make(map[string][]string, len(r.authors)),
+		Paths:    make(map[string]int, len(r.paths)),
+		Backends: make(map[string]int, len(r.backends)),
+		Statuses: make(map[string]int, len(r.statuses)),
+	}
+	for author, list := range r.authors {
+		s.Authors[author] = append([]string(nil), list...)
+	}
+	for path, n := range r.paths {
+		s.Paths[path] = n
+	}
+	for backend, n := range r.backends {
+		s.Backends[backend] = n
+	}
+	for code, n := range r.statuses {
+		s.Statuses[strconv.Itoa(code)] = n
+	}
+	return s
+}
+
+// GET answers the report, DELETE zeroes it
+func (r *Report) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		rw.Header().Set("content-type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		snapshot := r.snapshot()
+		if req.URL.Query().Get("view") == "full" {
+			_ = json.NewEncoder(rw).Encode(snapshot)
+		} else {
+			_ = json.NewEncoder(rw).Encode(snapshot.Authors)
+		}
+	case http.MethodDelete:
+		r.Reset()
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		rw.Header().Set("Allow", "GET, HEAD, DELETE")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (r Report) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
-	rw.Header().Set("content-type", "application/json")
-	rw.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(rw).Encode(r)
+// remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
@@ -11,17 +13,17 @@ func TestReport_Process(t *testing.T) {
 	req.Header.Set("lb-author", "test-author")
 	req.Header.Set("lb-req-cnt", "1")
 
-	r := make(Report)
+	r := NewReport()
 
 	r.Process(req)
-	if !reflect.DeepEqual(r["test-author"], []string{"1"}) {
-		t.Errorf("Unexpected report state %s", r)
+	if !reflect.DeepEqual(r.authors["test-author"], []string{"1"}) {
+		t.Errorf("Unexpected report state %v", r.authors)
 	}
 
 	req.Header.Set("lb-req-cnt", "2")
 	r.Process(req)
-	if !reflect.DeepEqual(r["test-author"], []string{"1", "2"}) {
-		t.Errorf("Unexpected report state %s", r)
+	if !reflect.DeepEqual(r.authors["test-author"], []string{"1", "2"}) {
+		t.Errorf("Unexpected report state %v", r.authors)
 	}
 
 	req.Header.Set("lb-author", "test-len")
@@ -29,7 +31,55 @@ func TestReport_Process(t *testing.T) {
 		req.Header.Set("lb-req-cnt", "test-len")
 		r.Process(req)
 	}
-	if len(r["test-len"]) != reportMaxLen {
-		t.Errorf("Unexpectd error length: %d", len(r["test-len"]))
+	if len(r.authors["test-len"]) != reportMaxLen {
+		t.Errorf("Unexpectd error length: %d", len(r.authors["test-len"]))
+	}
+}
+
+func TestReport_Track(t *testing.T) {
+	r := NewReport()
+	handler := r.Track(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	for _, target := range []string{"/api/v1/some-data", "/api/v1/some-data", "/missing"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("lb-from", "server1:8080")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/report?view=full", nil))
+	var got reportSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := reportSnapshot{
+		Authors:  map[string][]string{},
+		Paths:    map[string]int{"/api/v1/some-data": 2, "/missing": 1},
+		Backends: map[string]int{"server1:8080": 3},
+		Statuses: map[string]int{"200": 2, "404": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected report %+v", got)
+	}
+
+	// the authors alone by default
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("lb-author", "test-author")
+	req.Header.Set("lb-req-cnt", "1")
+	r.Process(req)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/report", nil))
+	var authors map[string][]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &authors); err != nil || !reflect.DeepEqual(authors, map[string][]string{"test-author": {"1"}}) {
+		t.Errorf("Unexpected authors report %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("DELETE", "/report", nil))
+	if rec.Code != http.StatusNoContent || len(r.snapshot().Paths) != 0 || len(r.snapshot().Statuses) != 0 {
+		t.Errorf("Report not reset: %d %+v", rec.Code, r.snapshot())
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// first wait between attempts to seed the database, doubled after each failure
+	seedBackoff = 200 * time.Millisecond
+	// longest wait between attempts
+	seedBackoffMax = 10 * time.Second
+	// time a single attempt has
+	seedAttemptTimeout = 5 * time.Second
+)
+
+// set once the seed value is in the database, until then the server is not ready for data requests
+var seeded atomic.Bool
+
+// write the seed value to the database at url, retrying with backoff until it
+// succeeds or ctx is done. The database may start after the server.
+func seedDatabase(ctx context.Context, client *http.Client, url string, payload Payload, backoff time.Duration) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err := postSeed(ctx, client, url, body)
+		if err == nil {
+			return nil
+		}
+		events.Warn("database seed failed", "attempt", attempt, "error", err)
+
+		// full jitter keeps restarted servers from retrying in lockstep
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if backoff < seedBackoffMax {
+			backoff *= 2
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func postSeed(ctx context.Context, client *http.Client, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, seedAttemptTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("content-type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("database answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// answer 503 until the database is seeded
+func readinessGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !seeded.Load() {
+			rw.Header().Set("Retry-After", "1")
+			http.Error(rw, "database not seeded yet", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeedDatabase(t *testing.T) {
+	attempts := 0
+	var got Payload
+	db := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		rw.WriteHeader(http.StatusCreated)
+	}))
+	defer db.Close()
+
+	err := seedDatabase(context.Background(), db.Client(), db.URL, Payload{Value: "2023-06-16"}, time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if attempts != 3 || got.Value != "2023-06-16" {
+		t.Errorf("Unexpected seeding after %d attempts: %+v", attempts, got)
+	}
+}
+
+func TestSeedDatabaseGivesUp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// nothing listens on the port
+	err := seedDatabase(ctx, http.DefaultClient, "http://127.0.0.1:1/api/v1/db/solo", Payload{}, time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReadinessGate(t *testing.T) {
+	defer seeded.Store(false)
+	handler := readinessGate(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Unexpected status before seeding: %d", rec.Code)
+	}
+
+	seeded.Store(true)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Unexpected status after seeding: %d", rec.Code)
+	}
+}
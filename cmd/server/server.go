@@ -1,99 +1,133 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"flag"
-	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/mikhmol/Architecture_Lab4/httptools"
+	"github.com/mikhmol/Architecture_Lab4/logging"
 	"github.com/mikhmol/Architecture_Lab4/signal"
 )
 
-var port = flag.Int("port", 8080, "server port")
+var (
+	port            = flag.Int("port", 8080, "server port")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "time given to in-flight requests, delayed ones included, to finish on shutdown")
+	readyCache      = flag.Duration("ready-cache", 2*time.Second, "time the outcome of probing the database is reused by /ready")
+	cacheTTL        = flag.Duration("cache-ttl", time.Second, "time a value fetched from the database is served without asking it again, 0 disables the cache")
+	cacheMaxStale   = flag.Duration("cache-max-stale", time.Minute, "time a cached value may be served when the database fails or is slow")
+	cacheStaleAfter = flag.Duration("cache-stale-after", 500*time.Millisecond, "time to wait for the database before a stale cached value is served instead")
+	dbURL           = flag.String("db-url", "http://database:8080", "address of the database")
+	dbTimeout       = flag.Duration("db-timeout", 2*time.Second, "time a database call has to answer")
+	breakerFailures = flag.Int("db-breaker-failures", 5, "failed database calls in a row after which calls are suspended, 0 disables the breaker")
+	breakerCooldown = flag.Duration("db-breaker-cooldown", 5*time.Second, "time database calls are suspended before a trial call")
+	maxRequestDelay = flag.Duration("max-request-delay", 10*time.Second, "longest delay a data request may ask for with ?delay= or X-Delay, 0 refuses them")
+	dataKey         = flag.String("key", "", "database key of the value served and seeded, "+confTeamName+" or "+defaultKey+" when empty")
+	chaosEnabled    = flag.Bool("chaos", false, "serve "+adminChaosPath+" changing the health failure mode and delay at runtime, only for tests as anyone reaching the server may use it")
+	logFormat       = flag.String("log-format", logging.FormatText, "format of the event lines: text or json")
+	logLevel        = flag.String("log-level", "info", "least severe events logged: debug, info, warn or error")
+)
+
+// set once shutdown started, readiness checks fail so the balancer sends no new requests
+var draining atomic.Bool
+
+// set from -log-format and -log-level once flags are parsed
+var events = logging.Default()
 
 const confResponseDelaySec = "CONF_RESPONSE_DELAY_SEC"
 const confHealthFailure = "CONF_HEALTH_FAILURE"
-const databaseURL = "http://database:8080/db/solo"
+const confTeamName = "TEAM_NAME"
+
+// paths of the database API below -db-url
+const databaseKeysPath = "/api/v1/db/"
+const databaseReadyPath = "/ready"
 
 type Payload struct {
 	Value string `json:"value"`
 }
 
 func main() {
+	flag.Parse()
+	if err := loadConfig(flag.CommandLine, os.LookupEnv); err != nil {
+		log.Fatal(err)
+	}
+	var err error
+	if events, err = logging.New(*logFormat, *logLevel, os.Stderr); err != nil {
+		log.Fatal(err)
+	}
 	h := new(http.ServeMux)
+	metrics := NewMetrics()
 
-	h.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
+	// liveness, the process is up
+	h.Handle("/health", metrics.Instrument("/health", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Set("content-type", "text/plain")
-		if failConfig := os.Getenv(confHealthFailure); failConfig == "true" {
+		if healthFailure() {
 			rw.WriteHeader(http.StatusInternalServerError)
 			_, _ = rw.Write([]byte("FAILURE"))
 		} else {
 			rw.WriteHeader(http.StatusOK)
 			_, _ = rw.Write([]byte("OK"))
 		}
-	})
-
-	report := make(Report)
-
-	h.HandleFunc("/api/v1/some-data", func(rw http.ResponseWriter, r *http.Request) {
-		respDelayString := os.Getenv(confResponseDelaySec)
-		if delaySec, parseErr := strconv.Atoi(respDelayString); parseErr == nil && delaySec > 0 && delaySec < 300 {
-			time.Sleep(time.Duration(delaySec) * time.Second)
-		}
-
-		report.Process(r)
+	})))
+	// readiness, checked by the balancer to keep traffic away while the database is out of reach
+	dbClient := newDatabaseClient(*dbTimeout)
+	h.Handle("/ready", metrics.Instrument("/ready", readyHandler(newDependencyProbe(dbClient, strings.TrimSuffix(*dbURL, "/")+databaseReadyPath, *readyCache))))
+
+	report := NewReport()
+	key := resolveKey(*dataKey)
+	keysURL := strings.TrimSuffix(*dbURL, "/") + databaseKeysPath
+	events.Info("serving key", "key", key)
+
+	data := &dataHandler{
+		client:     dbClient,
+		url:        keyURL(keysURL, key),
+		keysURL:    keysURL,
+		cache:      newValueCache(*cacheTTL, *cacheMaxStale),
+		breaker:    newCircuitBreaker(*breakerFailures, *breakerCooldown),
+		staleAfter: *cacheStaleAfter,
+		maxDelay:   *maxRequestDelay,
+		metrics:    metrics,
+	}
+	h.Handle("/api/v1/some-data", metrics.Instrument("/api/v1/some-data", report.Track(readinessGate(data))))
+
+	h.Handle("/report", metrics.Instrument("/report", report))
+	h.Handle("/metrics", metrics)
+	// changes the failure mode and delay at runtime for failover tests. The
+	// balancer forwards it like any other path, so it is off unless asked for.
+	if *chaosEnabled {
+		h.HandleFunc(adminChaosPath, chaosHandler)
+	}
 
-		// Call database using http.DefaultClient
-		resp, err := http.Get(databaseURL)
-		if err != nil {
-			http.Error(rw, "Error getting data", http.StatusInternalServerError)
-			return
-		}
-		defer resp.Body.Close()
+	server := httptools.CreateServer(*port, httptools.RequestIDMiddleware(h))
+	server.Start()
 
-		body, err := ioutil.ReadAll(resp.Body)
+	// Init database, which may still be starting
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		err := seedDatabase(ctx, dbClient, keyURL(keysURL, key), Payload{Value: "2023-06-16"}, seedBackoff)
 		if err != nil {
-			http.Error(rw, "Error reading data", http.StatusInternalServerError)
+			events.Error("database not seeded", "error", err)
 			return
 		}
+		seeded.Store(true)
+		events.Info("database seeded")
+	}()
 
-		rw.Header().Set("content-type", "application/json")
-		rw.WriteHeader(http.StatusOK)
-		rw.Write(body)
-	})
-
-	h.Handle("/report", report)
-
-	server := httptools.CreateServer(*port, h)
-	server.Start()
-
-	// Init database
-	jsonData := Payload{
-		Value: "2023-06-16",
-	}
-	jsonValue, _ := json.Marshal(jsonData)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
-
-	client := http.Client{}
-	req, err := http.NewRequestWithContext(ctx, "POST", databaseURL, bytes.NewBuffer(jsonValue))
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
+	signal.WaitForTerminationSignal()
 
-	_, err = client.Do(req)
-	if err != nil {
-		fmt.Println(err)
+	// stop accepting connections and let in-flight requests finish
+	draining.Store(true)
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		events.Error("requests not drained", "error", err)
 	}
-
-	signal.WaitForTerminationSignal()
+	events.Info("server stopped")
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	dbMaxIdleConns  = flag.Int("db-max-idle-conns", 64, "idle connections kept open to the database, reused by the next calls")
+	dbDialTimeout   = flag.Duration("db-dial-timeout", time.Second, "time to connect to the database")
+	dbKeepAlive     = flag.Duration("db-keep-alive", 30*time.Second, "interval of the TCP keep-alive probes of database connections")
+	dbIdleTimeout   = flag.Duration("db-idle-conn-timeout", 90*time.Second, "time an idle connection to the database is kept open")
+	dbHeaderTimeout = flag.Duration("db-header-timeout", 0, "time the database has to send the response headers once the request is written, 0 leaves it to -db-timeout")
+)
+
+// client shared by every database call, configured by the db flags. The
+// default transport keeps only 2 idle connections per host, so under load
+// most calls opened a new connection and left it in TIME_WAIT.
+func newDatabaseClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   *dbDialTimeout,
+		KeepAlive: *dbKeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          *dbMaxIdleConns,
+		MaxIdleConnsPerHost:   *dbMaxIdleConns, // the database is the only host
+		IdleConnTimeout:       *dbIdleTimeout,
+		ResponseHeaderTimeout: *dbHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDatabaseClientReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	db := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		_, _ = rw.Write([]byte(`{"key":"solo","value":"a"}`))
+	}))
+	db.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	db.Start()
+	defer db.Close()
+	d := &dataHandler{client: newDatabaseClient(time.Second), url: db.URL}
+
+	// two rounds of 10 concurrent calls, the second one runs on the idle connections of the first
+	for round := 0; round < 2; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				d.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/some-data", nil))
+				if rec.Code != http.StatusOK {
+					t.Errorf("Unexpected status %d", rec.Code)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if n := conns.Load(); n > 10 {
+		t.Errorf("Unexpected %d connections opened for 20 calls, want at most 10", n)
+	}
+	transport := d.client.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != *dbMaxIdleConns || transport.IdleConnTimeout != *dbIdleTimeout {
+		t.Errorf("Unexpected transport settings %d %s", transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+}
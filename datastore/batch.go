@@ -0,0 +1,31 @@
+package datastore
+
+// WriteBatch collects writes that are applied atomically by Db.Write
+type WriteBatch struct {
+	entries []entry
+}
+
+func (b *WriteBatch) Put(key, value string) {
+	b.entries = append(b.entries, entry{key: key, value: value})
+}
+
+func (b *WriteBatch) Delete(key string) {
+	b.entries = append(b.entries, entry{key: key, kind: kindDeleted})
+}
+
+// Len returns the number of writes in the batch
+func (b *WriteBatch) Len() int {
+	return len(b.entries)
+}
+
+// Write appends all entries of the batch with a single write to the out segment
+func (db *Db) Write(b *WriteBatch) error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.writeEntries(b.entries)
+}
@@ -0,0 +1,50 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_WriteBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("old", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	var b WriteBatch
+	b.Put("key1", "value1")
+	b.Put("key2", "value2")
+	b.Put("key1", "value3")
+	b.Delete("old")
+	if b.Len() != 4 {
+		t.Errorf("Expected 4 writes in the batch, got %d", b.Len())
+	}
+	if err := db.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][]string{{"key1", "value3"}, {"key2", "value2"}} {
+		value, err := db.Get(pair[0])
+		if err != nil {
+			t.Fatalf("Cannot get %s: %s", pair[0], err)
+		}
+		if value != pair[1] {
+			t.Errorf("Bad value returned expected %s, got %s", pair[1], value)
+		}
+	}
+	if _, err := db.Get("old"); err != ErrNotFound {
+		t.Errorf("Expected the key to be deleted by the batch, got %v", err)
+	}
+}
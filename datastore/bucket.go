@@ -0,0 +1,66 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// separates the bucket name from the key in the flat key space
+const bucketSeparator = "/"
+
+var ErrInvalidBucket = fmt.Errorf("bucket name must be non-empty and must not contain %q", bucketSeparator)
+
+// Bucket is a namespace of keys. Keys of a bucket are stored as "<bucket>/<key>"
+// so they can also be reached through Db using the full name.
+type Bucket struct {
+	db     *Db
+	prefix string
+}
+
+// Bucket returns the bucket with the given name, buckets need not be created
+func (db *Db) Bucket(name string) (*Bucket, error) {
+	if name == "" || strings.Contains(name, bucketSeparator) {
+		return nil, ErrInvalidBucket
+	}
+	return &Bucket{db: db, prefix: name + bucketSeparator}, nil
+}
+
+func (b *Bucket) Get(key string) (string, error) {
+	return b.db.Get(b.prefix + key)
+}
+
+func (b *Bucket) Put(key, value string) error {
+	return b.db.Put(b.prefix+key, value)
+}
+
+func (b *Bucket) Delete(key string) error {
+	return b.db.Delete(b.prefix + key)
+}
+
+// NewIterator walks keys of the bucket, see Db.NewIterator
+func (b *Bucket) NewIterator(prefix, after string) *Iterator {
+	if after != "" {
+		after = b.prefix + after
+	}
+	it := b.db.NewIterator(b.prefix+prefix, after)
+	it.trim = len(b.prefix)
+	return it
+}
+
+// Drop deletes every key of the bucket with a single write and returns the number of deleted keys
+func (b *Bucket) Drop() (int, error) {
+	db := b.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var tombstones []entry
+	for key := range db.fileIndex {
+		if strings.HasPrefix(key, b.prefix) {
+			tombstones = append(tombstones, entry{key: key, kind: kindDeleted})
+		}
+	}
+	if len(tombstones) == 0 {
+		return 0, nil
+	}
+	return len(tombstones), db.writeEntries(tombstones)
+}
@@ -0,0 +1,71 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_Bucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sessions, err := db.Bucket("sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := db.Bucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Bucket("a/b"); err != ErrInvalidBucket {
+		t.Errorf("Expected ErrInvalidBucket, got %v", err)
+	}
+
+	if err := sessions.Put("abc", "session"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Put("def", "session"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("abc", "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := users.Get("abc"); err != nil || value != "user" {
+		t.Errorf("Bad value returned expected user, got %s (%v)", value, err)
+	}
+	if value, err := db.Get("sessions/abc"); err != nil || value != "session" {
+		t.Errorf("Bad value returned expected session, got %s (%v)", value, err)
+	}
+
+	var keys []string
+	for it := sessions.NewIterator("", "abc"); it.Next(); {
+		keys = append(keys, it.Key())
+		if value, err := it.Value(); err != nil || value != "session" {
+			t.Errorf("Bad value returned expected session, got %s (%v)", value, err)
+		}
+	}
+	if len(keys) != 1 || keys[0] != "def" {
+		t.Errorf("Unexpected bucket keys %v", keys)
+	}
+
+	if n, err := sessions.Drop(); err != nil || n != 2 {
+		t.Errorf("Expected 2 dropped keys, got %d (%v)", n, err)
+	}
+	if _, err := sessions.Get("abc"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := users.Get("abc"); err != nil {
+		t.Errorf("Dropping a bucket affected another one: %v", err)
+	}
+}
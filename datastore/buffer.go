@@ -0,0 +1,108 @@
+package datastore
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// SetWriteBuffer makes Put append entries into an in-memory buffer of the given size
+// instead of issuing a write syscall per entry. The buffer is flushed when it is full,
+// every flushInterval (if positive), on Sync and on Close.
+// A zero size switches back to unbuffered writes.
+func (db *Db) SetWriteBuffer(size int, flushInterval time.Duration) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.flush(); err != nil {
+		return err
+	}
+	db.stopFlusher()
+
+	if size <= 0 {
+		db.outBuf = nil
+		return nil
+	}
+	db.outBuf = bufio.NewWriterSize(db.out, size)
+
+	if flushInterval > 0 {
+		db.flushStop = make(chan struct{})
+		go db.flushPeriodically(flushInterval, db.flushStop)
+	}
+	return nil
+}
+
+// Sync flushes buffered writes and commits the active segment to stable storage
+func (db *Db) Sync() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	db.bufMu.Lock()
+	defer db.bufMu.Unlock()
+
+	if err := db.flush(); err != nil {
+		return err
+	}
+	return db.out.Sync()
+}
+
+// write appends data to the active segment, through the buffer when it is enabled
+func (db *Db) write(data []byte) (int, error) {
+	if db.outBuf != nil {
+		return db.outBuf.Write(data)
+	}
+	return db.out.Write(data)
+}
+
+// number of bytes written by Put but not yet flushed to the active segment
+func (db *Db) buffered() int {
+	if db.outBuf == nil {
+		return 0
+	}
+	return db.outBuf.Buffered()
+}
+
+// flush must be called either with db.mu locked for writing or with db.mu locked
+// for reading and db.bufMu held
+func (db *Db) flush() error {
+	if db.outBuf == nil || db.outBuf.Buffered() == 0 {
+		return nil
+	}
+	return db.outBuf.Flush()
+}
+
+// flushForRead makes entries of the active segment visible to readers, db.mu must be locked for reading
+func (db *Db) flushForRead(segment int) error {
+	if segment != db.outSegment || db.outBuf == nil {
+		return nil
+	}
+	db.bufMu.Lock()
+	defer db.bufMu.Unlock()
+	return db.flush()
+}
+
+func (db *Db) flushPeriodically(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.mu.RLock()
+			db.bufMu.Lock()
+			if err := db.flush(); err != nil {
+				fmt.Println("Error flushing write buffer:", err)
+			}
+			db.bufMu.Unlock()
+			db.mu.RUnlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (db *Db) stopFlusher() {
+	if db.flushStop != nil {
+		close(db.flushStop)
+		db.flushStop = nil
+	}
+}
@@ -0,0 +1,110 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDb_WriteBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-buffer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.SetWriteBuffer(4096, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	segmentSize := func() int64 {
+		info, err := os.Stat(filepath.Join(dir, defaultOutFileName+"-0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	t.Run("buffered put", func(t *testing.T) {
+		if err := db.Put("key1", "value1"); err != nil {
+			t.Fatal(err)
+		}
+		if size := segmentSize(); size != 0 {
+			t.Errorf("Expected the entry to stay in the buffer, segment size is %d", size)
+		}
+	})
+
+	t.Run("read flushes", func(t *testing.T) {
+		value, err := db.Get("key1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "value1" {
+			t.Errorf("Bad value returned expected value1, got %s", value)
+		}
+		if segmentSize() == 0 {
+			t.Error("Expected the buffer to be flushed by Get")
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		before := segmentSize()
+		if err := db.Put("key2", "value2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if segmentSize() <= before {
+			t.Error("Expected Sync to flush the buffer")
+		}
+	})
+
+	t.Run("periodic flush", func(t *testing.T) {
+		if err := db.SetWriteBuffer(4096, 10*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		before := segmentSize()
+		if err := db.Put("key3", "value3"); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for segmentSize() <= before {
+			if time.Now().After(deadline) {
+				t.Fatal("Buffer was not flushed by the timer")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	t.Run("close flushes", func(t *testing.T) {
+		if err := db.SetWriteBuffer(4096, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Put("key4", "value4"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		db, err = NewDb(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := db.Get("key4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "value4" {
+			t.Errorf("Bad value returned expected value4, got %s", value)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package datastore
+
+import (
+	"encoding/binary"
+)
+
+// CompareAndSwap stores newValue only if the key currently holds oldValue and reports
+// whether the value was swapped. ErrNotFound is returned for missing keys.
+func (db *Db) CompareAndSwap(key, oldValue, newValue string) (bool, error) {
+	return db.compareAndSwap(entry{key: key, value: oldValue}, entry{key: key, value: newValue})
+}
+
+// CompareAndSwapInt64 is CompareAndSwap for values written with PutInt64
+func (db *Db) CompareAndSwapInt64(key string, oldValue, newValue int64) (bool, error) {
+	return db.compareAndSwap(int64Entry(key, oldValue), int64Entry(key, newValue))
+}
+
+func (db *Db) compareAndSwap(old, next entry) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	current, repair, err := db.get(old.key)
+	if repair != nil {
+		db.repairIndex(repair)
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.kind != old.kind {
+		return false, ErrWrongType
+	}
+	if current.value != old.value {
+		return false, nil
+	}
+	return true, db.writeEntries([]entry{next})
+}
+
+func int64Entry(key string, value int64) entry {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(value))
+	return entry{key: key, value: string(buf[:]), kind: kindInt64}
+}
@@ -0,0 +1,51 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_CompareAndSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-cas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("key", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if swapped, err := db.CompareAndSwap("key", "v1", "v2"); err != nil || !swapped {
+		t.Errorf("Expected the value to be swapped, got %t (%v)", swapped, err)
+	}
+	if swapped, err := db.CompareAndSwap("key", "v1", "v3"); err != nil || swapped {
+		t.Errorf("Expected a stale swap to fail, got %t (%v)", swapped, err)
+	}
+	if value, _ := db.Get("key"); value != "v2" {
+		t.Errorf("Bad value returned expected v2, got %s", value)
+	}
+	if _, err := db.CompareAndSwap("missing", "", "value"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := db.PutInt64("counter", 1); err != nil {
+		t.Fatal(err)
+	}
+	if swapped, err := db.CompareAndSwapInt64("counter", 1, 2); err != nil || !swapped {
+		t.Errorf("Expected the counter to be swapped, got %t (%v)", swapped, err)
+	}
+	if value, _ := db.GetInt64("counter"); value != 2 {
+		t.Errorf("Bad value returned expected 2, got %d", value)
+	}
+	if _, err := db.CompareAndSwap("counter", "2", "3"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Clone writes a compacted copy of the database into targetDir, only the latest
+// value of every key is copied. Writes are blocked while the copy is made,
+// reads continue to be served.
+func (db *Db) Clone(targetDir string) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	db.bufMu.Lock()
+	err := db.flush()
+	db.bufMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(targetDir, 0o700); err != nil {
+		return err
+	}
+	existing, err := listSegments(targetDir)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("target directory %s already contains segments", targetDir)
+	}
+
+	segments, err := listSegments(db.dir())
+	if err != nil {
+		return err
+	}
+
+	// write into a temporary file so a failed clone never looks like a valid database
+	outputPath := filepath.Join(targetDir, defaultOutFileName+"-0")
+	tmpPath := filepath.Join(targetDir, "clone.tmp")
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(file)
+
+	for _, segment := range segments {
+		var writeErr error
+		err = readEntries(db.segmentPath(segment), func(e entry, offset int64) {
+			if writeErr != nil {
+				return
+			}
+			if s, ok := db.fileIndex[e.key]; ok && s == segment && db.index[e.key] == offset {
+				_, writeErr = out.Write(e.Encode())
+			}
+		})
+		if err == nil {
+			err = writeErr
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	fmt.Println("Cloned database to", targetDir)
+	return os.Rename(tmpPath, outputPath)
+}
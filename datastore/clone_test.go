@@ -0,0 +1,77 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDb_Clone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "source"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDb(filepath.Join(dir, "source"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pairs := [][]string{
+		{"key1", "old"},
+		{"key2", "value2"},
+		{"key1", "value1"},
+	}
+	for _, pair := range pairs {
+		if err := db.Put(pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(dir, "target")
+	if err := db.Clone(target); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("independent copy", func(t *testing.T) {
+		if err := db.Put("key2", "changed"); err != nil {
+			t.Fatal(err)
+		}
+
+		clone, err := NewDb(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer clone.Close()
+
+		for _, pair := range [][]string{{"key1", "value1"}, {"key2", "value2"}} {
+			value, err := clone.Get(pair[0])
+			if err != nil {
+				t.Fatalf("Cannot get %s: %s", pair[0], err)
+			}
+			if value != pair[1] {
+				t.Errorf("Bad value returned expected %s, got %s", pair[1], value)
+			}
+		}
+
+		segments, err := clone.Segments()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(segments) != 1 || segments[0].Entries != 2 {
+			t.Errorf("Expected one compacted segment with 2 entries, got %+v", segments)
+		}
+	})
+
+	t.Run("non-empty target", func(t *testing.T) {
+		if err := db.Clone(target); err == nil {
+			t.Error("Expected an error when cloning into an existing database")
+		}
+	})
+}
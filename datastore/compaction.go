@@ -0,0 +1,57 @@
+package datastore
+
+import (
+	"sync/atomic"
+)
+
+// CompactionFilter is called for every entry rewritten during a merge.
+// Returning keep=false drops the entry, otherwise newValue is stored instead of value.
+type CompactionFilter func(key, value string) (keep bool, newValue string)
+
+// SetCompactionFilter registers a filter applied by subsequent merges, nil removes it
+func (db *Db) SetCompactionFilter(filter CompactionFilter) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.compactionFilter = filter
+}
+
+type CompactionResult struct {
+	SegmentsBefore int   `json:"segmentsBefore"`
+	SegmentsAfter  int   `json:"segmentsAfter"`
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
+// Compact seals the out segment and merges all segments into one,
+// only the latest values of live keys are kept
+func (db *Db) Compact() (CompactionResult, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var res CompactionResult
+	before, err := listSegments(db.dir())
+	if err != nil {
+		return res, err
+	}
+	usageBefore, err := db.measureDiskUsage()
+	if err != nil {
+		return res, err
+	}
+	res.SegmentsBefore = len(before)
+
+	if db.outOffset > 0 || db.buffered() > 0 {
+		if err := db.rotate(); err != nil {
+			return res, err
+		}
+	}
+	if err := db.merge(atomic.AddInt64(&goroutineID, 1), true); err != nil {
+		return res, err
+	}
+
+	after, err := listSegments(db.dir())
+	if err != nil {
+		return res, err
+	}
+	res.SegmentsAfter = len(after)
+	res.ReclaimedBytes = usageBefore - db.diskUsage
+	return res, nil
+}
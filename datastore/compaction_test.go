@@ -0,0 +1,117 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDb_CompactionFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-filter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetCompactionFilter(func(key, value string) (bool, string) {
+		if strings.HasPrefix(key, "session-") {
+			return false, ""
+		}
+		if key == "user" {
+			return true, "scrubbed"
+		}
+		return true, value
+	})
+
+	pairs := [][]string{
+		{"session-1", "expired"},
+		{"user", "secret"},
+		{"session-2", "expired"},
+		{"other", "value"},
+		{"last", "value"}, // stays in the out segment
+	}
+	for _, pair := range pairs {
+		if err := db.Put(pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.wg.Wait()
+
+	for _, key := range []string{"session-1", "session-2"} {
+		if _, err := db.Get(key); err != ErrNotFound {
+			t.Errorf("Expected %s to be dropped, got %v", key, err)
+		}
+	}
+
+	expected := map[string]string{"user": "scrubbed", "other": "value", "last": "value"}
+	for key, want := range expected {
+		value, err := db.Get(key)
+		if err != nil {
+			t.Fatalf("Cannot get %s: %s", key, err)
+		}
+		if value != want {
+			t.Errorf("Bad value returned for %s expected %s, got %s", key, want, value)
+		}
+	}
+}
+
+func TestDb_Compact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := db.Put("key", strings.Repeat("v", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Put("other", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := db.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.SegmentsBefore != 1 {
+		t.Errorf("Expected 1 segment before compaction, got %d", res.SegmentsBefore)
+	}
+	if res.ReclaimedBytes <= 0 {
+		t.Errorf("Expected reclaimed bytes, got %d", res.ReclaimedBytes)
+	}
+
+	segments, err := db.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != res.SegmentsAfter {
+		t.Errorf("Reported %d segments after compaction, found %d", res.SegmentsAfter, len(segments))
+	}
+	for _, s := range segments {
+		if s.Entries != s.LiveEntries {
+			t.Errorf("Segment %d still has dead entries: %+v", s.Index, s)
+		}
+	}
+
+	if value, err := db.Get("key"); err != nil || value != strings.Repeat("v", 10) {
+		t.Errorf("Bad value returned after compaction: %s (%v)", value, err)
+	}
+	if value, err := db.Get("other"); err != nil || value != "value" {
+		t.Errorf("Bad value returned after compaction: %s (%v)", value, err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/fs"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -21,11 +22,15 @@ import (
 
 const (
 	defaultOutFileName = "data-segment"
+	mergeFileName      = "merge-segment" // merged data before it replaces segment 0
 	TenMegabytes       = 10 * 1024 * 1024
 	workerPoolSize     = 20 // Change this value to control the maximum number of concurrent file descriptors
+	MaxKeySize         = 1024
 )
 
 var ErrNotFound = fmt.Errorf("record does not exist")
+var ErrKeyTooLarge = fmt.Errorf("key is longer than %d bytes", MaxKeySize)
+var ErrValueTooLarge = fmt.Errorf("value is too large")
 var goroutineID int64
 
 type hashIndex map[string]int64
@@ -33,22 +38,45 @@ type hashIndex map[string]int64
 // keep segment indexes per key
 type fileIndex map[string]int
 
+// keep value sizes per key, used for stats
+type sizeIndex map[string]int
+
 type Db struct {
 	out        *os.File
+	outBuf     *bufio.Writer // nil unless enabled with SetWriteBuffer
 	outPath    string
 	outOffset  int64
 	outSegment int
 
 	// indexes:
-	index     hashIndex // key -> offset
-	fileIndex fileIndex // key -> segment
+	index     hashIndex         // key -> offset
+	fileIndex fileIndex         // key -> segment
+	sizeIndex sizeIndex         // key -> value size
+	versions  map[string]uint64 // key -> sequence number of the last write, used by transactions
+	expiries  map[string]int64  // key -> expiration time of values written with a TTL
+	legacy    map[string]bool   // keys whose live entry was written without the kind byte
+	writeSeq  uint64
 
 	maxFileSize int64
+	compactions int   // number of finished merges
+	diskUsage   int64 // total size of segment files
+
+	quota       int64 // max disk usage, 0 means unlimited
+	quotaPolicy QuotaPolicy
 
 	wg sync.WaitGroup // for unit tests
 	mu sync.RWMutex   // synchronize access to the file index
 
+	bufMu     sync.Mutex    // guards flushing of outBuf by readers
+	flushStop chan struct{} // stops the periodic flusher
+
 	workerPool *semaphore.Weighted
+
+	hotKeys *hotKeyTracker // nil unless enabled with TrackHotKeys
+
+	compactionFilter CompactionFilter
+
+	watchers watchers
 }
 
 func NewDb(dir string, maxFileSize ...int64) (*Db, error) {
@@ -76,6 +104,10 @@ func NewDb(dir string, maxFileSize ...int64) (*Db, error) {
 		out:         f,
 		index:       make(hashIndex),
 		fileIndex:   make(fileIndex),
+		sizeIndex:   make(sizeIndex),
+		versions:    make(map[string]uint64),
+		expiries:    make(map[string]int64),
+		legacy:      make(map[string]bool),
 		maxFileSize: size,
 		outSegment:  maxSegmentIndex,
 		workerPool:  semaphore.NewWeighted(workerPoolSize),
@@ -84,6 +116,9 @@ func NewDb(dir string, maxFileSize ...int64) (*Db, error) {
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	if db.diskUsage, err = db.measureDiskUsage(); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -91,134 +126,278 @@ const bufSize = 8192
 
 // shall recover data indexes for all avaliable segments
 func (db *Db) recover() error {
-	files, err := ioutil.ReadDir(filepath.Dir(db.outPath))
+	idx, err := scanSegments(db.dir())
+	if idx != nil {
+		db.index, db.fileIndex, db.sizeIndex, db.expiries, db.legacy = idx.index, idx.fileIndex, idx.sizeIndex, idx.expiries, idx.legacy
+		db.outOffset = idx.offsets[db.outSegment]
+	}
+	return err
+}
+
+// RebuildIndex re-scans all segments and replaces the in-memory indexes.
+// Reads are served from the old indexes while the scan is running.
+func (db *Db) RebuildIndex() error {
+	db.mu.RLock()
+	db.bufMu.Lock()
+	err := db.flush()
+	db.bufMu.Unlock()
+	if err != nil {
+		db.mu.RUnlock()
+		return err
+	}
+	segment, offset, compactions := db.outSegment, db.outOffset, db.compactions
+	idx, err := scanSegments(db.dir())
+	db.mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	// sort the files in ascending order,
-	// current-data-1, current-data-2 etc
-	// it is important to maintain c orrect indexes
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// writes made between the scan and the swap are missing, scan again without letting anyone in
+	if segment != db.outSegment || offset != db.outOffset || compactions != db.compactions {
+		if err := db.flush(); err != nil {
+			return err
+		}
+		if idx, err = scanSegments(db.dir()); err != nil {
+			return err
+		}
+	}
+
+	db.index, db.fileIndex, db.sizeIndex, db.expiries, db.legacy = idx.index, idx.fileIndex, idx.sizeIndex, idx.expiries, idx.legacy
+	db.outOffset = idx.offsets[db.outSegment]
+	return nil
+}
+
+// indexes built by scanning segment files
+type segmentIndex struct {
+	index     hashIndex
+	fileIndex fileIndex
+	sizeIndex sizeIndex
+	expiries  map[string]int64
+	legacy    map[string]bool
+	offsets   map[int]int64 // segment -> end of the last entry
+}
+
+// read all segments in dir and build indexes from scratch
+func scanSegments(dir string) (*segmentIndex, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &segmentIndex{
+		index:     make(hashIndex),
+		fileIndex: make(fileIndex),
+		sizeIndex: make(sizeIndex),
+		expiries:  make(map[string]int64),
+		legacy:    make(map[string]bool),
+		offsets:   make(map[int]int64),
+	}
+
+	// segments are sorted in ascending order,
+	// it is important to maintain correct indexes
 	// later added data override old ones
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
+	for _, segment := range segments {
+		filePath := filepath.Join(dir, defaultOutFileName+"-"+strconv.Itoa(segment))
+		err := readEntries(filePath, func(e entry, offset int64) {
+			if e.deleted() {
+				delete(idx.index, e.key)
+				delete(idx.fileIndex, e.key)
+				delete(idx.sizeIndex, e.key)
+				delete(idx.expiries, e.key)
+				delete(idx.legacy, e.key)
+			} else {
+				idx.index[e.key] = offset
+				idx.fileIndex[e.key] = segment
+				idx.sizeIndex[e.key] = len(e.value)
+				if e.expires != 0 {
+					idx.expiries[e.key] = e.expires
+				} else {
+					delete(idx.expiries, e.key)
+				}
+				if e.legacy {
+					idx.legacy[e.key] = true
+				} else {
+					delete(idx.legacy, e.key)
+				}
+			}
+			// entries written before kinds existed are a byte shorter than they encode to
+			idx.offsets[segment] = offset + int64(e.storedSize())
+		})
+		if err != nil {
+			return idx, err
+		}
+	}
+	return idx, nil
+}
 
+// list segment numbers found in dir in ascending order
+func listSegments(dir string) ([]int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]int, 0, len(files))
 	for _, file := range files {
 		if file.IsDir() || !strings.HasPrefix(file.Name(), defaultOutFileName+"-") {
 			continue
 		}
-
-		filePath := filepath.Join(filepath.Dir(db.outPath), file.Name())
-
 		// extract segment index from the filename
 		segment, err := strconv.Atoi(strings.TrimPrefix(file.Name(), defaultOutFileName+"-"))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		segments = append(segments, segment)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
 
-		// Open the file
-		input, err := os.Open(filePath)
-		if err != nil {
+// decode entries of a segment file one by one, fn gets each entry with its offset
+func readEntries(filePath string, fn func(e entry, offset int64)) error {
+	input, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	var buf [bufSize]byte
+	var offset int64
+	in := bufio.NewReaderSize(input, bufSize)
+
+	// read data from file and decode
+	for {
+		var data []byte
+		header, err := in.Peek(bufSize)
+		if err == io.EOF {
+			if len(header) == 0 {
+				return nil
+			}
+		} else if err != nil {
 			return err
 		}
+		if len(header) < 4 {
+			return fmt.Errorf("corrupted file")
+		}
+		size := binary.LittleEndian.Uint32(header)
 
-		// reset offset for a new file
-		db.outOffset = 0
-
-		var buf [bufSize]byte
-		in := bufio.NewReaderSize(input, bufSize)
-
-		// read data from file and decode
-		for err == nil {
-			var (
-				header, data []byte
-				n            int
-			)
-			header, err = in.Peek(bufSize)
-			if err == io.EOF {
-				if len(header) == 0 {
-					break
-				}
-			} else if err != nil {
-				input.Close()
-				return err
-			}
-			size := binary.LittleEndian.Uint32(header)
-
-			if size < bufSize {
-				data = buf[:size]
-			} else {
-				data = make([]byte, size)
-			}
-			n, err = in.Read(data)
-
-			if err == nil {
-				if n != int(size) {
-					input.Close()
-					return fmt.Errorf("corrupted file")
-				}
-
-				var e entry
-				e.Decode(data)
-				db.index[e.key] = db.outOffset // out offset relevant for the last segment only
-				db.outOffset += int64(n)
-				db.fileIndex[e.key] = segment
-			}
+		if size < bufSize {
+			data = buf[:size]
+		} else {
+			data = make([]byte, size)
+		}
+		n, err := io.ReadFull(in, data)
+		if err == io.EOF {
+			return nil
+		} else if err != nil || n != int(size) {
+			return fmt.Errorf("corrupted file")
 		}
 
-		// Close the file, maybe left last one opened after recovering?
-		input.Close()
+		var e entry
+		e.Decode(data)
+		fn(e, offset)
+		offset += int64(n)
 	}
-
-	return nil
 }
 
 func (db *Db) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.stopFlusher()
+	db.watchers.closeAll()
+	if err := db.flush(); err != nil {
+		db.out.Close()
+		return err
+	}
 	return db.out.Close()
 }
 
 func (db *Db) Get(key string) (string, error) {
+	e, err := db.getEntry(key)
+	if err != nil {
+		return "", err
+	}
+	if e.kind != kindValue {
+		return "", ErrWrongType
+	}
+	return e.value, nil
+}
+
+func (db *Db) getEntry(key string) (entry, error) {
 
-	db.mu.RLock()         // Lock for reading
-	defer db.mu.RUnlock() // Unlock after operation
+	db.mu.RLock() // Lock for reading
+	e, repair, err := db.get(key)
+	db.mu.RUnlock() // Unlock after operation
 
+	if repair != nil {
+		db.applyRepair(repair)
+	}
+	return e, err
+}
+
+// read the entry of the key, db.mu must be locked for reading.
+// When the index doesn't match segment data, the key is looked up by scanning
+// segments and the returned repair should be applied to the index.
+func (db *Db) get(key string) (entry, *indexRepair, error) {
 	segment, ok := db.fileIndex[key]
 	if !ok {
-		return "", ErrNotFound
+		return entry{}, nil, ErrNotFound
 	}
 
 	position, ok := db.index[key]
-	if !ok {
-		return "", ErrNotFound
+	if !ok || db.expired(key) {
+		return entry{}, nil, ErrNotFound
+	}
+
+	if db.hotKeys != nil {
+		db.hotKeys.sample(key)
+	}
+
+	if err := db.flushForRead(segment); err != nil {
+		return entry{}, nil, err
 	}
 
 	// Wait until a worker is available
 	if err := db.workerPool.Acquire(context.Background(), 1); err != nil {
 		// This should never happen under normal circumstances
-		return "", fmt.Errorf("acquire worker: %w", err)
+		return entry{}, nil, fmt.Errorf("acquire worker: %w", err)
 	}
 	defer db.workerPool.Release(1)
 
-	filePath := filepath.Join(filepath.Dir(db.outPath), defaultOutFileName+"-"+strconv.Itoa(segment))
+	e, err := db.readAt(key, segment, position)
+	if err == errStaleIndex {
+		return db.findKey(key, segment, position)
+	}
+	return e, nil, err
+}
+
+// read the entry at the given position and make sure it belongs to the key
+func (db *Db) readAt(key string, segment int, position int64) (entry, error) {
+	filePath := db.segmentPath(segment)
 	fmt.Println("Get segment:", filepath.Base(filePath))
 	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
+	if os.IsNotExist(err) {
+		return entry{}, errStaleIndex
+	} else if err != nil {
+		return entry{}, err
 	}
 	defer file.Close()
+	return readEntryAt(file, key, position)
+}
 
-	_, err = file.Seek(position, 0)
-	if err != nil {
-		return "", err
+// read the entry of the key at the position of an open segment file
+func readEntryAt(file *os.File, key string, position int64) (entry, error) {
+	reader := bufio.NewReader(io.NewSectionReader(file, position, math.MaxInt64-position))
+	e, err := readEntry(reader)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == errCorruptedEntry || (err == nil && (e.key != key || e.deleted())) {
+		return entry{}, errStaleIndex
+	} else if err != nil {
+		return entry{}, err
 	}
-
-	reader := bufio.NewReader(file)
-	value, err := readValue(reader)
-	if err != nil {
-		return "", err
-	}
-	return value, nil
+	return e, nil
 }
 
 func (db *Db) Put(key, value string) error {
@@ -226,46 +405,160 @@ func (db *Db) Put(key, value string) error {
 	db.mu.Lock()         // Lock for writing
 	defer db.mu.Unlock() // Unlock after operation
 
+	return db.writeEntries([]entry{{key: key, value: value}})
+}
+
+// Delete removes the key by writing a tombstone
+func (db *Db) Delete(key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.fileIndex[key]; !ok {
+		return ErrNotFound
+	}
+	return db.writeEntries([]entry{{key: key, kind: kindDeleted}})
+}
+
+// append entries to the out segment with a single write and update indexes,
+// db.mu must be locked for writing
+func (db *Db) writeEntries(entries []entry) error {
+	if err := db.rotateIfFull(); err != nil {
+		return err
+	}
+
+	size := 0
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		if len(e.key) > MaxKeySize {
+			return ErrKeyTooLarge
+		}
+		size += e.size()
+		keys[i] = e.key
+	}
+	if err := db.checkQuota(keys, size); err != nil {
+		return err
+	}
+
+	data := make([]byte, 0, size)
+	for _, e := range entries {
+		data = append(data, e.Encode()...)
+	}
+
+	n, err := db.write(data)
+	if err == nil {
+		for _, e := range entries {
+			db.indexEntry(e, len(e.value))
+		}
+		db.diskUsage += int64(n)
+	}
+	return err
+}
+
+// start a new segment and merge the sealed ones once the out segment exceeds its size limit
+func (db *Db) rotateIfFull() error {
 	fileInfo, err := db.out.Stat()
 	if err != nil {
 		return err
 	}
+	if fileInfo.Size()+int64(db.buffered()) <= db.maxFileSize {
+		return nil
+	}
+	if err := db.rotate(); err != nil {
+		return err
+	}
 
-	// Check if the file size is exceeding the limit
-	if fileInfo.Size() > db.maxFileSize {
-		// Close the current file
-		db.out.Close()
+	// Start a goroutine to merge segments to delete not actual data
+	db.wg.Add(1) // increment the WaitGroup counter before starting the goroutine
+	go func(id int64) {
+		defer db.wg.Done() // decrement the counter when the function completes
+		fmt.Printf("Goroutine %d is merging segment files\n", id)
+		db.mergeSegmentFiles(id)
+	}(atomic.AddInt64(&goroutineID, 1)) // generate unique ID and pass it as an argument
+	return nil
+}
 
-		// Open a new segment file
-		db.outSegment++
-		db.outPath = filepath.Join(filepath.Dir(db.outPath), defaultOutFileName+"-"+strconv.Itoa(db.outSegment))
-		db.out, err = os.OpenFile(db.outPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
-		if err != nil {
-			return err
+// update indexes for an entry just written at outOffset and move the offset past it.
+// valueSize differs from len(e.value) for values written with PutStream.
+func (db *Db) indexEntry(e entry, valueSize int) {
+	if e.deleted() {
+		db.forget(e.key)
+	} else {
+		db.index[e.key] = db.outOffset
+		db.fileIndex[e.key] = db.outSegment
+		db.sizeIndex[e.key] = valueSize
+		delete(db.legacy, e.key)
+		if e.expires != 0 {
+			db.expiries[e.key] = e.expires
+		} else {
+			delete(db.expiries, e.key)
 		}
-		db.outOffset = 0 // reset offset for a new file
+		db.writeSeq++
+		db.versions[e.key] = db.writeSeq
+	}
+	db.outOffset += int64(e.size() - len(e.value) + valueSize)
+	if valueSize != len(e.value) {
+		// the value was streamed into the segment and is not held in memory
+		db.watchers.send(Event{Key: e.key, Streamed: true})
+	} else {
+		db.watchers.notify(e)
+	}
+}
 
-		// Start a goroutine to merge segments to delete not actual data
-		db.wg.Add(1) // increment the WaitGroup counter before starting the goroutine
-		go func(id int64) {
-			defer db.wg.Done() // decrement the counter when the function completes
-			fmt.Printf("Goroutine %d is merging segment files\n", id)
-			db.mergeSegmentFiles(id)
-		}(atomic.AddInt64(&goroutineID, 1)) // generate unique ID and pass it as an argument
+// remove the key from all indexes. Its version moves on instead, transactions that read
+// the key conflict with the removal even when the key was recovered at version 0.
+func (db *Db) forget(key string) {
+	delete(db.index, key)
+	delete(db.fileIndex, key)
+	delete(db.sizeIndex, key)
+	delete(db.expiries, key)
+	delete(db.legacy, key)
+	db.writeSeq++
+	db.versions[key] = db.writeSeq
+}
+
+// seal the current segment and continue writing into a new one
+func (db *Db) rotate() error {
+	// Flush buffered entries and close the current file
+	if err := db.flush(); err != nil {
+		return err
 	}
+	db.out.Close()
 
-	e := entry{
-		key:   key,
-		value: value,
+	// Open a new segment file
+	var err error
+	db.outSegment++
+	db.outPath = db.segmentPath(db.outSegment)
+	db.out, err = os.OpenFile(db.outPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
 	}
+	db.outOffset = 0 // reset offset for a new file
+	if db.outBuf != nil {
+		db.outBuf.Reset(db.out)
+	}
+	return nil
+}
 
-	n, err := db.out.Write(e.Encode())
-	if err == nil {
-		db.index[key] = db.outOffset
-		db.fileIndex[key] = db.outSegment
-		db.outOffset += int64(n)
+// sum sizes of all segment files, buffered writes are included
+func (db *Db) measureDiskUsage() (int64, error) {
+	segments, err := listSegments(db.dir())
+	if err != nil {
+		return 0, err
 	}
-	return err
+	var usage int64
+	for _, segment := range segments {
+		info, err := os.Stat(db.segmentPath(segment))
+		if err != nil {
+			return 0, err
+		}
+		usage += info.Size()
+	}
+	return usage + int64(db.buffered()), nil
+}
+
+// directory holding the segment files
+func (db *Db) dir() string {
+	return filepath.Dir(db.outPath)
 }
 
 // scan directory to get max existing segment file and return its index
@@ -297,12 +590,18 @@ func (db *Db) mergeSegmentFiles(id int64) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	return db.merge(id, false)
+}
+
+// merge all segments except the out one into segment 0, db.mu must be locked for writing.
+// Unless forced, merging is skipped when there is a single sealed segment.
+func (db *Db) merge(id int64, force bool) error {
 	files, err := ioutil.ReadDir(filepath.Dir(db.outPath))
 	if err != nil {
 		return err
 	}
 
-	if len(files) <= 2 {
+	if len(files) <= 2 && !force {
 		fmt.Printf("Goroutine %d skip merging\n", id)
 		return nil // nothing to merge
 	}
@@ -310,81 +609,58 @@ func (db *Db) mergeSegmentFiles(id int64) error {
 	fmt.Printf("Goroutine %d merge files in %s\n", id, filepath.Dir(db.outPath))
 
 	fileNames := GetFilesToMerge(files, db.outSegment)
+	if len(fileNames) == 0 {
+		return nil
+	}
 
 	mergedData := make(map[string]entry)
 
 	for _, fileName := range fileNames {
-
 		filePath := filepath.Join(filepath.Dir(db.outPath), fileName)
-		input, err := os.Open(filePath)
+		err := readEntries(filePath, func(e entry, _ int64) {
+			mergedData[e.key] = e
+		})
 		if err != nil {
 			return err
 		}
-
-		var buf [bufSize]byte
-		in := bufio.NewReaderSize(input, bufSize)
-		for err == nil {
-			var (
-				header, data []byte
-				n            int
-			)
-			header, err = in.Peek(bufSize)
-			if err == io.EOF {
-				if len(header) == 0 {
-					break
-				}
-			} else if err != nil {
-				input.Close()
-				return err
-			}
-			size := binary.LittleEndian.Uint32(header)
-
-			if size < bufSize {
-				data = buf[:size]
-			} else {
-				data = make([]byte, size)
-			}
-			n, err = in.Read(data)
-
-			if err == nil {
-				if n != int(size) {
-					input.Close()
-					return fmt.Errorf("corrupted file")
-				}
-
-				var e entry
-				e.Decode(data)
-				mergedData[e.key] = e
-			}
-		}
-		input.Close()
-	}
-
-	// Remove segment files
-	for index, fileName := range fileNames {
-		if index == 0 {
-			continue // Skip the first file, it will be used for merging
-		}
-		filePath := filepath.Join(filepath.Dir(db.outPath), fileName)
-		err = os.Remove(filePath)
-		if err != nil {
-			fmt.Println("Error removing file:", err)
-			return err
-		}
-		fmt.Println("Removed file:", fileName)
 	}
 
+	// segment 0 is written aside and renamed over the old one, so streams reading
+	// the old file keep reading the bytes they were opened for
 	outputPath := filepath.Join(filepath.Dir(db.outPath), defaultOutFileName+"-0")
-	file, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_TRUNC, 0o600)
+	file, err := os.OpenFile(filepath.Join(filepath.Dir(db.outPath), mergeFileName), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return err
 	}
-	fmt.Println("Merged file:", filepath.Base(outputPath))
 	defer file.Close()
 
 	//var mergedIndex = make(hashIndex)
 	var entryOffset int64 = 0 // keep offset in a file
+	now := timeNow().UnixNano()
 	for _, e := range mergedData {
+		if _, ok := db.fileIndex[e.key]; !ok {
+			continue // the key was deleted or evicted
+		}
+		if e.expired(now) {
+			// forget the key unless a newer version lives in the out segment
+			if segment := db.fileIndex[e.key]; segment != db.outSegment {
+				db.forget(e.key)
+			}
+			continue
+		}
+		if db.compactionFilter != nil && e.kind == kindValue {
+			keep, newValue := db.compactionFilter(e.key, e.value)
+			if !keep {
+				// forget the key unless a newer version lives in the out segment
+				if segment, ok := db.fileIndex[e.key]; ok && segment != db.outSegment {
+					db.forget(e.key)
+				}
+				fmt.Println("Drop", e.key)
+				continue
+			}
+			e.value = newValue
+		}
+
 		n, err := file.Write(e.Encode())
 		fmt.Println("Add", e) // trace what is added
 		if err == nil {
@@ -396,12 +672,43 @@ func (db *Db) mergeSegmentFiles(id int64) error {
 				if segment != db.outSegment {
 					db.index[e.key] = entryOffset
 					db.fileIndex[e.key] = 0
+					db.sizeIndex[e.key] = len(e.value)
+					delete(db.legacy, e.key) // rewritten with the kind byte
 				}
 			}
 			entryOffset += int64(n)
 		}
 	}
 
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), outputPath); err != nil {
+		return err
+	}
+	fmt.Println("Merged file:", filepath.Base(outputPath))
+
+	// Remove segment files
+	for index, fileName := range fileNames {
+		if index == 0 {
+			continue // Skip the first file, it was replaced by the merged one
+		}
+		filePath := filepath.Join(filepath.Dir(db.outPath), fileName)
+		err = os.Remove(filePath)
+		if err != nil {
+			fmt.Println("Error removing file:", err)
+			return err
+		}
+		fmt.Println("Removed file:", fileName)
+	}
+
+	db.compactions++
+	if usage, err := db.measureDiskUsage(); err == nil {
+		db.diskUsage = usage
+	}
 	fmt.Printf("Goroutine %d finished merging\n", id)
 
 	return nil
@@ -418,7 +725,12 @@ func GetFilesToMerge(files []fs.FileInfo, outSegment int) []string {
 		}
 		fileNames = append(fileNames, file.Name())
 	}
-	sort.Strings(fileNames)
+	// sort by segment number, later segments override data of earlier ones
+	sort.Slice(fileNames, func(i, j int) bool {
+		si, _ := strconv.Atoi(strings.TrimPrefix(fileNames[i], defaultOutFileName+"-"))
+		sj, _ := strconv.Atoi(strings.TrimPrefix(fileNames[j], defaultOutFileName+"-"))
+		return si < sj
+	})
 	return fileNames
 
 }
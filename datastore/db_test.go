@@ -1,9 +1,12 @@
 package datastore
 
 import (
+	"encoding/binary"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -146,3 +149,271 @@ func TestDb_Put_Merge(t *testing.T) {
 		}
 	}
 }
+
+func TestDb_RebuildIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("key1", "value1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("key2", "value2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate index drift
+	db.mu.Lock()
+	delete(db.fileIndex, "key1")
+	db.index["key2"] = 1
+	db.mu.Unlock()
+
+	if err := db.RebuildIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][]string{{"key1", "value1"}, {"key2", "value2"}} {
+		value, err := db.Get(pair[0])
+		if err != nil {
+			t.Fatalf("Cannot get %s: %s", pair[0], err)
+		}
+		if value != pair[1] {
+			t.Errorf("Bad value returned expected %s, got %s", pair[1], value)
+		}
+	}
+
+	// writes after the rebuild must continue at the right offset
+	if err := db.Put("key3", "value3"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := db.Get("key3"); err != nil || value != "value3" {
+		t.Errorf("Bad value returned expected value3, got %s (%v)", value, err)
+	}
+}
+
+func TestDb_RecoverSegmentOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// segment 10 is newer than segment 2 even though it sorts before it as a string
+	for segment, value := range map[int]string{2: "old", 10: "new"} {
+		e := entry{key: "key", value: value}
+		name := filepath.Join(dir, defaultOutFileName+"-"+strconv.Itoa(segment))
+		if err := ioutil.WriteFile(name, e.Encode(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value, err := db.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "new" {
+		t.Errorf("Bad value returned expected new, got %s", value)
+	}
+}
+
+func TestDb_Delete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, key := range []string{"key1", "key2"} {
+		if err := db.Put(key, "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Delete("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get("key1"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a deleted key, got %v", err)
+	}
+	if err := db.Delete("key1"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound when deleting a missing key, got %v", err)
+	}
+
+	t.Run("new db process", func(t *testing.T) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		db, err = NewDb(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Get("key1"); err != ErrNotFound {
+			t.Errorf("Deleted key was recovered: %v", err)
+		}
+		if value, err := db.Get("key2"); err != nil || value != "value" {
+			t.Errorf("Bad value returned expected value, got %s (%v)", value, err)
+		}
+	})
+}
+
+func TestDb_DeleteMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-delete-merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("key1", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("key2", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("key1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("key3", "value"); err != nil {
+		t.Fatal(err)
+	}
+	db.wg.Wait()
+
+	if _, err := db.Get("key1"); err != ErrNotFound {
+		t.Errorf("Deleted key survived merge: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = NewDb(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Get("key1"); err != ErrNotFound {
+		t.Errorf("Deleted key was recovered after merge: %v", err)
+	}
+	for _, key := range []string{"key2", "key3"} {
+		if value, err := db.Get(key); err != nil || value != "value" {
+			t.Errorf("Bad value returned for %s, got %s (%v)", key, value, err)
+		}
+	}
+}
+
+func TestDb_Has(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Has("key") {
+		t.Error("Expected the key to exist")
+	}
+	if err := db.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if db.Has("key") || db.Has("missing") {
+		t.Error("Expected the key to be missing")
+	}
+}
+
+func TestDb_KeyTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	key := strings.Repeat("k", MaxKeySize+1)
+	if err := db.Put(key, "value"); err != ErrKeyTooLarge {
+		t.Errorf("Expected ErrKeyTooLarge, got %v", err)
+	}
+	if err := db.PutStream(key, strings.NewReader("value"), 5); err != ErrKeyTooLarge {
+		t.Errorf("Expected ErrKeyTooLarge from PutStream, got %v", err)
+	}
+	if err := db.Put(key[:MaxKeySize], "value"); err != nil {
+		t.Errorf("Expected a key of %d bytes to be stored, got %v", MaxKeySize, err)
+	}
+}
+
+func TestDb_LegacyEntries(t *testing.T) {
+	dir := t.TempDir()
+	// two entries written before kinds were introduced, without the trailing kind byte
+	var data []byte
+	for _, e := range []entry{{key: "a", value: "1"}, {key: "b", value: "22"}} {
+		encoded := e.Encode()
+		legacy := encoded[:len(encoded)-1]
+		binary.LittleEndian.PutUint32(legacy, uint32(len(legacy)))
+		data = append(data, legacy...)
+	}
+	if err := os.WriteFile(filepath.Join(dir, defaultOutFileName+"-0"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.outOffset != int64(len(data)) {
+		t.Errorf("Unexpected out offset %d after legacy entries of %d bytes", db.outOffset, len(data))
+	}
+	if size := db.entrySize("a"); size != 14 {
+		t.Errorf("Unexpected size %d of the legacy entry of a, want 14", size)
+	}
+
+	if err := db.Put("c", "333"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RebuildIndex(); err != nil {
+		t.Fatal(err)
+	}
+	// the new entry is indexed where it was written, no read has to repair it
+	if db.index["c"] != int64(len(data)) {
+		t.Errorf("Unexpected offset %d of c, want %d", db.index["c"], len(data))
+	}
+	for key, want := range map[string]string{"a": "1", "b": "22", "c": "333"} {
+		if value, err := db.Get(key); err != nil || value != want {
+			t.Errorf("Unexpected value %q (%v) of %s, want %q", value, err, key, want)
+		}
+	}
+}
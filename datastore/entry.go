@@ -4,22 +4,68 @@ import (
 	"bufio"
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
+// kinds of entries, stored as the last byte of an encoded entry.
+// Entries written before kinds were introduced have no such byte and are values.
+const (
+	kindValue byte = iota
+	kindDeleted
+	kindInt64 // value holds 8 little-endian bytes
+)
+
+// flag of the kind byte, the encoded value is prefixed with 8 little-endian bytes of
+// the expiration time in unix nanoseconds
+const kindExpiring byte = 0x80
+
 type entry struct {
 	key, value string
+	kind       byte
+	expires    int64 // unix nanoseconds, 0 means the entry never expires
+	legacy     bool  // decoded without the kind byte, stored one byte shorter than size tells
+}
+
+// size of the encoded entry in bytes
+func (e *entry) size() int {
+	size := len(e.key) + len(e.value) + 13
+	if e.expires != 0 {
+		size += 8
+	}
+	return size
+}
+
+// bytes the entry takes in its segment, size is what Encode writes
+func (e *entry) storedSize() int {
+	if e.legacy {
+		return e.size() - 1
+	}
+	return e.size()
+}
+
+// tombstones mark deleted keys
+func (e *entry) deleted() bool {
+	return e.kind == kindDeleted
 }
 
 func (e *entry) Encode() []byte {
 	kl := len(e.key)
 	vl := len(e.value)
-	size := kl + vl + 12
+	size := e.size()
 	res := make([]byte, size)
 	binary.LittleEndian.PutUint32(res, uint32(size))
 	binary.LittleEndian.PutUint32(res[4:], uint32(kl))
 	copy(res[8:], e.key)
+	value := res[kl+12:]
+	res[size-1] = e.kind
+	if e.expires != 0 {
+		vl += 8
+		binary.LittleEndian.PutUint64(value, uint64(e.expires))
+		value = value[8:]
+		res[size-1] |= kindExpiring
+	}
 	binary.LittleEndian.PutUint32(res[kl+8:], uint32(vl))
-	copy(res[kl+12:], e.value)
+	copy(value, e.value)
 	return res
 }
 
@@ -30,11 +76,60 @@ func (e *entry) Decode(input []byte) {
 	e.key = string(keyBuf)
 
 	vl := binary.LittleEndian.Uint32(input[kl+8:])
-	valBuf := make([]byte, vl)
-	copy(valBuf, input[kl+12:kl+12+vl])
+	value := input[kl+12 : kl+12+vl]
+
+	e.kind = kindValue
+	e.expires = 0
+	e.legacy = len(input) <= int(kl+vl+12)
+	if !e.legacy {
+		e.kind = input[kl+vl+12]
+	}
+	if e.kind&kindExpiring != 0 && len(value) >= 8 {
+		e.expires = int64(binary.LittleEndian.Uint64(value))
+		value = value[8:]
+	}
+	e.kind &^= kindExpiring
+
+	valBuf := make([]byte, len(value))
+	copy(valBuf, value)
 	e.value = string(valBuf)
 }
 
+func (e *entry) expired(now int64) bool {
+	return e.expires != 0 && e.expires <= now
+}
+
+var errCorruptedEntry = fmt.Errorf("corrupted entry")
+
+// read a whole entry validating its framing
+func readEntry(in *bufio.Reader) (entry, error) {
+	header, err := in.Peek(4)
+	if err != nil {
+		return entry{}, err
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	if size < 12 {
+		return entry{}, errCorruptedEntry
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		return entry{}, err
+	}
+	kl := int(binary.LittleEndian.Uint32(data[4:]))
+	if kl > size-12 {
+		return entry{}, errCorruptedEntry
+	}
+	vl := int(binary.LittleEndian.Uint32(data[kl+8:]))
+	if kl+vl+12 != size && kl+vl+13 != size {
+		return entry{}, errCorruptedEntry
+	}
+
+	var e entry
+	e.Decode(data)
+	return e, nil
+}
+
 func readValue(in *bufio.Reader) (string, error) {
 	header, err := in.Peek(8)
 	if err != nil {
@@ -3,11 +3,12 @@ package datastore
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"testing"
 )
 
 func TestEntry_Encode(t *testing.T) {
-	e := entry{"key", "value"}
+	e := entry{key: "key", value: "value"}
 	encoded := e.Encode()
 	e.Decode(encoded)
 	if e.key != "key" {
@@ -19,7 +20,7 @@ func TestEntry_Encode(t *testing.T) {
 }
 
 func TestReadValue(t *testing.T) {
-	e := entry{"key", "test-value"}
+	e := entry{key: "key", value: "test-value"}
 	data := e.Encode()
 	//fmt.Println("Data:", data)
 	v, err := readValue(bufio.NewReader(bytes.NewReader(data)))
@@ -30,3 +31,25 @@ func TestReadValue(t *testing.T) {
 		t.Errorf("Got bat value [%s]", v)
 	}
 }
+
+func TestEntry_DecodeWithoutKind(t *testing.T) {
+	// entries written before kinds were introduced have no trailing kind byte
+	e := entry{key: "key", value: "value", kind: kindDeleted}
+	encoded := e.Encode()
+	legacy := encoded[:len(encoded)-1]
+	binary.LittleEndian.PutUint32(legacy, uint32(len(legacy)))
+
+	var decoded entry
+	decoded.Decode(legacy)
+	if decoded.key != "key" || decoded.value != "value" || decoded.deleted() {
+		t.Errorf("Unexpected entry %+v", decoded)
+	}
+
+	read, err := readEntry(bufio.NewReader(bytes.NewReader(legacy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != decoded {
+		t.Errorf("Unexpected entry %+v", read)
+	}
+}
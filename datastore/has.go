@@ -0,0 +1,10 @@
+package datastore
+
+// Has reports whether the key exists without reading its value
+func (db *Db) Has(key string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	_, ok := db.fileIndex[key]
+	return ok && !db.expired(key)
+}
@@ -0,0 +1,48 @@
+package datastore
+
+import (
+	"sort"
+	"strings"
+)
+
+// Iterator walks keys in ascending order. Keys are captured when the iterator is
+// created, values are read on demand and reflect the current state of the database.
+type Iterator struct {
+	db   *Db
+	keys []string
+	pos  int
+	trim int // length of the bucket prefix hidden from Key
+}
+
+// NewIterator returns an iterator over keys having the prefix and sorting after the given key,
+// an empty after starts from the first matching key
+func (db *Db) NewIterator(prefix, after string) *Iterator {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range db.fileIndex {
+		if strings.HasPrefix(key, prefix) && key > after && !db.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return &Iterator{db: db, keys: keys, pos: -1}
+}
+
+// Next advances to the next key, false is returned when there are no more keys
+func (it *Iterator) Next() bool {
+	if it.pos < len(it.keys) {
+		it.pos++
+	}
+	return it.pos < len(it.keys)
+}
+
+func (it *Iterator) Key() string {
+	return it.keys[it.pos][it.trim:]
+}
+
+// Value reads the value of the current key, ErrNotFound is returned if it was deleted meanwhile
+func (it *Iterator) Value() (string, error) {
+	return it.db.Get(it.keys[it.pos])
+}
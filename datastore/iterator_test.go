@@ -0,0 +1,56 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDb_Iterator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-iterator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, key := range []string{"user-2", "session-1", "user-1", "user-3"} {
+		if err := db.Put(key, "value-"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Delete("user-3"); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(it *Iterator) []string {
+		var keys []string
+		for it.Next() {
+			keys = append(keys, it.Key())
+			value, err := it.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != "value-"+it.Key() {
+				t.Errorf("Bad value returned for %s: %s", it.Key(), value)
+			}
+		}
+		return keys
+	}
+
+	if keys := collect(db.NewIterator("", "")); !reflect.DeepEqual(keys, []string{"session-1", "user-1", "user-2"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+	if keys := collect(db.NewIterator("user-", "")); !reflect.DeepEqual(keys, []string{"user-1", "user-2"}) {
+		t.Errorf("Unexpected keys with prefix %v", keys)
+	}
+	if keys := collect(db.NewIterator("user-", "user-1")); !reflect.DeepEqual(keys, []string{"user-2"}) {
+		t.Errorf("Unexpected keys after user-1 %v", keys)
+	}
+}
@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// MultiGet reads string values of several keys opening every segment file once.
+// Keys that are missing or hold values of other types are left out of the result.
+func (db *Db) MultiGet(keys []string) (map[string]string, error) {
+	entries, err := db.multiGet(keys)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(entries))
+	for key, e := range entries {
+		if e.kind == kindValue {
+			values[key] = e.value
+		}
+	}
+	return values, nil
+}
+
+// read entries of existing keys, grouping reads by segment
+func (db *Db) multiGet(keys []string) (map[string]entry, error) {
+	values := make(map[string]entry, len(keys))
+	var repairs []*indexRepair
+	defer func() {
+		for _, r := range repairs {
+			db.applyRepair(r)
+		}
+	}()
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// group keys by segment, ordered by their position to read files front to back
+	bySegment := make(map[int][]string)
+	for _, key := range keys {
+		segment, ok := db.fileIndex[key]
+		if !ok || db.expired(key) {
+			continue
+		}
+		if _, seen := bySegment[segment]; !seen {
+			if err := db.flushForRead(segment); err != nil {
+				return nil, err
+			}
+		}
+		if db.hotKeys != nil {
+			db.hotKeys.sample(key)
+		}
+		bySegment[segment] = append(bySegment[segment], key)
+	}
+	if len(bySegment) == 0 {
+		return values, nil
+	}
+
+	if err := db.workerPool.Acquire(context.Background(), 1); err != nil {
+		return nil, fmt.Errorf("acquire worker: %w", err)
+	}
+	defer db.workerPool.Release(1)
+
+	for segment, segmentKeys := range bySegment {
+		sort.Slice(segmentKeys, func(i, j int) bool {
+			return db.index[segmentKeys[i]] < db.index[segmentKeys[j]]
+		})
+
+		var stale []string
+		file, err := os.Open(db.segmentPath(segment))
+		if os.IsNotExist(err) {
+			stale = segmentKeys
+		} else if err != nil {
+			return nil, err
+		} else {
+			for _, key := range segmentKeys {
+				e, err := readEntryAt(file, key, db.index[key])
+				if err == errStaleIndex {
+					stale = append(stale, key)
+				} else if err != nil {
+					file.Close()
+					return nil, err
+				} else {
+					values[key] = e
+				}
+			}
+			file.Close()
+		}
+
+		for _, key := range stale {
+			e, repair, err := db.findKey(key, segment, db.index[key])
+			if repair != nil {
+				repairs = append(repairs, repair)
+			}
+			if err == nil {
+				values[key] = e
+			} else if err != nil && err != ErrNotFound {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,47 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDb_MultiGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-multiget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pairs := [][]string{
+		{"key1", "value1"},
+		{"key2", "value2"},
+		{"key3", "value3"},
+		{"key4", "value4"},
+	}
+	for _, pair := range pairs {
+		if err := db.Put(pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.wg.Wait()
+	if err := db.PutInt64("counter", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := db.MultiGet([]string{"key4", "key1", "missing", "counter", "key3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"key1": "value1", "key3": "value3", "key4": "value4"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Bad values returned expected %v, got %v", expected, values)
+	}
+}
@@ -0,0 +1,90 @@
+package datastore
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+var ErrQuotaExceeded = fmt.Errorf("disk quota exceeded")
+
+type QuotaPolicy int
+
+const (
+	// QuotaReject makes Put fail with ErrQuotaExceeded once the quota is reached
+	QuotaReject QuotaPolicy = iota
+	// QuotaEvict drops least recently written keys and compacts all segments to make room
+	QuotaEvict
+)
+
+// SetQuota limits the total size of segment files, a zero maxBytes removes the limit
+func (db *Db) SetQuota(maxBytes int64, policy QuotaPolicy) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.quota = maxBytes
+	db.quotaPolicy = policy
+}
+
+// make sure entries of the given total size fit into the quota, db.mu must be locked for writing
+func (db *Db) checkQuota(replaced []string, size int) error {
+	if db.quota <= 0 || db.diskUsage+int64(size) <= db.quota {
+		return nil
+	}
+	if db.quotaPolicy != QuotaEvict {
+		return ErrQuotaExceeded
+	}
+
+	// live entries ordered from the least recently written one, entries of the same
+	// merged segment are not ordered between themselves
+	skip := make(map[string]bool, len(replaced))
+	for _, k := range replaced {
+		skip[k] = true
+	}
+	keys := make([]string, 0, len(db.fileIndex))
+	var live int64
+	for k := range db.fileIndex {
+		if skip[k] {
+			continue // its current value is replaced anyway
+		}
+		keys = append(keys, k)
+		live += db.entrySize(k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		si, sj := db.fileIndex[keys[i]], db.fileIndex[keys[j]]
+		if si != sj {
+			return si < sj
+		}
+		return db.index[keys[i]] < db.index[keys[j]]
+	})
+
+	evict := 0
+	for live+int64(size) > db.quota && evict < len(keys) {
+		live -= db.entrySize(keys[evict])
+		evict++
+	}
+	if live+int64(size) > db.quota {
+		return ErrQuotaExceeded
+	}
+
+	for _, k := range keys[:evict] {
+		db.forget(k)
+		db.watchers.notify(entry{key: k, kind: kindDeleted})
+	}
+	for _, k := range replaced {
+		// old values of replaced keys are dropped by compaction as well
+		db.forget(k)
+	}
+	fmt.Printf("Quota exceeded, evicting %d keys\n", evict)
+
+	// rewrite everything written so far without the evicted keys
+	if err := db.rotate(); err != nil {
+		return err
+	}
+	return db.merge(atomic.AddInt64(&goroutineID, 1), true)
+}
+
+// size of the live entry of the key
+func (db *Db) entrySize(key string) int64 {
+	e := entry{key: key, expires: db.expiries[key], legacy: db.legacy[key]}
+	return int64(e.storedSize() + db.sizeIndex[key])
+}
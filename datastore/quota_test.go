@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDb_QuotaReject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// room for two entries of 10 + 4 + 13 bytes
+	db.SetQuota(60, QuotaReject)
+
+	value := strings.Repeat("v", 10)
+	for _, key := range []string{"key1", "key2"} {
+		if err := db.Put(key, value); err != nil {
+			t.Fatalf("Could not put %s: %v", key, err)
+		}
+	}
+	if err := db.Put("key3", value); err != ErrQuotaExceeded {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := db.Get("key3"); err != ErrNotFound {
+		t.Errorf("Rejected key must not be stored, got %v", err)
+	}
+}
+
+func TestDb_QuotaEvict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.SetQuota(60, QuotaEvict)
+
+	value := strings.Repeat("v", 10)
+	for _, key := range []string{"key1", "key2", "key2", "key3"} {
+		if err := db.Put(key, value); err != nil {
+			t.Fatalf("Could not put %s: %v", key, err)
+		}
+	}
+
+	if _, err := db.Get("key1"); err != ErrNotFound {
+		t.Errorf("Expected the least recently written key to be evicted, got %v", err)
+	}
+	for _, key := range []string{"key2", "key3"} {
+		if v, err := db.Get(key); err != nil || v != value {
+			t.Errorf("Expected %s to survive eviction, got %s (%v)", key, v, err)
+		}
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DiskUsage > 60 {
+		t.Errorf("Disk usage %d exceeds the quota", stats.DiskUsage)
+	}
+
+	if err := db.Put("huge", strings.Repeat("v", 100)); err != ErrQuotaExceeded {
+		t.Errorf("Expected ErrQuotaExceeded for an entry larger than the quota, got %v", err)
+	}
+}
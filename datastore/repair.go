@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"fmt"
+	"os"
+)
+
+var errStaleIndex = fmt.Errorf("index entry does not match segment data")
+
+// index fix found by a read that hit a stale position
+type indexRepair struct {
+	key           string
+	staleSegment  int
+	stalePosition int64
+	segment       int
+	position      int64
+	size          int
+	legacy        bool
+	found         bool
+}
+
+// scan segments for the latest entry of the key, starting with the one the index points to.
+// db.mu must be locked for reading.
+func (db *Db) findKey(key string, staleSegment int, stalePosition int64) (entry, *indexRepair, error) {
+	fmt.Printf("Stale index entry for %s at segment %d offset %d, scanning segments\n", key, staleSegment, stalePosition)
+
+	repair := &indexRepair{key: key, staleSegment: staleSegment, stalePosition: stalePosition}
+
+	segments, err := listSegments(db.dir())
+	if err != nil {
+		return entry{}, nil, err
+	}
+	candidates := []int{staleSegment}
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != staleSegment {
+			candidates = append(candidates, segments[i])
+		}
+	}
+
+	for _, segment := range candidates {
+		var latest entry
+		found := false
+		err := readEntries(db.segmentPath(segment), func(e entry, offset int64) {
+			if e.key == key {
+				latest, found = e, !e.deleted()
+				repair.position = offset
+			}
+		})
+		if found {
+			repair.segment, repair.size, repair.legacy, repair.found = segment, len(latest.value), latest.legacy, true
+			return latest, repair, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return entry{}, nil, err
+		}
+	}
+	return entry{}, repair, ErrNotFound
+}
+
+// update the index entry unless it was changed since the stale read
+func (db *Db) applyRepair(r *indexRepair) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.repairIndex(r)
+}
+
+// applyRepair for callers holding db.mu locked for writing
+func (db *Db) repairIndex(r *indexRepair) {
+	segment, ok := db.fileIndex[r.key]
+	if !ok || segment != r.staleSegment || db.index[r.key] != r.stalePosition {
+		return
+	}
+	if r.found {
+		db.fileIndex[r.key] = r.segment
+		db.index[r.key] = r.position
+		db.sizeIndex[r.key] = r.size
+		if r.legacy {
+			db.legacy[r.key] = true
+		} else {
+			delete(db.legacy, r.key)
+		}
+		fmt.Printf("Repaired index entry for %s: segment %d offset %d\n", r.key, r.segment, r.position)
+	} else {
+		db.forget(r.key)
+		fmt.Printf("Removed index entry for missing key %s\n", r.key)
+	}
+}
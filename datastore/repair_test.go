@@ -0,0 +1,82 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_ReadRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-repair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, pair := range [][]string{{"key1", "value1"}, {"key2", "value2"}} {
+		if err := db.Put(pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectedOffset := db.index["key2"]
+
+	t.Run("offset of another key", func(t *testing.T) {
+		db.index["key2"] = 0
+
+		value, err := db.Get("key2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "value2" {
+			t.Errorf("Bad value returned expected value2, got %s", value)
+		}
+		if db.index["key2"] != expectedOffset {
+			t.Errorf("Index was not repaired: %d instead of %d", db.index["key2"], expectedOffset)
+		}
+	})
+
+	t.Run("offset past the end", func(t *testing.T) {
+		db.index["key2"] = 10000
+
+		value, err := db.Get("key2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "value2" {
+			t.Errorf("Bad value returned expected value2, got %s", value)
+		}
+	})
+
+	t.Run("missing segment", func(t *testing.T) {
+		db.fileIndex["key1"] = 7
+
+		value, err := db.Get("key1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != "value1" {
+			t.Errorf("Bad value returned expected value1, got %s", value)
+		}
+		if db.fileIndex["key1"] != 0 {
+			t.Errorf("Index was not repaired, segment %d", db.fileIndex["key1"])
+		}
+	})
+
+	t.Run("key not on disk", func(t *testing.T) {
+		db.index["ghost"] = 0
+		db.fileIndex["ghost"] = 0
+
+		if _, err := db.Get("ghost"); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if _, ok := db.fileIndex["ghost"]; ok {
+			t.Error("Expected the stale index entry to be removed")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// ScanResult is a key with its value, values written with PutInt64 are formatted
+// in decimal and have the int64 type
+type ScanResult struct {
+	Key   string
+	Value string
+	Type  string // empty for strings
+}
+
+// Scan returns up to limit keys having the prefix in ascending order together with
+// their values, reading each segment file once
+func (db *Db) Scan(prefix string, limit int) ([]ScanResult, error) {
+	var keys []string
+	for it := db.NewIterator(prefix, ""); len(keys) < limit && it.Next(); {
+		keys = append(keys, it.Key())
+	}
+
+	entries, err := db.multiGet(keys)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ScanResult, 0, len(entries))
+	for _, key := range keys {
+		e, ok := entries[key]
+		if !ok {
+			continue // deleted meanwhile
+		}
+		r := ScanResult{Key: key, Value: e.value}
+		if e.kind == kindInt64 && len(e.value) == 8 {
+			r.Value = strconv.FormatInt(int64(binary.LittleEndian.Uint64([]byte(e.value))), 10)
+			r.Type = TypeInt64
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDb_Scan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, key := range []string{"sess-2", "user-1", "sess-1", "sess-3"} {
+		if err := db.Put(key, "v-"+key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.PutInt64("sess-0", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := db.Scan("sess-", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScanResult{
+		{Key: "sess-0", Value: "7", Type: TypeInt64},
+		{Key: "sess-1", Value: "v-sess-1"},
+		{Key: "sess-2", Value: "v-sess-2"},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("Bad scan result expected %v, got %v", expected, res)
+	}
+}
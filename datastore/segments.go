@@ -0,0 +1,65 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type SegmentInfo struct {
+	Index       int       `json:"index"`
+	Size        int64     `json:"size"` // bytes on disk
+	Entries     int       `json:"entries"`
+	LiveEntries int       `json:"liveEntries"` // entries still referenced by the index
+	Created     time.Time `json:"created"`     // file systems rarely expose birth time, so the modification time is used
+}
+
+// Segments returns metadata of every segment file in ascending order
+func (db *Db) Segments() ([]SegmentInfo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	db.bufMu.Lock()
+	err := db.flush()
+	db.bufMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := listSegments(db.dir())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]SegmentInfo, 0, len(segments))
+	for _, segment := range segments {
+		filePath := db.segmentPath(segment)
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		info := SegmentInfo{
+			Index:   segment,
+			Size:    fileInfo.Size(),
+			Created: fileInfo.ModTime(),
+		}
+		err = readEntries(filePath, func(e entry, offset int64) {
+			info.Entries++
+			if s, ok := db.fileIndex[e.key]; ok && s == segment && db.index[e.key] == offset {
+				info.LiveEntries++
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// path of the segment file with the given index
+func (db *Db) segmentPath(segment int) string {
+	return filepath.Join(db.dir(), defaultOutFileName+"-"+strconv.Itoa(segment))
+}
@@ -0,0 +1,58 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_Segments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-segments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// rotate after the first entry
+	db, err := NewDb(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, pair := range [][]string{{"key1", "value1"}, {"key1", "value2"}, {"key2", "value"}} {
+		if err := db.Put(pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.wg.Wait()
+
+	segments, err := db.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) == 0 {
+		t.Fatal("Expected at least one segment")
+	}
+
+	entries, live := 0, 0
+	for i, s := range segments {
+		if i > 0 && s.Index <= segments[i-1].Index {
+			t.Errorf("Segments are not sorted: %v", segments)
+		}
+		if s.Size == 0 && s.Entries > 0 {
+			t.Errorf("Segment %d has entries but zero size", s.Index)
+		}
+		if s.Created.IsZero() {
+			t.Errorf("Segment %d has no creation time", s.Index)
+		}
+		entries += s.Entries
+		live += s.LiveEntries
+	}
+	if live != 2 {
+		t.Errorf("Expected 2 live entries, got %d", live)
+	}
+	if entries < live {
+		t.Errorf("Live entries (%d) exceed total entries (%d)", live, entries)
+	}
+}
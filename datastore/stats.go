@@ -0,0 +1,156 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// upper bounds of the value-size histogram buckets, the last one catches everything else
+var valueSizeBuckets = []int64{64, 256, 1024, 4 * 1024, 16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024, math.MaxInt64}
+
+// hot key tracker keeps this many candidates per requested top-N entry
+const hotKeyCapacityFactor = 4
+
+type SizeBucket struct {
+	UpperBound int64 `json:"upperBound"` // math.MaxInt64 for the last bucket
+	Count      int   `json:"count"`
+}
+
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+type Stats struct {
+	KeyCount     int          `json:"keyCount"`
+	SegmentCount int          `json:"segmentCount"`
+	DiskUsage    int64        `json:"diskUsage"` // total size of segment files in bytes
+	DeadBytes    int64        `json:"deadBytes"` // bytes taken by overwritten and deleted entries
+	Compactions  int          `json:"compactions"`
+	ValueSizes   []SizeBucket `json:"valueSizes"`
+	HotKeys      []KeyCount   `json:"hotKeys,omitempty"` // empty unless TrackHotKeys was called
+}
+
+// Stats returns a snapshot of the database state
+func (db *Db) Stats() (Stats, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	stats := Stats{
+		KeyCount:    len(db.fileIndex),
+		Compactions: db.compactions,
+		ValueSizes:  make([]SizeBucket, len(valueSizeBuckets)),
+	}
+	for i, bound := range valueSizeBuckets {
+		stats.ValueSizes[i].UpperBound = bound
+	}
+	var liveBytes int64
+	for key, size := range db.sizeIndex {
+		liveBytes += db.entrySize(key)
+		i := sort.Search(len(valueSizeBuckets), func(i int) bool {
+			return int64(size) <= valueSizeBuckets[i]
+		})
+		stats.ValueSizes[i].Count++
+	}
+
+	files, err := ioutil.ReadDir(db.dir())
+	if err != nil {
+		return Stats{}, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasPrefix(file.Name(), defaultOutFileName+"-") {
+			continue
+		}
+		stats.SegmentCount++
+		stats.DiskUsage += file.Size()
+	}
+	stats.DiskUsage += int64(db.buffered())
+	stats.DeadBytes = stats.DiskUsage - liveBytes
+
+	if db.hotKeys != nil {
+		stats.HotKeys = db.hotKeys.top()
+	}
+	return stats, nil
+}
+
+// TrackHotKeys enables sampling of read keys, every sampleRate-th Get is counted
+// and the n most read keys are reported in Stats
+func (db *Db) TrackHotKeys(n int, sampleRate int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if n <= 0 {
+		db.hotKeys = nil
+		return
+	}
+	if sampleRate < 1 {
+		sampleRate = 1
+	}
+	db.hotKeys = &hotKeyTracker{
+		n:          n,
+		capacity:   n * hotKeyCapacityFactor,
+		sampleRate: int64(sampleRate),
+		counts:     make(map[string]int64),
+	}
+}
+
+// bounded counter of the most frequent keys (space-saving algorithm),
+// memory stays limited no matter how many distinct keys are read
+type hotKeyTracker struct {
+	n          int
+	capacity   int
+	sampleRate int64
+	reads      int64
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (t *hotKeyTracker) sample(key string) {
+	if atomic.AddInt64(&t.reads, 1)%t.sampleRate != 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.counts[key]; ok || len(t.counts) < t.capacity {
+		t.counts[key]++
+		return
+	}
+
+	// replace the least frequent key, the newcomer inherits its count
+	var minKey string
+	var minCount int64 = math.MaxInt64
+	for k, c := range t.counts {
+		if c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = minCount + 1
+}
+
+func (t *hotKeyTracker) top() []KeyCount {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]KeyCount, 0, len(t.counts))
+	for k, c := range t.counts {
+		res = append(res, KeyCount{Key: k, Count: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Key < res[j].Key
+	})
+	if len(res) > t.n {
+		res = res[:t.n]
+	}
+	return res
+}
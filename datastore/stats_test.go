@@ -0,0 +1,129 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDb_Stats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	db.TrackHotKeys(2, 1)
+
+	if err := db.Put("small", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("large", strings.Repeat("x", 2000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("cold", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	reads := map[string]int{"small": 3, "large": 2, "cold": 1}
+	for key, n := range reads {
+		for i := 0; i < n; i++ {
+			if _, err := db.Get(key); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.KeyCount != 3 {
+		t.Errorf("Expected 3 keys, got %d", stats.KeyCount)
+	}
+	if stats.SegmentCount != 1 {
+		t.Errorf("Expected 1 segment, got %d", stats.SegmentCount)
+	}
+	if stats.DiskUsage == 0 {
+		t.Error("Expected non-zero disk usage")
+	}
+	if stats.DeadBytes != 0 {
+		t.Errorf("Expected no dead bytes, got %d", stats.DeadBytes)
+	}
+	if stats.ValueSizes[0].Count != 2 {
+		t.Errorf("Expected 2 small values, got %d", stats.ValueSizes[0].Count)
+	}
+	if stats.ValueSizes[3].Count != 1 {
+		t.Errorf("Expected 1 value up to 4KiB, got %d", stats.ValueSizes[3].Count)
+	}
+
+	if len(stats.HotKeys) != 2 {
+		t.Fatalf("Expected 2 hot keys, got %v", stats.HotKeys)
+	}
+	if stats.HotKeys[0] != (KeyCount{"small", 3}) || stats.HotKeys[1] != (KeyCount{"large", 2}) {
+		t.Errorf("Unexpected hot keys %v", stats.HotKeys)
+	}
+}
+
+func TestHotKeyTracker_Bounded(t *testing.T) {
+	tracker := &hotKeyTracker{n: 1, capacity: 2, sampleRate: 1, counts: make(map[string]int64)}
+	for _, key := range []string{"a", "a", "a", "a", "a", "b", "c", "d", "e"} {
+		tracker.sample(key)
+	}
+	if len(tracker.counts) > 2 {
+		t.Errorf("Tracker grew beyond its capacity: %v", tracker.counts)
+	}
+	if top := tracker.top(); len(top) != 1 || top[0].Key != "a" {
+		t.Errorf("Expected a to be the hottest key, got %v", top)
+	}
+}
+
+func TestDb_StatsDeadBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	e := entry{key: "key", value: "value"}
+	for i := 0; i < 3; i++ {
+		if err := db.Put(e.key, e.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeadBytes != int64(2*e.size()) {
+		t.Errorf("Expected %d dead bytes, got %d", 2*e.size(), stats.DeadBytes)
+	}
+
+	if _, err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = db.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeadBytes != 0 {
+		t.Errorf("Expected no dead bytes after compaction, got %d", stats.DeadBytes)
+	}
+	if stats.Compactions != 1 {
+		t.Errorf("Expected 1 compaction, got %d", stats.Compactions)
+	}
+}
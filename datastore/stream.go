@@ -0,0 +1,172 @@
+package datastore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// PutStream stores a value of the given size read from r without holding it in memory.
+// The database is locked for writing while r is consumed, so r should be a local source
+// such as a file rather than a network connection. Watch events of such values carry no
+// value and are marked Streamed.
+func (db *Db) PutStream(key string, r io.Reader, size int64) error {
+	if len(key) > MaxKeySize {
+		return ErrKeyTooLarge
+	}
+	e := entry{key: key}
+	total := int64(e.size()) + size
+	if size < 0 || total > math.MaxUint32 {
+		return fmt.Errorf("%w: %d bytes", ErrValueTooLarge, size)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.rotateIfFull(); err != nil {
+		return err
+	}
+	if err := db.checkQuota([]string{key}, int(total)); err != nil {
+		return err
+	}
+	// buffered entries go first, the value is then copied straight into the segment
+	if err := db.flush(); err != nil {
+		return err
+	}
+
+	kl := len(key)
+	header := make([]byte, kl+12)
+	binary.LittleEndian.PutUint32(header, uint32(total))
+	binary.LittleEndian.PutUint32(header[4:], uint32(kl))
+	copy(header[8:], key)
+	binary.LittleEndian.PutUint32(header[kl+8:], uint32(size))
+
+	err := writeStream(db.out, header, r, size, kindValue)
+	if err != nil {
+		// drop the partial entry so that it is not taken for data
+		if truncErr := db.out.Truncate(db.outOffset); truncErr != nil {
+			return fmt.Errorf("%v (truncating segment: %v)", err, truncErr)
+		}
+		return err
+	}
+	db.indexEntry(e, int(size))
+	db.diskUsage += total
+	return nil
+}
+
+func writeStream(out io.Writer, header []byte, r io.Reader, size int64, kind byte) error {
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	n, err := io.CopyN(out, r, size)
+	if err == io.EOF {
+		return fmt.Errorf("value is shorter than declared: %d of %d bytes", n, size)
+	} else if err != nil {
+		return err
+	}
+	_, err = out.Write([]byte{kind})
+	return err
+}
+
+// GetStream returns a reader of the value and its size, the reader must be closed.
+// Reading holds one slot of the worker pool until then.
+func (db *Db) GetStream(key string) (io.ReadCloser, int64, error) {
+	db.mu.RLock()
+	segment, ok := db.fileIndex[key]
+	position, indexed := db.index[key]
+	if !ok || !indexed || db.expired(key) {
+		db.mu.RUnlock()
+		return nil, 0, ErrNotFound
+	}
+	if err := db.flushForRead(segment); err != nil {
+		db.mu.RUnlock()
+		return nil, 0, err
+	}
+	if err := db.workerPool.Acquire(context.Background(), 1); err != nil {
+		db.mu.RUnlock()
+		return nil, 0, fmt.Errorf("acquire worker: %w", err)
+	}
+	// the file stays readable after merge removes it or renames the merged segment 0
+	// over it, since it is opened under the lock
+	file, err := os.Open(db.segmentPath(segment))
+	db.mu.RUnlock()
+
+	if err == nil {
+		var value *io.SectionReader
+		value, err = openValue(file, key, position)
+		if err == nil {
+			return &streamReader{SectionReader: value, file: file, db: db}, value.Size(), nil
+		}
+		file.Close()
+	}
+	db.workerPool.Release(1)
+
+	if os.IsNotExist(err) || err == errStaleIndex {
+		// let the regular read path find the key and repair the index
+		e, err := db.getEntry(key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if e.kind != kindValue {
+			return nil, 0, ErrWrongType
+		}
+		return io.NopCloser(strings.NewReader(e.value)), int64(len(e.value)), nil
+	}
+	return nil, 0, err
+}
+
+// locate the value of the entry at the position, checking that it is a value of the key
+func openValue(file *os.File, key string, position int64) (*io.SectionReader, error) {
+	kl := len(key)
+	header := make([]byte, kl+12)
+	if _, err := file.ReadAt(header, position); err == io.EOF {
+		return nil, errStaleIndex
+	} else if err != nil {
+		return nil, err
+	}
+	size := int64(binary.LittleEndian.Uint32(header))
+	if int(binary.LittleEndian.Uint32(header[4:])) != kl || string(header[8:kl+8]) != key {
+		return nil, errStaleIndex
+	}
+	vl := int64(binary.LittleEndian.Uint32(header[kl+8:]))
+	offset := position + int64(kl) + 12
+
+	switch size {
+	case int64(kl) + vl + 12: // legacy entry without a kind
+	case int64(kl) + vl + 13:
+		kind := make([]byte, 1)
+		if _, err := file.ReadAt(kind, position+size-1); err == io.EOF {
+			return nil, errStaleIndex
+		} else if err != nil {
+			return nil, err
+		}
+		if kind[0]&kindExpiring != 0 && vl >= 8 {
+			offset, vl = offset+8, vl-8 // skip the expiration time
+		}
+		if kind[0]&^kindExpiring == kindDeleted {
+			return nil, errStaleIndex
+		}
+		if kind[0]&^kindExpiring != kindValue {
+			return nil, ErrWrongType
+		}
+	default:
+		return nil, errStaleIndex
+	}
+	return io.NewSectionReader(file, offset, vl), nil
+}
+
+type streamReader struct {
+	*io.SectionReader
+	file *os.File
+	db   *Db
+}
+
+func (r *streamReader) Close() error {
+	err := r.file.Close()
+	r.db.workerPool.Release(1)
+	return err
+}
@@ -0,0 +1,144 @@
+package datastore
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDb_Stream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	value := strings.Repeat("0123456789", 100000)
+	if err := db.PutStream("large", strings.NewReader(value), int64(len(value))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("small", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, size, err := db.GetStream("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(value)) || string(data) != value {
+		t.Errorf("Bad streamed value of %d bytes, got %d bytes", len(value), len(data))
+	}
+
+	// streamed values are regular entries
+	if got, err := db.Get("large"); err != nil || got != value {
+		t.Errorf("Cannot read streamed value with Get: %v", err)
+	}
+	r, _, err = db.GetStream("small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadAll(r)
+	r.Close()
+	if string(data) != "value" {
+		t.Errorf("Bad value returned expected value, got %s", data)
+	}
+
+	if err := db.PutInt64("int", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.GetStream("int"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType, got %v", err)
+	}
+	if _, _, err := db.GetStream("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDb_PutStreamShortReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PutStream("key", bytes.NewReader([]byte("short")), 100); err == nil {
+		t.Fatal("Expected an error for a value shorter than declared")
+	}
+	if err := db.Put("next", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RebuildIndex(); err != nil {
+		t.Fatalf("Partial entry was left in the segment: %v", err)
+	}
+	if got, err := db.Get("next"); err != nil || got != "value" {
+		t.Errorf("Bad value returned expected value, got %s (%v)", got, err)
+	}
+	if _, err := db.Get("key"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDb_StreamDuringMerge(t *testing.T) {
+	db, err := NewDb(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	old := strings.Repeat("a", 100000)
+	if err := db.PutStream("large", strings.NewReader(old), int64(len(old))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := db.GetStream("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	start := make([]byte, 1000)
+	if _, err := io.ReadFull(r, start); err != nil {
+		t.Fatal(err)
+	}
+
+	// segment 0 is rewritten with a new value of the key while the stream is open
+	updated := strings.Repeat("b", len(old))
+	if err := db.PutStream("large", strings.NewReader(updated), int64(len(updated))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(start) + string(rest); got != old {
+		t.Errorf("Unexpected streamed value of %d bytes changed by the merge", len(got))
+	}
+	if value, err := db.Get("large"); err != nil || value != updated {
+		t.Errorf("Unexpected value after merge (%v)", err)
+	}
+}
@@ -0,0 +1,44 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+)
+
+// replaced in tests
+var timeNow = time.Now
+
+// PutWithTTL stores a value that expires after ttl. Expired keys are not returned
+// by reads and are dropped by compaction, writing the key again without a TTL
+// makes it permanent.
+func (db *Db) PutWithTTL(key, value string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("ttl must be positive, got %s", ttl)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e := entry{key: key, value: value, expires: timeNow().Add(ttl).UnixNano()}
+	return db.writeEntries([]entry{e})
+}
+
+// TTL returns the time left until the key expires, zero means the key never expires
+func (db *Db) TTL(key string) (time.Duration, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if _, ok := db.fileIndex[key]; !ok || db.expired(key) {
+		return 0, ErrNotFound
+	}
+	expires, ok := db.expiries[key]
+	if !ok {
+		return 0, nil
+	}
+	return time.Unix(0, expires).Sub(timeNow()), nil
+}
+
+// report whether the key has a TTL that has run out, db.mu must be locked for reading
+func (db *Db) expired(key string) bool {
+	expires, ok := db.expiries[key]
+	return ok && expires <= timeNow().UnixNano()
+}
@@ -0,0 +1,84 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDb_TTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PutWithTTL("session", "token", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("permanent", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := db.Get("session"); err != nil || value != "token" {
+		t.Errorf("Bad value returned expected token, got %s (%v)", value, err)
+	}
+	if ttl, err := db.TTL("session"); err != nil || ttl != time.Minute {
+		t.Errorf("Bad TTL returned expected 1m, got %s (%v)", ttl, err)
+	}
+	if ttl, err := db.TTL("permanent"); err != nil || ttl != 0 {
+		t.Errorf("Expected no TTL, got %s (%v)", ttl, err)
+	}
+
+	// the expiration time survives a restart
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = NewDb(dir); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := db.TTL("session"); err != nil || ttl != time.Minute {
+		t.Errorf("Bad TTL after recovery expected 1m, got %s (%v)", ttl, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := db.Get("session"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an expired key, got %v", err)
+	}
+	if it := db.NewIterator("", ""); !it.Next() || it.Key() != "permanent" || it.Next() {
+		t.Error("Expected the iterator to skip the expired key")
+	}
+
+	if _, err := db.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := db.Stats(); err != nil || stats.KeyCount != 1 {
+		t.Errorf("Expected the expired key to be dropped by compaction, got %+v (%v)", stats, err)
+	}
+	if value, err := db.Get("permanent"); err != nil || value != "value" {
+		t.Errorf("Bad value returned expected value, got %s (%v)", value, err)
+	}
+}
+
+func TestEntry_EncodeExpiring(t *testing.T) {
+	e := entry{key: "key", value: "value", kind: kindValue, expires: 42}
+	var decoded entry
+	decoded.Decode(e.Encode())
+	if decoded != e {
+		t.Errorf("Unexpected entry %+v", decoded)
+	}
+	if len(e.Encode()) != e.size() {
+		t.Errorf("Encoded size %d does not match %d", len(e.Encode()), e.size())
+	}
+}
@@ -0,0 +1,145 @@
+package datastore
+
+import (
+	"fmt"
+)
+
+var (
+	ErrConflict = fmt.Errorf("transaction conflicts with a concurrent write")
+	ErrTxDone   = fmt.Errorf("transaction has already been committed or rolled back")
+)
+
+// Tx is an optimistic transaction. Writes are kept in memory and visible to the
+// transaction's own reads; Commit writes them as a single batch and fails with
+// ErrConflict if any key read by the transaction was changed in the meantime.
+type Tx struct {
+	db *Db
+
+	reads  map[string]uint64 // key -> version seen by the first read
+	writes map[string]entry
+	order  []string // keys in the order of their first write
+	done   bool
+}
+
+// Begin starts a new transaction
+func (db *Db) Begin() *Tx {
+	return &Tx{
+		db:     db,
+		reads:  make(map[string]uint64),
+		writes: make(map[string]entry),
+	}
+}
+
+func (tx *Tx) Get(key string) (string, error) {
+	if tx.done {
+		return "", ErrTxDone
+	}
+	if e, ok := tx.writes[key]; ok {
+		if e.deleted() {
+			return "", ErrNotFound
+		}
+		return e.value, nil
+	}
+
+	tx.db.mu.RLock()
+	e, repair, err := tx.db.get(key)
+	version := tx.db.versions[key]
+	tx.db.mu.RUnlock()
+
+	if repair != nil {
+		tx.db.applyRepair(repair)
+	}
+	if err == nil || err == ErrNotFound {
+		tx.recordRead(key, version)
+	}
+	if err != nil {
+		return "", err
+	}
+	if e.kind != kindValue {
+		return "", ErrWrongType
+	}
+	return e.value, nil
+}
+
+func (tx *Tx) Put(key, value string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.write(entry{key: key, value: value})
+	return nil
+}
+
+// Delete removes the key within the transaction, ErrNotFound is returned for missing keys
+func (tx *Tx) Delete(key string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if e, ok := tx.writes[key]; ok {
+		if e.deleted() {
+			return ErrNotFound
+		}
+	} else {
+		tx.db.mu.RLock()
+		_, exists := tx.db.fileIndex[key]
+		version := tx.db.versions[key]
+		tx.db.mu.RUnlock()
+
+		tx.recordRead(key, version)
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	tx.write(entry{key: key, kind: kindDeleted})
+	return nil
+}
+
+// Commit atomically applies all writes of the transaction
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	db := tx.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for key, version := range tx.reads {
+		if db.versions[key] != version {
+			return ErrConflict
+		}
+	}
+
+	entries := make([]entry, 0, len(tx.order))
+	for _, key := range tx.order {
+		e := tx.writes[key]
+		if _, exists := db.fileIndex[key]; e.deleted() && !exists {
+			continue // created and deleted within the transaction
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.writeEntries(entries)
+}
+
+// Rollback discards all writes of the transaction
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.writes = nil
+	tx.order = nil
+}
+
+func (tx *Tx) recordRead(key string, version uint64) {
+	if _, ok := tx.reads[key]; !ok {
+		tx.reads[key] = version
+	}
+}
+
+func (tx *Tx) write(e entry) {
+	if _, ok := tx.writes[e.key]; !ok {
+		tx.order = append(tx.order, e.key)
+	}
+	tx.writes[e.key] = e
+}
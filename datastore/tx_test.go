@@ -0,0 +1,164 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestTx(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Put("balance-a", "100"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("balance-b", "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("read your writes and commit", func(t *testing.T) {
+		tx := db.Begin()
+		a, err := tx.Get("balance-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		amount, _ := strconv.Atoi(a)
+		if err := tx.Put("balance-a", strconv.Itoa(amount-30)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("balance-b", "30"); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := tx.Get("balance-a"); v != "70" {
+			t.Errorf("Expected to read own write 70, got %s", v)
+		}
+		if v, _ := db.Get("balance-a"); v != "100" {
+			t.Errorf("Uncommitted write is visible outside the transaction: %s", v)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := db.Get("balance-b"); v != "30" {
+			t.Errorf("Expected committed value 30, got %s", v)
+		}
+		if err := tx.Put("balance-b", "0"); err != ErrTxDone {
+			t.Errorf("Expected ErrTxDone, got %v", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		tx := db.Begin()
+		if err := tx.Delete("balance-a"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.Get("balance-a"); err != ErrNotFound {
+			t.Errorf("Expected the key to be deleted within the transaction, got %v", err)
+		}
+		tx.Rollback()
+		if v, _ := db.Get("balance-a"); v != "70" {
+			t.Errorf("Rolled back delete was applied, got %s", v)
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		tx := db.Begin()
+		if _, err := tx.Get("balance-a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Put("balance-a", "0"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("balance-a", "1000"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != ErrConflict {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+		if v, _ := db.Get("balance-a"); v != "0" {
+			t.Errorf("Conflicting transaction was applied, got %s", v)
+		}
+	})
+
+	t.Run("conflict on missing key", func(t *testing.T) {
+		tx := db.Begin()
+		if _, err := tx.Get("new"); err != ErrNotFound {
+			t.Fatalf("Expected ErrNotFound, got %v", err)
+		}
+		if err := db.Put("new", "concurrent"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("new", "tx"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != ErrConflict {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+}
+
+func TestTxConflictsWithDelete(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("recovered", "1"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	// keys recovered from disk start at version 0
+	db, err = NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t.Run("read then concurrent delete", func(t *testing.T) {
+		tx := db.Begin()
+		if _, err := tx.Get("recovered"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Delete("recovered"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("recovered", "2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != ErrConflict {
+			t.Errorf("Expected a conflict with the delete, got %v", err)
+		}
+		if _, err := db.Get("recovered"); err != ErrNotFound {
+			t.Errorf("Deleted key was written back: %v", err)
+		}
+	})
+
+	t.Run("missing then put and delete", func(t *testing.T) {
+		tx := db.Begin()
+		if _, err := tx.Get("created"); err != ErrNotFound {
+			t.Fatalf("Expected a missing key, got %v", err)
+		}
+		if err := db.Put("created", "1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Delete("created"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("created", "2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != ErrConflict {
+			t.Errorf("Expected a conflict with the put and delete, got %v", err)
+		}
+	})
+}
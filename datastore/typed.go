@@ -0,0 +1,28 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var ErrWrongType = fmt.Errorf("stored value has a different type")
+
+// PutInt64 stores an integer value, it can only be read back with GetInt64
+func (db *Db) PutInt64(key string, value int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.writeEntries([]entry{int64Entry(key, value)})
+}
+
+// GetInt64 reads a value stored with PutInt64, ErrWrongType is returned for other values
+func (db *Db) GetInt64(key string) (int64, error) {
+	e, err := db.getEntry(key)
+	if err != nil {
+		return 0, err
+	}
+	if e.kind != kindInt64 || len(e.value) != 8 {
+		return 0, ErrWrongType
+	}
+	return int64(binary.LittleEndian.Uint64([]byte(e.value))), nil
+}
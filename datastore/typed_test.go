@@ -0,0 +1,59 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_Int64(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PutInt64("counter", -42); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("text", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := db.GetInt64("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != -42 {
+		t.Errorf("Bad value returned expected -42, got %d", value)
+	}
+
+	if _, err := db.Get("counter"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType reading an integer as string, got %v", err)
+	}
+	if _, err := db.GetInt64("text"); err != ErrWrongType {
+		t.Errorf("Expected ErrWrongType reading a string as integer, got %v", err)
+	}
+	if _, err := db.GetInt64("missing"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	t.Run("new db process", func(t *testing.T) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		db, err = NewDb(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := db.GetInt64("counter"); err != nil || value != -42 {
+			t.Errorf("Bad value returned expected -42, got %d (%v)", value, err)
+		}
+	})
+}
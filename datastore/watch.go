@@ -0,0 +1,100 @@
+package datastore
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// number of events a watcher may lag behind before it is dropped
+const watchBufferSize = 64
+
+// Event describes a change of a key. Values written with PutInt64 are formatted in decimal
+// and have the int64 type.
+type Event struct {
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	Type    string `json:"type,omitempty"`    // empty for strings
+	Expires int64  `json:"expires,omitempty"` // unix nanoseconds, set for values written with a TTL
+	Deleted bool   `json:"deleted,omitempty"`
+	// set for values written with PutStream, the event does not carry them
+	Streamed bool `json:"streamed,omitempty"`
+}
+
+// type name of int64 values in events
+const TypeInt64 = "int64"
+
+type watcher struct {
+	prefix string
+	events chan Event
+}
+
+type watchers struct {
+	mu   sync.Mutex
+	list map[*watcher]struct{}
+}
+
+// Watch streams changes of keys with the given prefix, an empty prefix matches every key.
+// The channel is closed by the returned cancel function, or when the watcher falls more
+// than watchBufferSize events behind so that writers are never blocked by slow readers.
+func (db *Db) Watch(prefix string) (<-chan Event, func()) {
+	return db.WatchBuffered(prefix, watchBufferSize)
+}
+
+// WatchBuffered is Watch letting the watcher fall up to size events behind
+func (db *Db) WatchBuffered(prefix string, size int) (<-chan Event, func()) {
+	w := &watcher{prefix: prefix, events: make(chan Event, size)}
+
+	db.watchers.mu.Lock()
+	if db.watchers.list == nil {
+		db.watchers.list = make(map[*watcher]struct{})
+	}
+	db.watchers.list[w] = struct{}{}
+	db.watchers.mu.Unlock()
+
+	return w.events, func() { db.watchers.remove(w) }
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if _, ok := ws.list[w]; ok {
+		delete(ws.list, w)
+		close(w.events)
+	}
+}
+
+func (ws *watchers) notify(e entry) {
+	event := Event{Key: e.key, Value: e.value, Expires: e.expires, Deleted: e.deleted()}
+	if e.kind == kindInt64 && len(e.value) == 8 {
+		event.Value = strconv.FormatInt(int64(binary.LittleEndian.Uint64([]byte(e.value))), 10)
+		event.Type = TypeInt64
+	}
+	ws.send(event)
+}
+
+func (ws *watchers) send(event Event) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for w := range ws.list {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			delete(ws.list, w)
+			close(w.events)
+		}
+	}
+}
+
+func (ws *watchers) closeAll() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for w := range ws.list {
+		close(w.events)
+	}
+	ws.list = nil
+}
@@ -0,0 +1,83 @@
+package datastore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDb_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	events, cancel := db.Watch("user:")
+
+	if err := db.Put("user:1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("order:1", "book"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutInt64("user:2", 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("user:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{Key: "user:1", Value: "alice"},
+		{Key: "user:2", Value: "42", Type: TypeInt64},
+		{Key: "user:1", Deleted: true},
+	}
+	for _, want := range expected {
+		if got := <-events; got != want {
+			t.Errorf("Bad event expected %v, got %v", want, got)
+		}
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected the channel to be closed after cancel")
+	}
+}
+
+func TestDb_WatchSlowReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test-db-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewDb(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	events, cancel := db.Watch("")
+	defer cancel()
+
+	for i := 0; i <= watchBufferSize; i++ {
+		if err := db.Put("key", "value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != watchBufferSize {
+		t.Errorf("Expected %d buffered events before the watcher is dropped, got %d", watchBufferSize, n)
+	}
+}
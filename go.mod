@@ -4,8 +4,11 @@ go 1.20
 
 require (
 	github.com/gorilla/mux v1.8.0
+	golang.org/x/sync v0.3.0
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
+)
+
+require (
 	github.com/kr/pretty v0.2.1 // indirect
 	github.com/kr/text v0.1.0 // indirect
-	golang.org/x/sync v0.3.0
-	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
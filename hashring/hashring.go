@@ -0,0 +1,56 @@
+package hashring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// VirtualNodes is the number of points of each unit of node weight on the ring,
+// more points spread keys more evenly.
+const VirtualNodes = 128
+
+// Node is a member of the ring, it gets points in proportion to its weight.
+type Node struct {
+	Name   string
+	Weight int
+}
+
+type point struct {
+	hash uint32
+	node string
+}
+
+// Ring is a consistent hash of keys to nodes, removing a node moves only the keys it owned.
+type Ring struct {
+	points []point
+}
+
+func New(nodes []Node) *Ring {
+	ring := &Ring{}
+	for _, node := range nodes {
+		for i := 0; i < VirtualNodes*node.Weight; i++ {
+			hash := crc32.ChecksumIEEE([]byte(node.Name + "#" + strconv.Itoa(i)))
+			ring.points = append(ring.points, point{hash: hash, node: node.Name})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring
+}
+
+// Get returns the first node clockwise from the hash of the key, empty when the ring has no nodes.
+func (ring *Ring) Get(key string) string {
+	if len(ring.points) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i].hash >= hash
+	})
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.points[i].node
+}
@@ -0,0 +1,43 @@
+package hashring
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	ring := New([]Node{{"a", 1}, {"b", 1}, {"c", 1}})
+	smaller := New([]Node{{"a", 1}, {"c", 1}})
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		node := ring.Get(key)
+		counts[node]++
+		// removing b moves only its keys
+		if node != "b" && smaller.Get(key) != node {
+			t.Errorf("Unexpected key %s moved from %s to %s", key, node, smaller.Get(key))
+		}
+	}
+	for _, node := range []string{"a", "b", "c"} {
+		if counts[node] < 500 {
+			t.Errorf("Unexpected %d of 3000 keys on %s", counts[node], node)
+		}
+	}
+	if node := New(nil).Get("key"); node != "" {
+		t.Errorf("Unexpected node %q of an empty ring", node)
+	}
+}
+
+func TestRingWeighted(t *testing.T) {
+	ring := New([]Node{{"a", 3}, {"b", 1}})
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[ring.Get(fmt.Sprintf("/api/v1/item-%d", i))]++
+	}
+
+	if counts["a"] <= 2*counts["b"] {
+		t.Errorf("Unexpected keys per node %v, a weighs 3 times b", counts)
+	}
+}
@@ -1,36 +1,97 @@
 package httptools
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
 type Server interface {
 	Start()
+	// Shutdown stops accepting connections and waits for active requests to finish.
+	// Connections still active when ctx is done are closed.
+	Shutdown(ctx context.Context) error
 }
 
+// WriteTimeout is the time the servers have to write a response, handlers
+// must answer well within it for their response to reach the client.
+const WriteTimeout = 10 * time.Second
+
+// prefix of addresses naming a unix socket, e.g. unix:///run/db.sock
+const unixScheme = "unix://"
+
 type server struct {
 	httpServer *http.Server
+	network    string
+	// serve HTTPS with the certificate and key files when set
+	certFile, keyFile string
 }
 
 func (s server) Start() {
+	if s.network == "unix" {
+		// socket left behind by a previous run
+		os.Remove(s.httpServer.Addr)
+	}
+	listener, err := net.Listen(s.network, s.httpServer.Addr)
+	if err != nil {
+		log.Fatalf("HTTP server cannot listen on %s: %s. Finishing the process.", s.httpServer.Addr, err)
+	}
 	go func() {
 		log.Println("Staring the HTTP server...")
-		err := s.httpServer.ListenAndServe()
+		var err error
+		if s.certFile != "" {
+			err = s.httpServer.ServeTLS(listener, s.certFile, s.keyFile)
+		} else {
+			err = s.httpServer.Serve(listener)
+		}
+		if err == http.ErrServerClosed {
+			return
+		}
 		log.Fatalf("HTTP server finished: %s. Finishing the process.", err)
 	}()
 }
 
+func (s server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.httpServer.Close()
+	}
+	return err
+}
+
 func CreateServer(port int, handler http.Handler) Server {
+	return CreateServerAt(fmt.Sprintf(":%d", port), handler)
+}
+
+// CreateServerAt creates a server listening on a host:port address
+// or on a unix socket given as unix:///path.sock.
+func CreateServerAt(addr string, handler http.Handler) Server {
+	network := "tcp"
+	if strings.HasPrefix(addr, unixScheme) {
+		network = "unix"
+		addr = strings.TrimPrefix(addr, unixScheme)
+	}
 	return server{
+		network: network,
 		httpServer: &http.Server{
-			Addr:           fmt.Sprintf(":%d", port),
+			Addr:           addr,
 			Handler:        handler,
 			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
+			WriteTimeout:   WriteTimeout,
 			MaxHeaderBytes: 1 << 20,
 		},
 	}
 }
+
+// CreateTLSServer creates a server answering HTTPS on the port with the
+// PEM encoded certificate and key files.
+func CreateTLSServer(port int, handler http.Handler, certFile, keyFile string) Server {
+	s := CreateServer(port, handler).(server)
+	s.certFile, s.keyFile = certFile, keyFile
+	return s
+}
@@ -0,0 +1,56 @@
+package httptools
+
+import (
+	"net/http"
+	"strings"
+)
+
+// W3C trace context headers, passed on unchanged so the services of a request share its trace.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// TraceID returns the trace id of a valid traceparent header, empty when there is none.
+func TraceID(h http.Header) string {
+	parts := strings.Split(h.Get(TraceParentHeader), "-")
+	if len(parts) < 4 || !lowerHex(parts[0], 2) || parts[0] == "ff" ||
+		!lowerHex(parts[1], 32) || !lowerHex(parts[2], 16) || !lowerHex(parts[3], 2) {
+		return ""
+	}
+	// only later versions may carry more fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return ""
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return ""
+	}
+	return parts[1]
+}
+
+func lowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// PropagateTrace copies the request id and a valid trace context of the request
+// being served to an outgoing request made for it.
+func PropagateTrace(out, in *http.Request) {
+	if id := in.Header.Get(RequestIDHeader); id != "" {
+		out.Header.Set(RequestIDHeader, id)
+	}
+	if TraceID(in.Header) == "" {
+		return
+	}
+	out.Header.Set(TraceParentHeader, in.Header.Get(TraceParentHeader))
+	if state := in.Header.Get(TraceStateHeader); state != "" {
+		out.Header.Set(TraceStateHeader, state)
+	}
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// formats of the event lines, also used by the access log of the balancer
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named debug, info, warn or error.
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if name == levelName {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// Logger writes what happened to a service as one event per line, key=value pairs
+// or JSON objects. Events below the level are dropped.
+type Logger struct {
+	format string
+	level  Level
+	now    func() time.Time
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// Default logs text events of level info and above to stderr, used until the flags are parsed.
+func Default() *Logger {
+	return &Logger{format: FormatText, level: LevelInfo, now: time.Now, out: os.Stderr}
+}
+
+func New(format, level string, out io.Writer) (*Logger, error) {
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	min, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{format: format, level: min, now: time.Now, out: out}, nil
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+func (l *Logger) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields) }
+func (l *Logger) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields) }
+func (l *Logger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+// write the event with fields given as key, value pairs
+func (l *Logger) log(level Level, msg string, fields []interface{}) {
+	if level < l.level {
+		return
+	}
+	keys := []string{"time", "level", "msg"}
+	values := []interface{}{l.now().UTC().Format(time.RFC3339Nano), level.String(), msg}
+	for i := 0; i+1 < len(fields); i += 2 {
+		keys = append(keys, fmt.Sprint(fields[i]))
+		value := fields[i+1]
+		switch v := value.(type) {
+		case error:
+			value = v.Error()
+		case time.Duration:
+			value = v.String()
+		}
+		values = append(values, value)
+	}
+
+	var line strings.Builder
+	if l.format == FormatJSON {
+		line.WriteByte('{')
+		for i, key := range keys {
+			k, _ := json.Marshal(key)
+			v, err := json.Marshal(values[i])
+			if err != nil {
+				v, _ = json.Marshal(fmt.Sprint(values[i]))
+			}
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.Write(k)
+			line.WriteByte(':')
+			line.Write(v)
+		}
+		line.WriteByte('}')
+	} else {
+		for i, key := range keys {
+			if i > 0 {
+				line.WriteByte(' ')
+			}
+			value := fmt.Sprint(values[i])
+			if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+				value = fmt.Sprintf("%q", value)
+			}
+			line.WriteString(key + "=" + value)
+		}
+	}
+	line.WriteByte('\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.out, line.String())
+}
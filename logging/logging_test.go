@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var text, jsonOut bytes.Buffer
+	textLog, err := New(FormatText, "info", &text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonLog, err := New(FormatJSON, "warn", &jsonOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func() time.Time { return time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC) }
+	textLog.now, jsonLog.now = at, at
+
+	for _, l := range []*Logger{textLog, jsonLog} {
+		l.Debug("health checked", "backend", "server1:8080", "healthy", true)
+		l.Info("backend healthy", "backend", "server1:8080")
+		l.Error("backend failed", "backend", "server1:8080", "status", 503, "duration", 1500*time.Millisecond, "error", errors.New("connection refused"))
+	}
+
+	// events below the level are dropped
+	want := "time=2023-05-01T10:00:00Z level=info msg=\"backend healthy\" backend=server1:8080\n" +
+		"time=2023-05-01T10:00:00Z level=error msg=\"backend failed\" backend=server1:8080 status=503 duration=1.5s error=\"connection refused\"\n"
+	if text.String() != want {
+		t.Errorf("Unexpected text events %q, want %q", text.String(), want)
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(jsonOut.Bytes(), &event); err != nil {
+		t.Fatalf("Unexpected JSON events %q: %s", jsonOut.String(), err)
+	}
+	wantEvent := map[string]interface{}{
+		"time":     "2023-05-01T10:00:00Z",
+		"level":    "error",
+		"msg":      "backend failed",
+		"backend":  "server1:8080",
+		"status":   503.0,
+		"duration": "1.5s",
+		"error":    "connection refused",
+	}
+	if !reflect.DeepEqual(event, wantEvent) {
+		t.Errorf("Unexpected JSON event %v", event)
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New("xml", "info", nil); err == nil {
+		t.Errorf("Unexpected xml log format accepted")
+	}
+	if _, err := New(FormatText, "verbose", nil); err == nil {
+		t.Errorf("Unexpected verbose log level accepted")
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds in seconds of the duration histogram buckets.
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts durations in LatencyBuckets. It is not safe for concurrent use,
+// the metrics holding it lock around observations and writes.
+type Histogram struct {
+	counts []uint64 // per bucket, the last one counts everything above the largest bound
+	sum    float64
+	count  uint64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.counts[sort.SearchFloat64s(LatencyBuckets, seconds)]++
+	h.sum += seconds
+	h.count++
+}
+
+// WriteHistogram writes the series of h in the Prometheus text format, labels are
+// given like backend="a" and may be empty.
+func WriteHistogram(w io.Writer, name, labels string, h *Histogram) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+	var cumulative uint64
+	for i, bound := range LatencyBuckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", name, prefix, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+	if labels == "" {
+		fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+		fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+		return
+	}
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// CountingWriter keeps the first write error so an exposition can be written
+// without checking each line. N and Err are what WriteTo returns.
+type CountingWriter struct {
+	W   io.Writer
+	N   int64
+	Err error
+}
+
+func (cw *CountingWriter) Write(data []byte) (int, error) {
+	if cw.Err != nil {
+		return 0, cw.Err
+	}
+	n, err := cw.W.Write(data)
+	cw.N += int64(n)
+	cw.Err = err
+	return n, err
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHistogram(t *testing.T) {
+	h := NewHistogram()
+	h.Observe(3 * time.Millisecond)
+	h.Observe(200 * time.Millisecond)
+	h.Observe(time.Minute)
+
+	var out strings.Builder
+	WriteHistogram(&out, "lb_request_duration_seconds", `backend="a"`, h)
+
+	for _, line := range []string{
+		`lb_request_duration_seconds_bucket{backend="a",le="0.005"} 1`,
+		`lb_request_duration_seconds_bucket{backend="a",le="0.25"} 2`,
+		`lb_request_duration_seconds_bucket{backend="a",le="10"} 2`,
+		`lb_request_duration_seconds_bucket{backend="a",le="+Inf"} 3`,
+		`lb_request_duration_seconds_sum{backend="a"} 60.203`,
+		`lb_request_duration_seconds_count{backend="a"} 3`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Unexpected histogram without %q:\n%s", line, out.String())
+		}
+	}
+
+	out.Reset()
+	WriteHistogram(&out, "server_db_request_duration_seconds", "", NewHistogram())
+	if !strings.Contains(out.String(), "server_db_request_duration_seconds_bucket{le=\"+Inf\"} 0\nserver_db_request_duration_seconds_sum 0\n") {
+		t.Errorf("Unexpected histogram without labels:\n%s", out.String())
+	}
+}
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("closed")
+}
+
+func TestCountingWriterKeepsFirstError(t *testing.T) {
+	out := &failingWriter{}
+	cw := &CountingWriter{W: out}
+	_, _ = cw.Write([]byte("a"))
+	_, _ = cw.Write([]byte("b"))
+	if out.writes != 1 || cw.Err == nil || cw.N != 0 {
+		t.Errorf("Unexpected %d writes, %d bytes and error %v", out.writes, cw.N, cw.Err)
+	}
+}